@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"github.com/hansung080/gchain/node"
+)
+
+func changePassphrase(nodeID string) result {
+	wallets, err := node.NewWallets(nodeID)
+	if err != nil {
+		return newFailure("Passphrase change", err)
+	}
+
+	if !wallets.IsEncrypted() {
+		return newFailure("Passphrase change", node.ErrWalletNotEncrypted)
+	}
+
+	oldPassphrase, err := readPassphrase("Enter old wallet passphrase: ")
+	if err != nil {
+		return newFailure("Passphrase change", err)
+	}
+
+	newPassphrase, err := readNewPassphrase()
+	if err != nil {
+		return newFailure("Passphrase change", err)
+	}
+
+	if err := wallets.ChangePassphrase(nodeID, oldPassphrase, newPassphrase); err != nil {
+		return newFailure("Passphrase change", err)
+	}
+
+	return messageResult{"Passphrase changed."}
+}
@@ -5,30 +5,140 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/hansung080/gchain/net/banman"
+	"github.com/hansung080/gchain/net/server"
+	"github.com/hansung080/gchain/node"
 )
 
-type CLI struct {}
+type CLI struct {
+	output string // text or json
+}
 
 func (cli *CLI) printUsage() {
-	fmt.Println("Usage: gchain <command> <flag>...")
+	fmt.Println("Usage: gchain -output <format> -net <network> <command> <flag>...")
+	fmt.Println("  -output is text (default) or json, which prints the result of the command as JSON.")
+	fmt.Println("  -net is mainnet (default), testnet or regtest, whose data files are prefixed by its name except mainnet.")
+	fmt.Println("  The default port is 3000 on mainnet, 13000 on testnet and 23000 on regtest.")
+	fmt.Println(" * changepassphrase")
+	fmt.Println("     : Change the passphrase of the encrypted wallet, and re-encrypt the wallet file.")
 	fmt.Println(" * createblockchain -addr <address>")
 	fmt.Println("     : Create a blockchain and send the genesis block reward to <address>.")
-	fmt.Println(" * createwallet")
+	fmt.Println(" * createrawtx -from <from> -to <to> -amount <amount> -file <file> -change <change> -fee <fee> -coinselect <strategy> -utxo <outpoints> -rbf -out <out>")
+	fmt.Println("     : Create the unsigned transaction into <out> file without any private key.")
+	fmt.Println("       The recipients are <to> and <amount>, or in <file> of sendmany.")
+	fmt.Println("       -change is the address to get the change back. It is the first <from> address by default.")
+	fmt.Println("       -fee is left to the miner. It is 0 by default.")
+	fmt.Println("       -rbf signals replace-by-fee in the inputs to sign, so that a conflicting transaction")
+	fmt.Println("       paying higher fee could replace it in the mempool.")
+	fmt.Println(" * createwallet -mnemonic -keytype <keytype>")
 	fmt.Println("     : Generate a new key-pair and save it into the wallet.")
+	fmt.Println("       -mnemonic derives the key-pair from the HD wallet seed instead,")
+	fmt.Println("       and prints a new mnemonic to back up, when the wallet has no seed yet.")
+	fmt.Println("       -keytype is secp256k1 (default) or p256. It is ignored for the existing seed.")
+	fmt.Println(" * dumpprivkey -addr <address>")
+	fmt.Println("     : Export the private key of <address> from the wallet.")
+	fmt.Println(" * dumppubkey -addr <address>")
+	fmt.Println("     : Export the public key of <address> from the wallet as hex.")
+	fmt.Println(" * encryptwallet")
+	fmt.Println("     : Encrypt the wallet file with a new passphrase.")
 	fmt.Println(" * getbalance -addr <address>")
 	fmt.Println("     : Get the balance of <address>.")
+	fmt.Println(" * history -addr <address> -page <page> -size <size>")
+	fmt.Println("     : List the transactions of <address> from the newest with their confirmations.")
+	fmt.Println("       <page> starts from 1, and <size> is 10 by default. It requires the history index.")
+	fmt.Println(" * importaddr -addr <address>")
+	fmt.Println("     : Import <address> into the wallet as watch-only.")
+	fmt.Println(" * importprivkey -key <key>")
+	fmt.Println("     : Import the private key exported by dumpprivkey into the wallet.")
+	fmt.Println(" * importpubkey -pkey <pkey>")
+	fmt.Println("     : Import the hex-encoded public key into the wallet as watch-only.")
 	fmt.Println(" * listaddr")
-	fmt.Println("     : List all the addresses from the wallet.")
-	fmt.Println(" * printchain")
-	fmt.Println("     : Print all the blocks of the blockchain.")
+	fmt.Println("     : List all the addresses from the wallet with their balances.")
+	fmt.Println(" * listbanned")
+	fmt.Println("     : List the banned hosts with their expiration times and reasons.")
+	fmt.Println(" * mine -node <addr> -miner <miner> -blocks <blocks>")
+	fmt.Println("     : Mine the block templates of the node by getwork and submitblock at JSON-RPC <addr> as an external miner.")
+	fmt.Println("       <addr> is RPC_ADDR env. var. by default, and <miner> is the miner address of the node by default.")
+	fmt.Println("       -blocks is the number of the blocks to mine. It mines forever by default.")
+	fmt.Println(" * printchain -from <height> -to <height> -limit <limit> -hash <hash> -txid <txid> -headers-only -asc -verify")
+	fmt.Println("     : Print the blocks of the blockchain from the tip, or from <from> height with -asc.")
+	fmt.Println("       -hash prints only the block of <hash>, and -txid prints only <txid> in its block.")
+	fmt.Println("       -headers-only omits the transactions.")
+	fmt.Println("       -verify recomputes the proof of work, the merkle root and the signatures of each block,")
+	fmt.Println("       and exits with 1 if any block fails.")
+	fmt.Println(" * reindexhistory")
+	fmt.Println("     : Build the history index, which is updated on new blocks since then.")
 	fmt.Println(" * reindexutxo")
 	fmt.Println("     : Rebuild the UTXO set.")
-	fmt.Println(" * send -from <from> -to <to> -amount <amount> -mine")
+	fmt.Println(" * restorewallet -mnemonic <mnemonic> -keytype <keytype>")
+	fmt.Println("     : Restore the HD wallet from <mnemonic>, and add the addresses owning UTXOs.")
+	fmt.Println("       It fails on the HD wallet of another mnemonic, not to replace its seed.")
+	fmt.Println("       -keytype is secp256k1 (default) or p256.")
+	fmt.Println(" * rpc -addr <addr> <method> <params>...")
+	fmt.Println("     : Call JSON-RPC <method> of the node at <addr> (default: RPC_ADDR env. var.), and print the result.")
+	fmt.Println("       <params> are parsed as JSON, or taken as strings if not JSON.")
+	fmt.Println(" * send -from <from> -to <to> -amount <amount> -fee <fee> -mine -coinselect <strategy> -utxo <outpoints>")
 	fmt.Println("     : Send <amount> of coins from <from> address to <to> address.")
+	fmt.Println("       -fee is left to the miner. It is 0 by default.")
 	fmt.Println("       Mine on the same node, when -mine is set.")
-	fmt.Println(" * startnode -miner <miner>")
+	fmt.Println("       -coinselect is bnb (default), largest, smallest or random.")
+	fmt.Println("       -utxo spends the comma-separated <txid>:<vout> outpoints first.")
+	fmt.Println(" * sendmany -from <from> -file <file> -fee <fee> -mine -coinselect <strategy> -utxo <outpoints> -node <node>")
+	fmt.Println("     : Send coins to the recipients in <file> from the comma-separated <from> addresses.")
+	fmt.Println("       <file> is a CSV file of <address>,<amount> lines,")
+	fmt.Println("       or a JSON file of [{\"addr\": <address>, \"amount\": <amount>}, ...] with .json extension.")
+	fmt.Println("       -fee, -mine, -coinselect and -utxo are the same as send.")
+	fmt.Println("       Without -mine, send it to the node in RPC_ADDR env. var., or to <node> address (default: localhost:<default port>).")
+	fmt.Println(" * sendrawtx -in <in> -miner <miner> -node <node> -peerid <id>")
+	fmt.Println("     : Send the signed transaction in <in> file to <node> address (default: localhost:<default port>).")
+	fmt.Println("       -miner mines it on the same node instead, and sends the block reward to <miner> address.")
+	fmt.Println("       -peerid sends it anonymously over TLS to the node of the secure transport,")
+	fmt.Println("       only if the node has <id>, which is printed by the node on start.")
+	fmt.Println(" * setban -host <host> -duration <duration> -remove")
+	fmt.Println("     : Ban <host> for <duration> (default: 24h), so that the node disconnects it and never connects to it.")
+	fmt.Println("       -remove lifts the ban of <host> instead.")
+	fmt.Println(" * signrawtx -in <in> -out <out>")
+	fmt.Println("     : Sign the inputs of the transaction in <in> file with the wallet only, and save it into <out> file.")
+	fmt.Println("       <out> is <in> by default. Any blockchain is not required.")
+	fmt.Println("       With RPC_ADDR env. var., the wallet of the running node signs it instead.")
+	fmt.Println(" * startnode -listen <addr> -external <addr> -seeds <addrs> -miner <miner> -emptyblocks -blocksonly -rpc <addr> -rpcauth <auth> -explorer <addr> -secure -allow <ids>")
 	fmt.Println("     : Start a node with ID specified in NODE_ID env. var.")
+	fmt.Println("       -listen is localhost:<NODE_ID> by default, and -external is the address advertised to the peers.")
+	fmt.Println("       -seeds are the comma-separated peers to connect to first (default: localhost:<default port>).")
 	fmt.Println("       -miner enables mining and send the block reward to <miner> address.")
+	fmt.Println("       The miner mines the transactions in the mempool in the background, and restarts on a new tip.")
+	fmt.Println("       -emptyblocks mines the blocks without a transaction too.")
+	fmt.Println("       -blocksonly neither accepts nor relays the transactions from the peers.")
+	fmt.Println("       It accepts the transactions sent from localhost, or with -peerid of sendrawtx.")
+	fmt.Println("       -rpc serves JSON-RPC on <addr>: getbestheight, getblock, getblockbyheight, gettransaction,")
+	fmt.Println("       getbalance, sendrawtransaction, getmempool, getpeerinfo, listbanned, setban, getmininginfo,")
+	fmt.Println("       getwork, submitblock, generate, which mines N blocks at once on regtest,")
+	fmt.Println("       walletpassphrase, walletlock and signrawtransaction, which signs with the wallet of the node.")
+	fmt.Println("       The wallet methods are served only on a loopback address, such as localhost:8332.")
+	fmt.Println("       -rpcauth is <user>:<password> required by JSON-RPC. A random password is written into")
+	fmt.Println("       rpc_cookie_<NODE_ID> by default, which the CLI of the same NODE_ID reads.")
+	fmt.Println("       The CLI sends RPC_AUTH env. var. of <user>:<password> instead, if it is set.")
+	fmt.Println("       It also streams Server-Sent Events on /events?types=<type>,...&addr=<addr>,...: blockconnected,")
+	fmt.Println("       blockdisconnected, txaccepted and txremoved.")
+	fmt.Println("       -explorer serves the read-only block explorer pages on <addr>.")
+	fmt.Println("       Known peers are exchanged with the other nodes, and kept in peers_<NODE_ID>.json.")
+	fmt.Println("       A misbehaving peer, such as sending malformed messages, invalid data or too many messages,")
+	fmt.Println("       is banned for 24h, and the bans are kept in bans_<NODE_ID>.json.")
+	fmt.Println("       -secure talks to the peers over TLS authenticated by the node key in nodekey_<NODE_ID>.pem,")
+	fmt.Println("       and prints the peer ID of the node. -allow accepts and dials only the peers of <ids>.")
+	fmt.Println(" * walletlock")
+	fmt.Println("     : Lock the wallet of the running node at RPC_ADDR env. var. before the timeout of walletpassphrase.")
+	fmt.Println(" * walletpassphrase -timeout <timeout>")
+	fmt.Println("     : Unlock the encrypted wallet of the running node at RPC_ADDR env. var. for <timeout> (default: 5m),")
+	fmt.Println("       so that signrawtx signs with it without the passphrase until then.")
+	fmt.Println()
+	fmt.Println("The encrypted wallet is unlocked until a command exits with the passphrase")
+	fmt.Println("in WALLET_PASSPHRASE env. var., or prompted when the env. var. is not set.")
+	fmt.Println("getbalance, listbanned, sendrawtx, setban and signrawtx talk to the running node by JSON-RPC,")
+	fmt.Println("when RPC_ADDR env. var. is set.")
 }
 
 func (cli *CLI) printUsageAndExit() {
@@ -36,39 +146,108 @@ func (cli *CLI) printUsageAndExit() {
 	os.Exit(1)
 }
 
-func (cli *CLI) validateArgs() {
-	if len(os.Args) < 2 {
+// parseGlobalFlags parses the flags before the command, and returns the command with its flags.
+func (cli *CLI) parseGlobalFlags() []string {
+	global := flag.NewFlagSet("gchain", flag.ContinueOnError)
+	global.Usage = cli.printUsage
+	output := global.String("output", outputText, "The output format: text or json")
+	network := global.String("net", node.MainNetParams.Name, "The network: mainnet, testnet or regtest")
+
+	if err := global.Parse(os.Args[1:]); err != nil {
+		os.Exit(1)
+	}
+
+	if *output != outputText && *output != outputJSON {
+		fmt.Printf("Invalid output format: %s\n", *output)
+		cli.printUsageAndExit()
+	}
+	cli.output = *output
+
+	if err := node.SelectNetwork(*network); err != nil {
+		fmt.Println(err)
 		cli.printUsageAndExit()
 	}
+
+	if global.NArg() < 1 {
+		cli.printUsageAndExit()
+	}
+
+	// keep stdout only for the result, so that it is parsed as JSON.
+	if cli.output == outputJSON {
+		node.MiningOutput = os.Stderr
+		promptOutput = os.Stderr
+	}
+
+	return global.Args()
 }
 
 func (cli *CLI) Run() {
-	cli.validateArgs()
+	args := cli.parseGlobalFlags()
+	defer cli.recoverFailure()
 
 	nodeID := os.Getenv("NODE_ID")
 	if nodeID == "" {
-		fmt.Println("NODE_ID env. var. is not set.")
-		os.Exit(1)
+		cli.print(failureResult{Error: "NODE_ID env. var. is not set."})
 	}
 
 	var err error
-	switch os.Args[1] {
+	switch args[0] {
+	case "changepassphrase":
+		err = cli.handleChangePassphrase(nodeID, args[1:])
 	case "createblockchain":
-		err = cli.handleCreateBlockchain(nodeID, os.Args[2:])
+		err = cli.handleCreateBlockchain(nodeID, args[1:])
+	case "createrawtx":
+		err = cli.handleCreateRawTx(nodeID, args[1:])
 	case "createwallet":
-		err = cli.handleCreateWallet(nodeID, os.Args[2:])
+		err = cli.handleCreateWallet(nodeID, args[1:])
+	case "dumpprivkey":
+		err = cli.handleDumpPrivKey(nodeID, args[1:])
+	case "dumppubkey":
+		err = cli.handleDumpPubKey(nodeID, args[1:])
+	case "encryptwallet":
+		err = cli.handleEncryptWallet(nodeID, args[1:])
 	case "getbalance":
-		err = cli.handleGetBalance(nodeID, os.Args[2:])
+		err = cli.handleGetBalance(nodeID, args[1:])
+	case "history":
+		err = cli.handleHistory(nodeID, args[1:])
+	case "importaddr":
+		err = cli.handleImportAddress(nodeID, args[1:])
+	case "importprivkey":
+		err = cli.handleImportPrivKey(nodeID, args[1:])
+	case "importpubkey":
+		err = cli.handleImportPubKey(nodeID, args[1:])
 	case "listaddr":
-		err = cli.handleListAddresses(nodeID, os.Args[2:])
+		err = cli.handleListAddresses(nodeID, args[1:])
+	case "listbanned":
+		err = cli.handleListBanned(nodeID, args[1:])
+	case "mine":
+		err = cli.handleMine(nodeID, args[1:])
 	case "printchain":
-		err = cli.handlePrintChain(nodeID, os.Args[2:])
+		err = cli.handlePrintChain(nodeID, args[1:])
+	case "reindexhistory":
+		err = cli.handleReindexHistory(nodeID, args[1:])
 	case "reindexutxo":
-		err = cli.handleReindexUTXO(nodeID, os.Args[2:])
+		err = cli.handleReindexUTXO(nodeID, args[1:])
+	case "restorewallet":
+		err = cli.handleRestoreWallet(nodeID, args[1:])
 	case "send":
-		err = cli.handleSend(nodeID, os.Args[2:])
+		err = cli.handleSend(nodeID, args[1:])
+	case "sendmany":
+		err = cli.handleSendMany(nodeID, args[1:])
+	case "rpc":
+		err = cli.handleRPC(nodeID, args[1:])
+	case "sendrawtx":
+		err = cli.handleSendRawTx(nodeID, args[1:])
+	case "setban":
+		err = cli.handleSetBan(nodeID, args[1:])
+	case "signrawtx":
+		err = cli.handleSignRawTx(nodeID, args[1:])
 	case "startnode":
-		err = cli.handleStartNode(nodeID, os.Args[2:])
+		err = cli.handleStartNode(nodeID, args[1:])
+	case "walletlock":
+		err = cli.handleWalletLock(nodeID, args[1:])
+	case "walletpassphrase":
+		err = cli.handleWalletPassphrase(nodeID, args[1:])
 	default:
 		cli.printUsageAndExit()
 	}
@@ -78,6 +257,17 @@ func (cli *CLI) Run() {
 	}
 }
 
+func (cli *CLI) handleChangePassphrase(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("changepassphrase", flag.ExitOnError)
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	cli.print(changePassphrase(nodeID))
+	return nil
+}
+
 func (cli *CLI) handleCreateBlockchain(nodeID string, flags []string) error {
 	cmd := flag.NewFlagSet("createblockchain", flag.ExitOnError)
 	addr := cmd.String("addr", "", "The address to send the genesis block reward to")
@@ -91,18 +281,111 @@ func (cli *CLI) handleCreateBlockchain(nodeID string, flags []string) error {
 		os.Exit(1)
 	}
 
-	createBlockchain(nodeID, *addr)
+	cli.print(createBlockchain(nodeID, *addr))
+	return nil
+}
+
+func (cli *CLI) handleCreateRawTx(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("createrawtx", flag.ExitOnError)
+	from := cmd.String("from", "", "The comma-separated source addresses to send coins from")
+	to := cmd.String("to", "", "The destination address to send coins to")
+	amount := cmd.Int("amount", 0, "The amount of coins to send")
+	file := cmd.String("file", "", "The CSV or JSON file of the recipients instead of -to and -amount")
+	change := cmd.String("change", "", "The address to get the change back")
+	fee := cmd.Int("fee", 0, "The fee left to the miner")
+	coinSelect := cmd.String("coinselect", node.DefaultCoinSelector, "The coin selection strategy: bnb, largest, smallest or random")
+	utxo := cmd.String("utxo", "", "The comma-separated <txid>:<vout> outpoints to spend first")
+	rbf := cmd.Bool("rbf", false, "The replace-by-fee flag to let the transaction be replaced in the mempool")
+	out := cmd.String("out", "", "The file to save the unsigned transaction into")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *from == "" || *out == "" || (*file == "" && (*to == "" || *amount <= 0)) || *fee < 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	coinControl, err := parseCoinControl(*coinSelect, *utxo)
+	if err != nil {
+		fmt.Println(err)
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	recipients := []node.Recipient{{Addr: *to, Amount: *amount}}
+	if *file != "" {
+		if recipients, err = readRecipients(*file); err != nil {
+			return err
+		}
+	}
+
+	cli.print(createRawTx(nodeID, strings.Split(*from, ","), recipients, *change, *fee, coinControl, *rbf, *out))
 	return nil
 }
 
 func (cli *CLI) handleCreateWallet(nodeID string, flags []string) error {
 	cmd := flag.NewFlagSet("createwallet", flag.ExitOnError)
+	mnemonic := cmd.Bool("mnemonic", false, "The mnemonic flag to derive the key-pair from the HD wallet seed")
+	keyType := cmd.String("keytype", node.DefaultKeyType.String(), "The key type of the key-pair: secp256k1 or p256")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	kt, err := node.ParseKeyType(*keyType)
+	if err != nil {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(createWallet(nodeID, *mnemonic, kt))
+	return nil
+}
+
+func (cli *CLI) handleDumpPrivKey(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("dumpprivkey", flag.ExitOnError)
+	addr := cmd.String("addr", "", "The address to export the private key of")
 
 	if err := cmd.Parse(flags); err != nil {
 		return err
 	}
 
-	createWallet(nodeID)
+	if *addr == "" {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(dumpPrivKey(nodeID, *addr))
+	return nil
+}
+
+func (cli *CLI) handleDumpPubKey(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("dumppubkey", flag.ExitOnError)
+	addr := cmd.String("addr", "", "The address to export the public key of")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *addr == "" {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(dumpPubKey(nodeID, *addr))
+	return nil
+}
+
+func (cli *CLI) handleEncryptWallet(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("encryptwallet", flag.ExitOnError)
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	cli.print(encryptWallet(nodeID))
 	return nil
 }
 
@@ -119,7 +402,77 @@ func (cli *CLI) handleGetBalance(nodeID string, flags []string) error {
 		os.Exit(1)
 	}
 
-	getBalance(nodeID, *addr)
+	cli.print(getBalance(nodeID, *addr))
+	return nil
+}
+
+func (cli *CLI) handleHistory(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("history", flag.ExitOnError)
+	addr := cmd.String("addr", "", "The address to list the transactions of")
+	page := cmd.Int("page", 1, "The page number starting from 1")
+	size := cmd.Int("size", 10, "The number of the transactions in a page")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *addr == "" || *page < 1 || *size < 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(history(nodeID, *addr, *page, *size))
+	return nil
+}
+
+func (cli *CLI) handleImportAddress(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("importaddr", flag.ExitOnError)
+	addr := cmd.String("addr", "", "The address to watch")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *addr == "" {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(importAddress(nodeID, *addr))
+	return nil
+}
+
+func (cli *CLI) handleImportPrivKey(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("importprivkey", flag.ExitOnError)
+	key := cmd.String("key", "", "The private key exported by dumpprivkey")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *key == "" {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(importPrivKey(nodeID, *key))
+	return nil
+}
+
+func (cli *CLI) handleImportPubKey(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("importpubkey", flag.ExitOnError)
+	pkey := cmd.String("pkey", "", "The hex-encoded public key to watch")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *pkey == "" {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(importPubKey(nodeID, *pkey))
 	return nil
 }
 
@@ -130,18 +483,72 @@ func (cli *CLI) handleListAddresses(nodeID string, flags []string) error {
 		return err
 	}
 
-	listAddresses(nodeID)
+	cli.print(listAddresses(nodeID))
+	return nil
+}
+
+func (cli *CLI) handleListBanned(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("listbanned", flag.ExitOnError)
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	cli.print(listBanned(nodeID))
+	return nil
+}
+
+func (cli *CLI) handleMine(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("mine", flag.ExitOnError)
+	nodeAddr := cmd.String("node", os.Getenv(rpcAddrEnv), "The JSON-RPC address of the node to get the works from")
+	miner := cmd.String("miner", "", "The address to send the block rewards to (default: the miner address of the node)")
+	blocks := cmd.Int("blocks", 0, "The number of the blocks to mine, or 0 to mine forever")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *nodeAddr == "" || *blocks < 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	mine(nodeID, *nodeAddr, *miner, *blocks)
 	return nil
 }
 
 func (cli *CLI) handlePrintChain(nodeID string, flags []string) error {
 	cmd := flag.NewFlagSet("printchain", flag.ExitOnError)
+	from := cmd.Int("from", 0, "The lowest height of the blocks to print")
+	to := cmd.Int("to", -1, "The highest height of the blocks to print (default: the best height)")
+	limit := cmd.Int("limit", 0, "The maximum number of the blocks to print (default: no limit)")
+	hash := cmd.String("hash", "", "The hash of the block to print")
+	txid := cmd.String("txid", "", "The ID of the transaction to print with its block")
+	headersOnly := cmd.Bool("headers-only", false, "The flag to omit the transactions")
+	asc := cmd.Bool("asc", false, "The flag to print in the ascending order of the heights")
+	verify := cmd.Bool("verify", false, "The flag to verify the proof of work, the merkle root and the signatures")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *from < 0 || *limit < 0 || (*hash != "" && *txid != "") {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(printChain(nodeID, printChainOptions{*from, *to, *limit, *hash, *txid, *headersOnly, *asc, *verify}))
+	return nil
+}
+
+func (cli *CLI) handleReindexHistory(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("reindexhistory", flag.ExitOnError)
 
 	if err := cmd.Parse(flags); err != nil {
 		return err
 	}
 
-	printChain(nodeID)
+	cli.print(reindexHistory(nodeID))
 	return nil
 }
 
@@ -152,7 +559,43 @@ func (cli *CLI) handleReindexUTXO(nodeID string, flags []string) error {
 		return err
 	}
 
-	reindexUTXO(nodeID)
+	cli.print(reindexUTXO(nodeID))
+	return nil
+}
+
+func (cli *CLI) handleRestoreWallet(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("restorewallet", flag.ExitOnError)
+	mnemonic := cmd.String("mnemonic", "", "The mnemonic to restore the HD wallet from")
+	keyType := cmd.String("keytype", node.DefaultKeyType.String(), "The key type of the HD wallet: secp256k1 or p256")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	kt, err := node.ParseKeyType(*keyType)
+	if *mnemonic == "" || err != nil {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(restoreWallet(nodeID, *mnemonic, kt))
+	return nil
+}
+
+func (cli *CLI) handleRPC(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("rpc", flag.ExitOnError)
+	addr := cmd.String("addr", os.Getenv(rpcAddrEnv), "The JSON-RPC address of the node")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *addr == "" || cmd.NArg() < 1 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(callRPC(nodeID, *addr, cmd.Arg(0), cmd.Args()[1:]))
 	return nil
 }
 
@@ -161,33 +604,222 @@ func (cli *CLI) handleSend(nodeID string, flags []string) error {
 	from := cmd.String("from", "", "The source address to send coins from")
 	to := cmd.String("to", "", "The destination address to send coins to")
 	amount := cmd.Int("amount", 0, "The amount of coins to send")
+	fee := cmd.Int("fee", 0, "The fee left to the miner")
 	mine := cmd.Bool("mine", false, "The mine flag to decide whether mining immediately on the same node.")
+	coinSelect := cmd.String("coinselect", node.DefaultCoinSelector, "The coin selection strategy: bnb, largest, smallest or random")
+	utxo := cmd.String("utxo", "", "The comma-separated <txid>:<vout> outpoints to spend first")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *from == "" || *to == "" || *amount <= 0 || *fee < 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	coinControl, err := parseCoinControl(*coinSelect, *utxo)
+	if err != nil {
+		fmt.Println(err)
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(send(nodeID, *from, *to, *amount, *fee, *mine, coinControl))
+	return nil
+}
+
+func (cli *CLI) handleSendMany(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("sendmany", flag.ExitOnError)
+	from := cmd.String("from", "", "The comma-separated source addresses to send coins from")
+	file := cmd.String("file", "", "The CSV or JSON file of the recipients")
+	fee := cmd.Int("fee", 0, "The fee left to the miner")
+	mine := cmd.Bool("mine", false, "The mine flag to decide whether mining immediately on the same node.")
+	coinSelect := cmd.String("coinselect", node.DefaultCoinSelector, "The coin selection strategy: bnb, largest, smallest or random")
+	utxo := cmd.String("utxo", "", "The comma-separated <txid>:<vout> outpoints to spend first")
+	nodeAddr := cmd.String("node", defaultNodeAddr(), "The node address to send the transaction to without -mine")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *from == "" || *file == "" || *fee < 0 {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	coinControl, err := parseCoinControl(*coinSelect, *utxo)
+	if err != nil {
+		fmt.Println(err)
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(sendMany(nodeID, strings.Split(*from, ","), *file, *fee, *mine, *nodeAddr, coinControl))
+	return nil
+}
+
+func (cli *CLI) handleSendRawTx(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("sendrawtx", flag.ExitOnError)
+	in := cmd.String("in", "", "The file of the signed transaction")
+	miner := cmd.String("miner", "", "The miner address to mine on the same node and send the block reward to")
+	nodeAddr := cmd.String("node", defaultNodeAddr(), "The node address to send the transaction to")
+	peerID := cmd.String("peerid", "", "The peer ID of the node of the secure transport to send the transaction over TLS to")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *in == "" {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(sendRawTx(nodeID, *in, *miner, *nodeAddr, *peerID))
+	return nil
+}
+
+func (cli *CLI) handleSetBan(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("setban", flag.ExitOnError)
+	host := cmd.String("host", "", "The host or address to ban")
+	duration := cmd.Duration("duration", banman.BanDuration, "The duration of the ban, such as 30m or 24h")
+	remove := cmd.Bool("remove", false, "The flag to lift the ban instead")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *host == "" || *duration < time.Second {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(setBan(nodeID, *host, *remove, *duration))
+	return nil
+}
+
+func (cli *CLI) handleSignRawTx(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("signrawtx", flag.ExitOnError)
+	in := cmd.String("in", "", "The file of the transaction to sign")
+	out := cmd.String("out", "", "The file to save the signed transaction into")
 
 	if err := cmd.Parse(flags); err != nil {
 		return err
 	}
 
-	if *from == "" || *to == "" || *amount <= 0 {
+	if *in == "" {
 		cmd.Usage()
 		os.Exit(1)
 	}
 
-	send(nodeID, *from, *to, *amount, *mine)
+	if *out == "" {
+		*out = *in
+	}
+
+	cli.print(signRawTx(nodeID, *in, *out))
 	return nil
 }
 
 func (cli *CLI) handleStartNode(nodeID string, flags []string) error {
 	cmd := flag.NewFlagSet("startnode", flag.ExitOnError)
+	listen := cmd.String("listen", "", "The address to listen on (default: localhost:<NODE_ID>)")
+	external := cmd.String("external", "", "The address advertised to the peers (default: the listen address)")
+	seeds := cmd.String("seeds", defaultNodeAddr(), "The comma-separated peer addresses to connect to first")
 	miner := cmd.String("miner", "", "The miner address to enables mining and send the block reward to")
+	emptyBlocks := cmd.Bool("emptyblocks", false, "The flag to mine the blocks without a transaction too")
+	blocksOnly := cmd.Bool("blocksonly", false, "The flag not to accept and relay the transactions from the peers")
+	rpcAddr := cmd.String("rpc", "", "The address to serve JSON-RPC on, such as localhost:8332")
+	rpcAuth := cmd.String("rpcauth", "", "The <user>:<password> required by JSON-RPC (default: a random password in the cookie file)")
+	explorerAddr := cmd.String("explorer", "", "The address to serve the block explorer on, such as :8080")
+	secure := cmd.Bool("secure", false, "The flag to talk to the peers over TLS authenticated by the node keys")
+	allow := cmd.String("allow", "", "The comma-separated peer IDs only accepted and dialed, which implies -secure")
 
 	if err := cmd.Parse(flags); err != nil {
 		return err
 	}
 
-	startNode(nodeID, *miner)
+	var seedList []string
+	if *seeds != "" {
+		seedList = strings.Split(*seeds, ",")
+	}
+
+	var allowList []string
+	if *allow != "" {
+		allowList = strings.Split(*allow, ",")
+	}
+
+	startNode(server.Config{
+		NodeID:       nodeID,
+		ListenAddr:   *listen,
+		ExternalAddr: *external,
+		Seeds:        seedList,
+		Miner:        *miner,
+		EmptyBlocks:  *emptyBlocks,
+		BlocksOnly:   *blocksOnly,
+		RPCAddr:      *rpcAddr,
+		RPCAuth:      *rpcAuth,
+		ExplorerAddr: *explorerAddr,
+		Secure:       *secure,
+		AllowedPeers: allowList,
+	})
+	return nil
+}
+
+func (cli *CLI) handleWalletLock(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("walletlock", flag.ExitOnError)
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	cli.print(walletLock(nodeID))
 	return nil
 }
 
+func (cli *CLI) handleWalletPassphrase(nodeID string, flags []string) error {
+	cmd := flag.NewFlagSet("walletpassphrase", flag.ExitOnError)
+	timeout := cmd.Duration("timeout", defaultUnlockTimeout, "The duration to unlock the wallet for, such as 30s or 5m")
+
+	if err := cmd.Parse(flags); err != nil {
+		return err
+	}
+
+	if *timeout < time.Second {
+		cmd.Usage()
+		os.Exit(1)
+	}
+
+	cli.print(walletPassphrase(nodeID, *timeout))
+	return nil
+}
+
+// defaultNodeAddr returns the local address on the default port of the network.
+func defaultNodeAddr() string {
+	return fmt.Sprintf("localhost:%d", node.Params().DefaultPort)
+}
+
+func parseCoinControl(coinSelect, utxo string) (*node.CoinControl, error) {
+	selector, err := node.NewCoinSelector(coinSelect)
+	if err != nil {
+		return nil, err
+	}
+
+	coinControl := &node.CoinControl{Selector: selector}
+	if utxo == "" {
+		return coinControl, nil
+	}
+
+	for _, s := range strings.Split(utxo, ",") {
+		op, err := node.ParseOutpoint(s)
+		if err != nil {
+			return nil, err
+		}
+		coinControl.Pinned = append(coinControl.Pinned, op)
+	}
+
+	return coinControl, nil
+}
+
 func NewCLI() *CLI {
 	return &CLI{}
 }
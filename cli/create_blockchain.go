@@ -1,12 +1,21 @@
 package cli
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/hansung080/gchain/node"
 )
 
-func createBlockchain(nodeID, addr string) {
+type createBlockchainResult struct {
+	Genesis string `json:"genesis"` // the genesis block hash
+}
+
+func (r createBlockchainResult) printText() {
+	fmt.Println(r.Genesis)
+}
+
+func createBlockchain(nodeID, addr string) result {
 	if !node.ValidateAddress(addr) {
 		log.Panicf("Invalid address: %v\n", addr)
 	}
@@ -15,4 +24,11 @@ func createBlockchain(nodeID, addr string) {
 	defer bc.Close()
 
 	node.UTXOSet{bc}.Reindex()
+
+	genesis, err := bc.GetBlockByHeight(0)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return createBlockchainResult{fmt.Sprintf("%x", genesis.Hash)}
 }
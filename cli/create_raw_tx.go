@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hansung080/gchain/node"
+)
+
+func createRawTx(nodeID string, froms []string, recipients []node.Recipient, change string, fee int, coinControl *node.CoinControl, rbf bool, out string) result {
+	bc := node.NewBlockchain(nodeID)
+	defer bc.Close()
+	utxoSet := node.UTXOSet{bc}
+
+	wallets, err := loadWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	builder := node.NewTxBuilder(&utxoSet).SetChange(change).SetFee(fee).SetCoinControl(coinControl).SetReplaceable(rbf)
+	for _, from := range froms {
+		if !wallets.HasWallet(from) {
+			log.Panicf("Address not found in the wallet: %v\n", from)
+		}
+
+		wallet := wallets.GetWallet(from)
+		builder.AddSource(&wallet)
+	}
+
+	for _, r := range recipients {
+		builder.AddRecipient(r.Addr, r.Amount)
+	}
+
+	p, err := builder.BuildUnsigned()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if err := p.SaveFile(out); err != nil {
+		log.Panic(err)
+	}
+
+	return txidResult{fmt.Sprintf("%x", p.Tx.ID)}
+}
@@ -6,14 +6,43 @@ import (
 	"github.com/hansung080/gchain/node"
 )
 
-func createWallet(nodeID string) {
-	wallets, err := node.NewWallets(nodeID)
+type createWalletResult struct {
+	Addr     string `json:"addr"`
+	Mnemonic string `json:"mnemonic,omitempty"` // only when a new HD wallet seed is generated
+}
+
+func (r createWalletResult) printText() {
+	if r.Mnemonic != "" {
+		fmt.Println("Write down the mnemonic below, and keep it safe to restore the wallet.")
+		fmt.Printf("mnemonic: %s\n", r.Mnemonic)
+	}
+	fmt.Println(r.Addr)
+}
+
+func createWallet(nodeID string, mnemonic bool, kt node.KeyType) result {
+	wallets, err := loadWallets(nodeID)
 	if err != nil {
-		fmt.Printf("Wallet creation failure: %s\n", err.Error())
-		return
+		return newFailure("Wallet creation", err)
+	}
+
+	var r createWalletResult
+	if mnemonic {
+		if !wallets.IsHD() {
+			words := node.NewMnemonic()
+			if err := wallets.SetMnemonic(words, kt); err != nil {
+				return newFailure("Wallet creation", err)
+			}
+			r.Mnemonic = words
+		}
+
+		r.Addr, err = wallets.CreateHDWallet(node.ReceiveChain)
+		if err != nil {
+			return newFailure("Wallet creation", err)
+		}
+	} else {
+		r.Addr = wallets.CreateWallet(kt)
 	}
 
-	addr := wallets.CreateWallet()
 	wallets.SaveFile(nodeID)
-	fmt.Println(addr)
+	return r
 }
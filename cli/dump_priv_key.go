@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hansung080/gchain/node"
+)
+
+type dumpPrivKeyResult struct {
+	Addr string `json:"addr"`
+	Key  string `json:"key"`
+}
+
+func (r dumpPrivKeyResult) printText() {
+	fmt.Println(r.Key)
+}
+
+func dumpPrivKey(nodeID, addr string) result {
+	wallets, err := loadWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if !wallets.HasWallet(addr) {
+		log.Panicf("Address not found in the wallet: %v\n", addr)
+	}
+
+	wallet := wallets.GetWallet(addr)
+	key, err := node.EncodeWIF(&wallet)
+	if err != nil {
+		return newFailure("Private key export", err)
+	}
+
+	return dumpPrivKeyResult{addr, key}
+}
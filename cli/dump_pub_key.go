@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+type dumpPubKeyResult struct {
+	Addr string `json:"addr"`
+	Pkey string `json:"pkey"`
+}
+
+func (r dumpPubKeyResult) printText() {
+	fmt.Println(r.Pkey)
+}
+
+func dumpPubKey(nodeID, addr string) result {
+	wallets, err := loadWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if !wallets.HasWallet(addr) {
+		log.Panicf("Address not found in the wallet: %v\n", addr)
+	}
+
+	wallet := wallets.GetWallet(addr)
+	if wallet.Pkey == nil {
+		return newFailure("Public key export", errors.New("Address is watched without the public key"))
+	}
+
+	return dumpPubKeyResult{addr, fmt.Sprintf("%x", wallet.Pkey)}
+}
@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"github.com/hansung080/gchain/node"
+)
+
+func encryptWallet(nodeID string) result {
+	wallets, err := node.NewWallets(nodeID)
+	if err != nil {
+		return newFailure("Wallet encryption", err)
+	}
+
+	passphrase, err := readNewPassphrase()
+	if err != nil {
+		return newFailure("Wallet encryption", err)
+	}
+
+	if err := wallets.Encrypt(passphrase); err != nil {
+		return newFailure("Wallet encryption", err)
+	}
+
+	wallets.SaveFile(nodeID)
+	return messageResult{"Wallet encrypted."}
+}
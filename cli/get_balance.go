@@ -7,11 +7,29 @@ import (
 	"github.com/hansung080/gchain/node"
 )
 
-func getBalance(nodeID, addr string) {
+type balanceResult struct {
+	Addr    string `json:"addr"`
+	Balance int    `json:"balance"`
+}
+
+func (r balanceResult) printText() {
+	fmt.Println(r.Balance)
+}
+
+func getBalance(nodeID, addr string) result {
 	if !node.ValidateAddress(addr) {
 		log.Panicf("Invalid address: %v\n", addr)
 	}
 
+	if client := rpcClient(nodeID); client != nil {
+		var balance int
+		if err := client.Call("getbalance", []interface{}{addr}, &balance); err != nil {
+			log.Panic(err)
+		}
+
+		return balanceResult{addr, balance}
+	}
+
 	bc := node.NewBlockchain(nodeID)
 	defer bc.Close()
 
@@ -23,5 +41,5 @@ func getBalance(nodeID, addr string) {
 		balance += out.Value
 	}
 
-	fmt.Println(balance)
+	return balanceResult{addr, balance}
 }
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/hansung080/gchain/node"
+)
+
+type historyResult struct {
+	Addr    string               `json:"addr"`
+	Page    int                  `json:"page"`
+	Pages   int                  `json:"pages"`
+	Total   int                  `json:"total"`
+	Entries []historyEntryResult `json:"entries"`
+}
+
+type historyEntryResult struct {
+	Txid          string `json:"txid"`
+	Direction     string `json:"direction"`
+	Amount        int    `json:"amount"`
+	Height        int    `json:"height"`
+	Confirmations int    `json:"confirmations"`
+}
+
+func (r historyResult) printText() {
+	fmt.Printf("Page %d of %d (%d entries)\n", r.Page, r.Pages, r.Total)
+	for _, e := range r.Entries {
+		fmt.Printf("%s %-8s %d (height: %d, confirmations: %d)\n", e.Txid, e.Direction, e.Amount, e.Height, e.Confirmations)
+	}
+}
+
+func history(nodeID, addr string, page, size int) result {
+	if !node.ValidateAddress(addr) {
+		log.Panicf("Invalid address: %v\n", addr)
+	}
+
+	bc := node.NewBlockchain(nodeID)
+	defer bc.Close()
+
+	index := node.HistoryIndex{bc}
+	if !index.Enabled() {
+		log.Panic("History index not found. Run reindexhistory first.")
+	}
+
+	pkeyHash := node.GetPkeyHashFromAddress([]byte(addr))
+	entries, total := index.History(pkeyHash, (page - 1) * size, size)
+	bestHeight := bc.GetBestHeight()
+
+	r := historyResult{Addr: addr, Page: page, Pages: (total + size - 1) / size, Total: total, Entries: []historyEntryResult{}}
+	for _, e := range entries {
+		r.Entries = append(r.Entries, historyEntryResult{
+			Txid:          fmt.Sprintf("%x", e.Txid),
+			Direction:     e.Direction.String(),
+			Amount:        e.Amount,
+			Height:        e.Height,
+			Confirmations: bestHeight - e.Height + 1,
+		})
+	}
+
+	return r
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"log"
+
+	"github.com/hansung080/gchain/node"
+)
+
+func importAddress(nodeID, addr string) result {
+	wallet, err := node.NewWatchOnlyWallet(addr)
+	if err != nil {
+		return newFailure("Address import", err)
+	}
+
+	wallets, err := loadWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallets.ImportWallet(wallet)
+	wallets.SaveFile(nodeID)
+	return addrResult{addr}
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"log"
+
+	"github.com/hansung080/gchain/node"
+)
+
+func importPrivKey(nodeID, key string) result {
+	wallet, err := node.DecodeWIF(key)
+	if err != nil {
+		return newFailure("Private key import", err)
+	}
+
+	wallets, err := loadWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	addr := wallets.ImportWallet(wallet)
+	wallets.SaveFile(nodeID)
+	return addrResult{addr}
+}
@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"encoding/hex"
+	"log"
+
+	"github.com/hansung080/gchain/node"
+)
+
+func importPubKey(nodeID, pkeyHex string) result {
+	pkey, err := hex.DecodeString(pkeyHex)
+	if err != nil {
+		return newFailure("Public key import", err)
+	}
+
+	wallet, err := node.NewPkeyWallet(pkey)
+	if err != nil {
+		return newFailure("Public key import", err)
+	}
+
+	wallets, err := loadWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	addr := wallets.ImportWallet(wallet)
+	wallets.SaveFile(nodeID)
+	return addrResult{addr}
+}
@@ -3,18 +3,68 @@ package cli
 import (
 	"log"
 	"fmt"
+	"sort"
 
 	"github.com/hansung080/gchain/node"
 )
 
-func listAddresses(nodeID string) {
-	wallets, err := node.NewWallets(nodeID)
+type listAddrResult struct {
+	Addrs []addrEntryResult `json:"addrs"`
+}
+
+type addrEntryResult struct {
+	Addr      string `json:"addr"`
+	Balance   *int   `json:"balance,omitempty"` // nil without the blockchain
+	WatchOnly bool   `json:"watchonly"`
+}
+
+func (r listAddrResult) printText() {
+	for _, a := range r.Addrs {
+		line := a.Addr
+		if a.Balance != nil {
+			line += fmt.Sprintf(" %d", *a.Balance)
+		}
+
+		if a.WatchOnly {
+			line += " (watch-only)"
+		}
+
+		fmt.Println(line)
+	}
+}
+
+func listAddresses(nodeID string) result {
+	wallets, err := loadWallets(nodeID)
 	if err != nil {
 		log.Panic(err)
 	}
 
 	addrs := wallets.GetAddresses()
+	sort.Strings(addrs)
+
+	// balances are shown only when the blockchain exists, because a wallet could be created before it.
+	var utxoSet *node.UTXOSet
+	if node.BlockchainExists(nodeID) {
+		bc := node.NewBlockchain(nodeID)
+		defer bc.Close()
+		utxoSet = &node.UTXOSet{bc}
+	}
+
+	r := listAddrResult{Addrs: []addrEntryResult{}}
 	for _, addr := range addrs {
-		fmt.Println(addr)
+		wallet := wallets.GetWallet(addr)
+		entry := addrEntryResult{Addr: addr, WatchOnly: wallet.IsWatchOnly()}
+
+		if utxoSet != nil {
+			balance := 0
+			for _, out := range utxoSet.FindUTXOs(wallet.GetPkeyHash()) {
+				balance += out.Value
+			}
+			entry.Balance = &balance
+		}
+
+		r.Addrs = append(r.Addrs, entry)
 	}
+
+	return r
 }
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hansung080/gchain/net/banman"
+	"github.com/hansung080/gchain/net/rpc"
+	"github.com/hansung080/gchain/net/server"
+)
+
+type listBannedResult struct {
+	Bans []rpc.BanResult `json:"bans"`
+}
+
+func (r listBannedResult) printText() {
+	for _, ban := range r.Bans {
+		fmt.Printf("%s until %s: %s\n", ban.Host, time.Unix(ban.Until, 0).Format(time.RFC3339), ban.Reason)
+	}
+}
+
+func listBanned(nodeID string) result {
+	r := listBannedResult{Bans: []rpc.BanResult{}}
+	if client := rpcClient(nodeID); client != nil {
+		if err := client.Call("listbanned", nil, &r.Bans); err != nil {
+			log.Panic(err)
+		}
+
+		return r
+	}
+
+	banMgr, err := banman.New(server.BansFile(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, ban := range banMgr.Banned() {
+		r.Bans = append(r.Bans, rpc.NewBanResult(ban))
+	}
+
+	return r
+}
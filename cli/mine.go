@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/hansung080/gchain/net/rpc"
+	"github.com/hansung080/gchain/node"
+)
+
+// workRefresh is the time after which a new work is requested, to mine on the new tip and the new transactions.
+const workRefresh = 10 * time.Second
+
+// mine mines the works of the node at addr as an external miner, until the blocks are mined, or forever if blocks is 0.
+func mine(nodeID, addr, miner string, blocks int) {
+	// the mined blocks are reported, instead of the progress of the proof of work.
+	node.MiningOutput = io.Discard
+
+	client := newRPCClient(nodeID, addr)
+	for mined := 0; blocks == 0 || mined < blocks; {
+		var params []interface{}
+		if miner != "" {
+			params = append(params, miner)
+		}
+
+		var work rpc.WorkResult
+		if err := client.Call("getwork", params, &work); err != nil {
+			log.Panic(err)
+		}
+
+		header, err := hex.DecodeString(work.Header)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		target, ok := new(big.Int).SetString(work.Target, 16)
+		if !ok {
+			log.Panicf("Invalid target: %s", work.Target)
+		}
+
+		abort := make(chan struct{})
+		timer := time.AfterFunc(workRefresh, func() { close(abort) })
+		nonce, _, found := node.FindNonce(header, target, abort)
+		timer.Stop()
+		if !found {
+			continue
+		}
+
+		// a stale block is rejected when another block is mined first, so the miner goes on.
+		var hash string
+		if err := client.Call("submitblock", []interface{}{work.WorkID, nonce}, &hash); err != nil {
+			fmt.Printf("Mined block at height %d is rejected: %s\n", work.Height, err)
+			continue
+		}
+
+		mined++
+		fmt.Printf("Mined a new block %s at height %d with %d transactions.\n", hash, work.Height, work.Txs)
+	}
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+/**
+  @ Output
+    gchain -output <format> <command> <flag>...
+    - text: human-readable lines (default)
+    - json: the typed result of the command as stable JSON. A failure is {"error": "<message>"}.
+      A command panicking on a failure is recovered, and printed as a failure too, instead of the stack trace.
+*/
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// result is the typed result of a command, printed as text or JSON.
+type result interface {
+	printText()
+}
+
+type messageResult struct {
+	Message string `json:"message"`
+}
+
+func (r messageResult) printText() {
+	fmt.Println(r.Message)
+}
+
+type addrResult struct {
+	Addr string `json:"addr"`
+}
+
+func (r addrResult) printText() {
+	fmt.Println(r.Addr)
+}
+
+type txidResult struct {
+	Txid string `json:"txid"`
+}
+
+func (r txidResult) printText() {
+	fmt.Println(r.Txid)
+}
+
+// failer is implemented by the result which exits with 1 after printed, when it failed.
+type failer interface {
+	failed() bool
+}
+
+type failureResult struct {
+	Error string `json:"error"`
+}
+
+func (r failureResult) printText() {
+	fmt.Println(r.Error)
+}
+
+func (r failureResult) failed() bool {
+	return true
+}
+
+func newFailure(action string, err error) failureResult {
+	return failureResult{Error: fmt.Sprintf("%s failure: %s", action, err.Error())}
+}
+
+// recoverFailure recovers the panic of a command, and prints it as a failure under -output json.
+// The panic goes on under -output text, as it did before the output format.
+func (cli *CLI) recoverFailure() {
+	if cli.output != outputJSON {
+		return
+	}
+
+	if r := recover(); r != nil {
+		cli.print(failureResult{Error: strings.TrimSpace(fmt.Sprint(r))})
+	}
+}
+
+func (cli *CLI) print(r result) {
+	if cli.output == outputJSON {
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			log.Panic(err)
+		}
+		fmt.Println(string(data))
+	} else {
+		r.printText()
+	}
+
+	if f, ok := r.(failer); ok && f.failed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultJSON(t *testing.T) {
+	balance := 0
+	for _, c := range []struct {
+		r        result
+		expected string
+	}{
+		{txidResult{"ab"}, `{"txid":"ab"}`},
+		{rpcResult{json.RawMessage(`[1, 2]`)}, `[1,2]`},
+		{newFailure("Address import", assert.AnError), `{"error":"Address import failure: ` + assert.AnError.Error() + `"}`},
+		{
+			listAddrResult{[]addrEntryResult{{Addr: "a"}, {Addr: "b", Balance: &balance, WatchOnly: true}}},
+			`{"addrs":[{"addr":"a","watchonly":false},{"addr":"b","balance":0,"watchonly":true}]}`,
+		},
+	} {
+		data, err := json.Marshal(c.r)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, string(data))
+	}
+}
+
+func TestRecoverFailure(t *testing.T) {
+	// the command panicking runs in a subprocess, because the failure exits.
+	if os.Getenv("GCHAIN_TEST_PANIC") != "" {
+		cli := &CLI{output: outputJSON}
+		defer cli.recoverFailure()
+		log.SetOutput(io.Discard)
+		log.Panicf("Invalid address: %v\n", os.Getenv("GCHAIN_TEST_PANIC"))
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRecoverFailure$")
+	cmd.Env = append(os.Environ(), "GCHAIN_TEST_PANIC=abc")
+	out, err := cmd.Output()
+	if exitErr, ok := err.(*exec.ExitError); assert.True(t, ok) {
+		assert.Equal(t, 1, exitErr.ExitCode())
+	}
+	assert.JSONEq(t, `{"error":"Invalid address: abc"}`, string(out))
+}
@@ -1,27 +1,175 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
+	"log"
 	"strconv"
 
+	"github.com/hansung080/gchain/net/rpc"
 	"github.com/hansung080/gchain/node"
 )
 
-func printChain(nodeID string) {
+type printChainOptions struct {
+	from        int
+	to          int // the best height if negative
+	limit       int // no limit if 0
+	hash        string
+	txid        string
+	headersOnly bool
+	asc         bool
+	verify      bool
+}
+
+type printChainResult struct {
+	Blocks   []chainBlockResult `json:"blocks"`
+	Verified bool               `json:"verified"`
+	Failed   int                `json:"failed"` // the number of the blocks failed to verify
+}
+
+type chainBlockResult struct {
+	rpc.BlockResult
+	TxCount  int                 `json:"txcount"`
+	PoW      bool                `json:"pow"`
+	Failures []string            `json:"failures,omitempty"`
+	txs      []*node.Transaction // for the text
+}
+
+func (r printChainResult) printText() {
+	for _, b := range r.Blocks {
+		fmt.Printf(" @ Block %d\n", b.Height)
+		fmt.Printf(" - prev. hash: %s\n", b.PrevHash)
+		fmt.Printf(" - hash: %s\n", b.Hash)
+		fmt.Printf(" - pow: %s\n", strconv.FormatBool(b.PoW))
+		fmt.Printf(" - txs: %d\n", b.TxCount)
+		if r.Verified {
+			if len(b.Failures) == 0 {
+				fmt.Println(" - verify: ok")
+			} else {
+				fmt.Println(" - verify: failed")
+			}
+			for _, failure := range b.Failures {
+				fmt.Printf("   ! %s\n", failure)
+			}
+		}
+		for _, tx := range b.txs {
+			fmt.Println(tx)
+		}
+		fmt.Println()
+	}
+
+	if r.Verified {
+		fmt.Printf("%d blocks verified, %d failed\n", len(r.Blocks), r.Failed)
+	}
+}
+
+func (r printChainResult) failed() bool {
+	return r.Failed > 0
+}
+
+func printChain(nodeID string, opts printChainOptions) result {
 	bc := node.NewBlockchain(nodeID)
 	defer bc.Close()
 
+	bestHeight := bc.GetBestHeight()
+	r := printChainResult{Blocks: []chainBlockResult{}, Verified: opts.verify}
+
+	var txid []byte
+	if opts.txid != "" {
+		var err error
+		if txid, err = hex.DecodeString(opts.txid); err != nil {
+			log.Panicf("Invalid transaction ID: %v\n", opts.txid)
+		}
+	}
+
+	for _, block := range findBlocks(bc, opts, txid, bestHeight) {
+		b := chainBlockResult{
+			BlockResult: rpc.NewBlockResult(block, bestHeight),
+			TxCount:     len(block.Txs),
+			PoW:         node.NewProofOfWork(block).Validate(),
+		}
+
+		// only the transaction of -txid is shown in its block.
+		allTxs := b.Txs
+		b.Txs = []rpc.TxResult{}
+		for i, tx := range block.Txs {
+			if opts.headersOnly || (txid != nil && !bytes.Equal(tx.ID, txid)) {
+				continue
+			}
+			b.Txs = append(b.Txs, allTxs[i])
+			b.txs = append(b.txs, tx)
+		}
+
+		if opts.verify {
+			for _, err := range bc.VerifyBlock(block) {
+				b.Failures = append(b.Failures, err.Error())
+			}
+			if len(b.Failures) > 0 {
+				r.Failed++
+			}
+		}
+
+		r.Blocks = append(r.Blocks, b)
+	}
+
+	return r
+}
+
+// findBlocks finds the block of -hash, the block including -txid,
+// or the blocks from -from to -to up to -limit in the descending or ascending order.
+func findBlocks(bc *node.Blockchain, opts printChainOptions, txid []byte, bestHeight int) []*node.Block {
+	if opts.hash != "" {
+		hash, err := hex.DecodeString(opts.hash)
+		if err != nil {
+			log.Panicf("Invalid block hash: %v\n", opts.hash)
+		}
+
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			log.Panic(err)
+		}
+		return []*node.Block{&block}
+	}
+
+	if txid != nil {
+		_, block, err := bc.FindTxWithBlock(txid)
+		if err != nil {
+			log.Panic(err)
+		}
+		return []*node.Block{block}
+	}
+
+	to := opts.to
+	if to < 0 || to > bestHeight {
+		to = bestHeight
+	}
+
+	var blocks []*node.Block
 	iter := bc.Iterator()
 	for iter.HasNext() {
 		block := iter.Next()
+		if block.Height < opts.from {
+			break
+		}
+		if block.Height > to {
+			continue
+		}
 
-		fmt.Printf(" @ Block %d\n", block.Height)
-		fmt.Printf(" - prev. hash: %x\n", block.PrevHash)
-		fmt.Printf(" - hash: %x\n", block.Hash)
-		fmt.Printf(" - pow: %s\n", strconv.FormatBool(node.NewProofOfWork(block).Validate()))
-		for _, tx := range block.Txs {
-			fmt.Println(tx)
+		blocks = append(blocks, block)
+		if !opts.asc && opts.limit > 0 && len(blocks) == opts.limit {
+			break
+		}
+	}
+
+	if opts.asc {
+		for i, j := 0, len(blocks) - 1; i < j; i, j = i + 1, j - 1 {
+			blocks[i], blocks[j] = blocks[j], blocks[i]
+		}
+		if opts.limit > 0 && len(blocks) > opts.limit {
+			blocks = blocks[:opts.limit]
 		}
-		fmt.Println()
 	}
+
+	return blocks
 }
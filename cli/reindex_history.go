@@ -0,0 +1,13 @@
+package cli
+
+import (
+	"github.com/hansung080/gchain/node"
+)
+
+func reindexHistory(nodeID string) result {
+	bc := node.NewBlockchain(nodeID)
+	defer bc.Close()
+
+	node.HistoryIndex{bc}.Reindex()
+	return messageResult{"History index rebuilt."}
+}
@@ -6,12 +6,19 @@ import (
 	"github.com/hansung080/gchain/node"
 )
 
-func reindexUTXO(nodeID string) {
+type reindexUTXOResult struct {
+	Txs int `json:"txs"` // the number of the transactions in the UTXO set
+}
+
+func (r reindexUTXOResult) printText() {
+	fmt.Printf("%d txs in UTXO set\n", r.Txs)
+}
+
+func reindexUTXO(nodeID string) result {
 	bc := node.NewBlockchain(nodeID)
 	defer bc.Close()
 
 	utxoSet := node.UTXOSet{bc}
 	utxoSet.Reindex()
-	count := utxoSet.CountTxs()
-	fmt.Printf("%d txs in UTXO set\n", count)
+	return reindexUTXOResult{utxoSet.CountTxs()}
 }
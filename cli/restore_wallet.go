@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/hansung080/gchain/node"
+)
+
+type restoreWalletResult struct {
+	Addrs []string `json:"addrs"`
+}
+
+func (r restoreWalletResult) printText() {
+	for _, addr := range r.Addrs {
+		fmt.Println(addr)
+	}
+	fmt.Printf("%d addresses restored\n", len(r.Addrs))
+}
+
+func restoreWallet(nodeID, mnemonic string, kt node.KeyType) result {
+	wallets, err := loadWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// the HD wallet is restored again only from its own mnemonic, not to replace the seed of its addresses.
+	if wallets.IsHD() {
+		seed, err := node.NewSeedFromMnemonic(mnemonic, "")
+		if err != nil {
+			return newFailure("Wallet restoration", err)
+		}
+
+		if !bytes.Equal(seed, wallets.Seed) || kt != wallets.KeyType {
+			return newFailure("Wallet restoration", errors.New("Wallet already has a different HD seed"))
+		}
+	} else if err := wallets.SetMnemonic(mnemonic, kt); err != nil {
+		return newFailure("Wallet restoration", err)
+	}
+
+	bc := node.NewBlockchain(nodeID)
+	defer bc.Close()
+
+	addrs, err := wallets.Restore(&node.UTXOSet{bc})
+	if err != nil {
+		log.Panic(err)
+	}
+
+	wallets.SaveFile(nodeID)
+	return restoreWalletResult{append([]string{}, addrs...)}
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/hansung080/gchain/net/rpc"
+	"github.com/hansung080/gchain/node"
+)
+
+const (
+	rpcAddrEnv = "RPC_ADDR"
+	rpcAuthEnv = "RPC_AUTH"
+)
+
+var errNoRPCAddr = errors.New("RPC_ADDR env. var. is not set for the running node")
+
+// rpcClient returns the client of the node in RPC_ADDR env. var., or nil for the local blockchain.
+func rpcClient(nodeID string) *rpc.Client {
+	addr := os.Getenv(rpcAddrEnv)
+	if addr == "" {
+		return nil
+	}
+
+	return newRPCClient(nodeID, addr)
+}
+
+// newRPCClient returns the client of the node at addr with <user>:<password> in RPC_AUTH env. var.,
+// or with the cookie file of the node on the same host by default.
+func newRPCClient(nodeID, addr string) *rpc.Client {
+	var user, password string
+	var err error
+	if auth := os.Getenv(rpcAuthEnv); auth != "" {
+		user, password, err = rpc.SplitAuth(auth)
+	} else {
+		user, password, err = rpc.ReadCookie(node.Params().DataFile(rpc.CookieFile, nodeID))
+	}
+	if err != nil {
+		log.Panic(err)
+	}
+
+	client := rpc.NewClient(addr)
+	client.SetAuth(user, password)
+	return client
+}
+
+// rpcResult is the result of the method as it is.
+type rpcResult struct {
+	json.RawMessage
+}
+
+func (r rpcResult) printText() {
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, r.RawMessage, "", "  "); err != nil {
+		log.Panic(err)
+	}
+
+	fmt.Println(indented.String())
+}
+
+// callRPC calls the method with the args parsed as JSON, or as strings if not JSON.
+func callRPC(nodeID, addr, method string, args []string) result {
+	var params []interface{}
+	for _, arg := range args {
+		var param interface{}
+		if err := json.Unmarshal([]byte(arg), &param); err != nil {
+			param = arg
+		}
+		params = append(params, param)
+	}
+
+	var r rpcResult
+	if err := newRPCClient(nodeID, addr).Call(method, params, &r.RawMessage); err != nil {
+		log.Panic(err)
+	}
+
+	return r
+}
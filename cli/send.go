@@ -1,12 +1,13 @@
 package cli
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/hansung080/gchain/node"
 )
 
-func send(nodeID, from, to string, amount int, mine bool) {
+func send(nodeID, from, to string, amount, fee int, mine bool, coinControl *node.CoinControl) result {
 	if !node.ValidateAddress(from) {
 		log.Panicf("Invalid address: %v\n", from)
 	}
@@ -19,19 +20,51 @@ func send(nodeID, from, to string, amount int, mine bool) {
 	defer bc.Close()
 	utxoSet := node.UTXOSet{bc}
 
-	wallets, err := node.NewWallets(nodeID)
+	wallets, err := loadWallets(nodeID)
 	if err != nil {
 		log.Panic(err)
 	}
+	if !wallets.HasWallet(from) {
+		log.Panicf("Address not found in the wallet: %v\n", from)
+	}
+
 	wallet := wallets.GetWallet(from)
+	if wallet.IsWatchOnly() {
+		log.Panicf("Address is watch-only: %v\n", from)
+	}
+
+	// an HD wallet gets the change back to a new address of the change chain.
+	change := ""
+	if wallets.IsHD() {
+		change, err = wallets.CreateHDWallet(node.ChangeChain)
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	tx, err := node.NewTxBuilder(&utxoSet).
+		AddSource(&wallet).
+		AddRecipient(to, amount).
+		SetChange(change).
+		SetFee(fee).
+		SetCoinControl(coinControl).
+		Build()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if change != "" && len(tx.Vouts) > 1 {
+		wallets.SaveFile(nodeID)
+	}
 
-	tx := node.NewTransaction(&wallet, to, amount, &utxoSet)
 	if mine {
-		coinbase := node.NewCoinbaseTx(from, "")
+		coinbase := node.NewCoinbaseTxWithFee(from, "", bc.GetBestHeight() + 1, fee)
 		txs := []*node.Transaction{coinbase, tx}
 		block := bc.MineBlock(txs)
 		utxoSet.Update(block)
 	} else {
 		// TODO: send transaction to another node.
 	}
-}
\ No newline at end of file
+
+	return txidResult{fmt.Sprintf("%x", tx.ID)}
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hansung080/gchain/node"
+)
+
+func sendMany(nodeID string, froms []string, file string, fee int, mine bool, nodeAddr string, coinControl *node.CoinControl) result {
+	recipients, err := readRecipients(file)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	bc := node.NewBlockchain(nodeID)
+	defer bc.Close()
+	utxoSet := node.UTXOSet{bc}
+
+	wallets, err := loadWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	builder := node.NewTxBuilder(&utxoSet).SetFee(fee).SetCoinControl(coinControl)
+	for _, from := range froms {
+		if !wallets.HasWallet(from) {
+			log.Panicf("Address not found in the wallet: %v\n", from)
+		}
+
+		wallet := wallets.GetWallet(from)
+		builder.AddSource(&wallet)
+	}
+
+	for _, r := range recipients {
+		builder.AddRecipient(r.Addr, r.Amount)
+	}
+
+	// an HD wallet gets the change back to a new address of the change chain.
+	change := ""
+	if wallets.IsHD() {
+		change, err = wallets.CreateHDWallet(node.ChangeChain)
+		if err != nil {
+			log.Panic(err)
+		}
+		builder.SetChange(change)
+	}
+
+	tx, err := builder.Build()
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if change != "" && len(tx.Vouts) > len(recipients) {
+		wallets.SaveFile(nodeID)
+	}
+
+	if mine {
+		coinbase := node.NewCoinbaseTxWithFee(froms[0], "", bc.GetBestHeight() + 1, fee)
+		txs := []*node.Transaction{coinbase, tx}
+		block := bc.MineBlock(txs)
+		utxoSet.Update(block)
+	} else {
+		broadcastTx(nodeID, tx, nodeAddr, "")
+	}
+
+	return txidResult{fmt.Sprintf("%x", tx.ID)}
+}
+
+// readRecipients reads the recipients from the JSON file, when file has .json extension,
+// or from the CSV file of <address>,<amount> lines. The CSV header line is skipped if any.
+//   - JSON: [{"addr": "<address>", "amount": <amount>}, ...]
+func readRecipients(file string) ([]node.Recipient, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if strings.ToLower(filepath.Ext(file)) == ".json" {
+		var entries []struct {
+			Addr   string `json:"addr"`
+			Amount int    `json:"amount"`
+		}
+
+		if err := json.NewDecoder(f).Decode(&entries); err != nil {
+			return nil, err
+		}
+
+		var recipients []node.Recipient
+		for _, e := range entries {
+			recipients = append(recipients, node.Recipient{Addr: e.Addr, Amount: e.Amount})
+		}
+		return recipients, nil
+	}
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var recipients []node.Recipient
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		amount, err := strconv.Atoi(record[1])
+		if err != nil {
+			if line == 1 {
+				continue // header line
+			}
+			return nil, fmt.Errorf("Invalid amount at line %d: %s", line, record[1])
+		}
+
+		recipients = append(recipients, node.Recipient{Addr: record[0], Amount: amount})
+	}
+
+	return recipients, nil
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/hansung080/gchain/net/server"
+	"github.com/hansung080/gchain/node"
+)
+
+func sendRawTx(nodeID, in, miner, nodeAddr, peerID string) result {
+	p, err := node.LoadPartialTx(in)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	if !p.IsComplete() {
+		log.Panic("Transaction is not completely signed")
+	}
+
+	tx := &p.Tx
+	if miner != "" {
+		if !node.ValidateAddress(miner) {
+			log.Panicf("Invalid address: %v\n", miner)
+		}
+
+		bc := node.NewBlockchain(nodeID)
+		defer bc.Close()
+
+		coinbase := node.NewCoinbaseTxWithFee(miner, "", bc.GetBestHeight() + 1, p.Fee())
+		block := bc.MineBlock([]*node.Transaction{coinbase, tx})
+		node.UTXOSet{bc}.Update(block)
+	} else {
+		if !p.Verify() {
+			log.Panic("Transaction verification failure")
+		}
+
+		broadcastTx(nodeID, tx, nodeAddr, peerID)
+	}
+
+	return txidResult{fmt.Sprintf("%x", tx.ID)}
+}
+
+// broadcastTx sends the transaction to the node in RPC_ADDR env. var., or to the node of nodeAddr by the tx message.
+func broadcastTx(nodeID string, tx *node.Transaction, nodeAddr, peerID string) {
+	if client := rpcClient(nodeID); client != nil {
+		if err := client.Call("sendrawtransaction", []interface{}{hex.EncodeToString(tx.Marshal())}, nil); err != nil {
+			log.Panic(err)
+		}
+	} else {
+		if err := server.SendTx(nodeAddr, tx, peerID); err != nil {
+			log.Panic(err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hansung080/gchain/net/banman"
+	"github.com/hansung080/gchain/net/rpc"
+	"github.com/hansung080/gchain/net/server"
+)
+
+type banResult struct {
+	rpc.BanResult
+}
+
+func (r banResult) printText() {
+	fmt.Printf("Banned %s until %s\n", r.Host, time.Unix(r.Until, 0).Format(time.RFC3339))
+}
+
+// setBan bans the host for the duration, or lifts its ban with remove.
+func setBan(nodeID, host string, remove bool, duration time.Duration) result {
+	command := "add"
+	if remove {
+		command = "remove"
+	}
+
+	if client := rpcClient(nodeID); client != nil {
+		var r banResult
+		if err := client.Call("setban", []interface{}{host, command, int64(duration / time.Second)}, &r.BanResult); err != nil {
+			if remove {
+				return newFailure("Unban", err)
+			}
+			log.Panic(err)
+		}
+
+		if remove {
+			return messageResult{fmt.Sprintf("Unbanned %s", host)}
+		}
+		return r
+	}
+
+	banMgr, err := banman.New(server.BansFile(nodeID))
+	if err != nil {
+		log.Panic(err)
+	}
+
+	var r result
+	if remove {
+		if !banMgr.Unban(host) {
+			return newFailure("Unban", fmt.Errorf("Host not banned: %s", host))
+		}
+		r = messageResult{fmt.Sprintf("Unbanned %s", host)}
+	} else {
+		r = banResult{rpc.NewBanResult(banMgr.Ban(host, duration, banman.ManualReason))}
+	}
+
+	if err := banMgr.Save(); err != nil {
+		log.Panic(err)
+	}
+	return r
+}
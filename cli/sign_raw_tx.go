@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/hansung080/gchain/net/rpc"
+	"github.com/hansung080/gchain/node"
+)
+
+type signRawTxResult struct {
+	Txid     string `json:"txid"`
+	Signed   int    `json:"signed"` // the number of the inputs signed by the wallet
+	Inputs   int    `json:"inputs"`
+	Complete bool   `json:"complete"`
+}
+
+func (r signRawTxResult) printText() {
+	fmt.Printf("%d of %d inputs signed\n", r.Signed, r.Inputs)
+	if r.Complete {
+		fmt.Println("Transaction is completely signed.")
+	}
+}
+
+func signRawTx(nodeID, in, out string) result {
+	p, err := node.LoadPartialTx(in)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// the wallet of the running node signs it, which is unlocked by walletpassphrase if encrypted.
+	if client := rpcClient(nodeID); client != nil {
+		var r rpc.SignRawTxResult
+		if err := client.Call("signrawtransaction", []interface{}{hex.EncodeToString(p.Marshal())}, &r); err != nil {
+			return newFailure("Signing", err)
+		}
+
+		data, err := hex.DecodeString(r.Hex)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		if p, err = node.UnmarshalPartialTx(data); err != nil {
+			log.Panic(err)
+		}
+
+		if err := p.SaveFile(out); err != nil {
+			log.Panic(err)
+		}
+
+		return signRawTxResult{r.Txid, r.Signed, r.Inputs, r.Complete}
+	}
+
+	wallets, err := loadWallets(nodeID)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	signed := p.Sign(wallets)
+	if err := p.SaveFile(out); err != nil {
+		log.Panic(err)
+	}
+
+	return signRawTxResult{fmt.Sprintf("%x", p.Tx.ID), signed, len(p.Tx.Vins), p.IsComplete()}
+}
@@ -1,3 +1,29 @@
 package cli
 
-func startNode(nodeID, miner string) {}
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/hansung080/gchain/net/server"
+	"github.com/hansung080/gchain/node"
+)
+
+func startNode(cfg server.Config) {
+	if cfg.Miner != "" {
+		if !node.ValidateAddress(cfg.Miner) {
+			log.Panicf("Invalid address: %v\n", cfg.Miner)
+		}
+		fmt.Printf("Mining is on. Address to receive rewards: %s\n", cfg.Miner)
+
+		// the background miner reports the mined blocks, instead of the progress of the proof of work.
+		node.MiningOutput = io.Discard
+	}
+
+	if cfg.EmptyBlocks && cfg.Miner == "" {
+		log.Panic("-emptyblocks requires -miner")
+	}
+
+	fmt.Printf("Starting node %s\n", cfg.NodeID)
+	server.NewServer(cfg).Run()
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/hansung080/gchain/node"
+)
+
+const (
+	walletPassphraseEnv  = "WALLET_PASSPHRASE"
+	defaultUnlockTimeout = 5 * time.Minute
+)
+
+var (
+	stdinReader            = bufio.NewReader(os.Stdin)
+	promptOutput io.Writer = os.Stdout // stderr for the JSON output
+)
+
+// loadWallets loads the wallet of the node, and unlocks it until the command exits, if it is encrypted.
+// The passphrase is read from WALLET_PASSPHRASE env. var., or prompted when the env. var. is not set.
+func loadWallets(nodeID string) (*node.Wallets, error) {
+	wallets, err := node.NewWallets(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if wallets.IsLocked() {
+		passphrase, err := readWalletPassphrase()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := wallets.Unlock(passphrase, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	return wallets, nil
+}
+
+// readWalletPassphrase reads the passphrase from WALLET_PASSPHRASE env. var., or prompts it when the env. var. is not set.
+func readWalletPassphrase() (string, error) {
+	if passphrase := os.Getenv(walletPassphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+
+	return readPassphrase("Enter wallet passphrase: ")
+}
+
+// readNewPassphrase prompts a new passphrase twice, and checks both are the same.
+func readNewPassphrase() (string, error) {
+	passphrase, err := readPassphrase("Enter new wallet passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	confirm, err := readPassphrase("Confirm new wallet passphrase: ")
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase != confirm {
+		return "", errors.New("Passphrases do not match")
+	}
+
+	return passphrase, nil
+}
+
+func readPassphrase(prompt string) (string, error) {
+	fmt.Fprint(promptOutput, prompt)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		passphrase, err := term.ReadPassword(fd)
+		fmt.Fprintln(promptOutput)
+		return string(passphrase), err
+	}
+
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,15 @@
+package cli
+
+// walletLock locks the wallet of the running node before the timeout of walletpassphrase.
+func walletLock(nodeID string) result {
+	client := rpcClient(nodeID)
+	if client == nil {
+		return newFailure("Wallet lock", errNoRPCAddr)
+	}
+
+	if err := client.Call("walletlock", nil, nil); err != nil {
+		return newFailure("Wallet lock", err)
+	}
+
+	return messageResult{"Wallet locked."}
+}
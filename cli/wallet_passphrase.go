@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+)
+
+// walletPassphrase unlocks the wallet of the running node for the timeout, so that the node signs with it for signrawtx.
+func walletPassphrase(nodeID string, timeout time.Duration) result {
+	client := rpcClient(nodeID)
+	if client == nil {
+		return newFailure("Wallet unlock", errNoRPCAddr)
+	}
+
+	passphrase, err := readWalletPassphrase()
+	if err != nil {
+		return newFailure("Wallet unlock", err)
+	}
+
+	if err := client.Call("walletpassphrase", []interface{}{passphrase, int64(timeout / time.Second)}, nil); err != nil {
+		return newFailure("Wallet unlock", err)
+	}
+
+	return messageResult{fmt.Sprintf("Wallet unlocked for %s.", timeout)}
+}
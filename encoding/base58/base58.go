@@ -22,7 +22,8 @@ func Encode(src []byte) []byte {
 	}
 
 	// https://en.bitcoin.it/wiki/Base58Check_encoding#Version_bytes
-	if src[0] == 0x00 {
+	// every leading zero byte is encoded as '1', because the big integer drops it.
+	for i := 0; i < len(src) && src[i] == 0x00; i++ {
 		dest = append(dest, b58Characters[0])
 	}
 
@@ -39,10 +40,13 @@ func Decode(src []byte) []byte {
 		dest.Add(dest, big.NewInt(int64(index)))
 	}
 
-	decoded := dest.Bytes()
-	if src[0] == b58Characters[0] {
-		decoded = append([]byte{0x00}, decoded...)
+	// every leading '1' is decoded as a zero byte.
+	zeros := 0
+	for zeros < len(src) && src[zeros] == b58Characters[0] {
+		zeros++
 	}
 
+	decoded := append(make([]byte, zeros), dest.Bytes()...)
+
 	return decoded
 }
@@ -21,3 +21,23 @@ func TestBase58(t *testing.T) {
 	decoded := Decode([]byte("1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"))
 	assert.Equal(t, strings.ToLower("0062E907B15CBF27D5425399EBF6F0FB50EBB88F18C29B7D93"), hex.EncodeToString(decoded))
 }
+
+func TestBase58LeadingZeros(t *testing.T) {
+	cases := []struct {
+		data    string
+		encoded string
+	}{
+		{"00", "1"},
+		{"0000", "11"},
+		{"000001", "112"},
+		{"00000000000000000000", "1111111111"},
+		{"0000ab", "113x"},
+	}
+
+	for _, c := range cases {
+		data, err := hex.DecodeString(c.data)
+		assert.Nil(t, err)
+		assert.Equal(t, c.encoded, string(Encode(data)))
+		assert.Equal(t, data, Decode([]byte(c.encoded)), "%s has all the leading zeros", c.encoded)
+	}
+}
@@ -0,0 +1,215 @@
+package mining
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hansung080/gchain/node"
+)
+
+/**
+  @ Miner
+    The miner runs in its own goroutine, so that the proof of work does not block the network handlers.
+
+        build a template  -->  search the nonce  -->  submit the block
+              ^                      |                      |
+              |   new tip, or new    |                      |
+              -- transactions after --------------------------
+                 templateRefresh (aborted)
+
+    - A template has the transactions selected from the mempool, and the coinbase rewarding the subsidy and their fees.
+    - Without a transaction, the miner waits for one, or mines an empty block with only the coinbase if EmptyBlocks is set.
+    - A block mined on the old tip is rejected as stale, if the tip has changed before it is submitted.
+*/
+
+// templateRefresh is the time after which the template is rebuilt to include the new transactions.
+const templateRefresh = 10 * time.Second
+
+type Config struct {
+	Addr        string // the address to send the block rewards to
+	EmptyBlocks bool   // mines the blocks without a transaction too
+}
+
+// Chain builds the block templates on its tip, and connects the mined blocks.
+type Chain interface {
+	BlockTemplate(addr string) *node.Block
+	SubmitBlock(block *node.Block) error
+}
+
+type Stats struct {
+	Running        bool
+	Height         int // the height of the block being mined
+	Txs            int // the transactions of the block being mined, except the coinbase
+	BlocksMined    int
+	RejectedBlocks int // such as the stale blocks mined on the old tip
+	Hashes         int64
+	HashRate       float64 // the hashes per second since the start
+	StartedAt      time.Time
+}
+
+type Miner struct {
+	cfg    Config
+	chain  Chain
+	events *node.EventBus
+
+	mu    sync.Mutex
+	stats Stats
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// Start mines in the background until Stop is called.
+func (m *Miner) Start() {
+	m.mu.Lock()
+	m.stats.Running = true
+	m.stats.StartedAt = time.Now()
+	m.mu.Unlock()
+
+	go m.run()
+}
+
+// Stop aborts the block being mined, and waits for the miner to stop.
+func (m *Miner) Stop() {
+	close(m.quit)
+	<-m.done
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats.Running = false
+}
+
+// Stats returns the copy of the statistics. A nil Miner returns the empty statistics, as not running.
+func (m *Miner) Stats() Stats {
+	if m == nil {
+		return Stats{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.stats
+	if elapsed := time.Since(stats.StartedAt).Seconds(); stats.Running && elapsed > 0 {
+		stats.HashRate = float64(stats.Hashes) / elapsed
+	}
+	return stats
+}
+
+func (m *Miner) run() {
+	defer close(m.done)
+
+	sub := m.events.Subscribe(func(e node.Event) bool {
+		return e.Type == node.BlockConnected || e.Type == node.TxAccepted
+	}, 64)
+	defer m.events.Unsubscribe(sub)
+
+	for {
+		template := m.chain.BlockTemplate(m.cfg.Addr)
+		if len(template.Txs) == 1 && !m.cfg.EmptyBlocks {
+			select {
+			case <-sub.C:
+				continue
+			case <-m.quit:
+				return
+			}
+		}
+
+		m.mu.Lock()
+		m.stats.Height = template.Height
+		m.stats.Txs = len(template.Txs) - 1
+		m.mu.Unlock()
+
+		if !m.mine(template, sub) {
+			return
+		}
+	}
+}
+
+// mine searches the nonce of the template, and submits the block if it is found.
+// The search is aborted on a new tip, or on a new transaction after templateRefresh. It returns false if the miner is stopped.
+func (m *Miner) mine(template *node.Block, sub *node.Subscription) bool {
+	var nonce int
+	var hash []byte
+	abort := make(chan struct{})
+	found := make(chan bool, 1)
+
+	go func() {
+		var ok bool
+		nonce, hash, ok = node.NewProofOfWork(template).RunUntil(abort)
+		found <- ok
+	}()
+
+	// the block found before the abort is submitted too, which is rejected as stale on a new tip.
+	finish := func(ok bool) {
+		m.addHashes(nonce)
+		if ok {
+			template.Hash = hash
+			template.Nonce = nonce
+			m.submit(template)
+		}
+	}
+
+	startTime := time.Now()
+	for {
+		select {
+		case ok := <-found:
+			finish(ok)
+			return true
+
+		case e := <-sub.C:
+			// the tip the template is built on could be announced after the template is built.
+			if e.Type == node.BlockConnected && bytes.Equal(e.Block.Hash, template.PrevHash) {
+				continue
+			}
+			if e.Type == node.TxAccepted && time.Since(startTime) < templateRefresh {
+				continue
+			}
+
+			close(abort)
+			finish(<-found)
+			return true
+
+		case <-m.quit:
+			close(abort)
+			<-found
+			m.addHashes(nonce)
+			return false
+		}
+	}
+}
+
+func (m *Miner) addHashes(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats.Hashes += int64(n)
+}
+
+func (m *Miner) submit(block *node.Block) {
+	err := m.chain.SubmitBlock(block)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		m.stats.RejectedBlocks++
+		fmt.Printf("Mined block %x is rejected: %s\n", block.Hash, err)
+		return
+	}
+
+	m.stats.BlocksMined++
+	fmt.Printf("Mined a new block %x at height %d with %d transactions.\n", block.Hash, block.Height, len(block.Txs) - 1)
+}
+
+func New(cfg Config, chain Chain, events *node.EventBus) *Miner {
+	return &Miner{
+		cfg:    cfg,
+		chain:  chain,
+		events: events,
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
@@ -0,0 +1,97 @@
+package mining
+
+import (
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hansung080/gchain/node"
+	"github.com/stretchr/testify/assert"
+)
+
+// chain is the chain of the blocks submitted, which has the transactions to mine in txs.
+type chain struct {
+	mu     sync.Mutex
+	events *node.EventBus
+	blocks []*node.Block
+	txs    []*node.Transaction
+}
+
+func (c *chain) BlockTemplate(addr string) *node.Block {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	txs := append(append([]*node.Transaction{}, c.txs...), node.NewCoinbaseTx(addr, ""))
+	tip := c.blocks[len(c.blocks) - 1]
+	return node.NewBlockTemplate(txs, tip.Hash, tip.Height + 1)
+}
+
+func (c *chain) SubmitBlock(block *node.Block) error {
+	c.mu.Lock()
+	c.blocks = append(c.blocks, block)
+	c.txs = nil
+	c.mu.Unlock()
+
+	c.events.Publish(node.Event{Type: node.BlockConnected, Block: block})
+	return nil
+}
+
+func (c *chain) height() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.blocks[len(c.blocks) - 1].Height
+}
+
+func newChain(addr string) *chain {
+	genesis := node.NewGenesisBlock(node.NewCoinbaseTx(addr, ""))
+	return &chain{events: node.NewEventBus(), blocks: []*node.Block{genesis}}
+}
+
+func TestMineTransactions(t *testing.T) {
+	node.MiningOutput = io.Discard
+	defer func() { node.MiningOutput = os.Stdout }()
+
+	addr := string(node.NewWallet(node.P256).GetAddress())
+	c := newChain(addr)
+	m := New(Config{Addr: addr}, c, c.events)
+	m.Start()
+	defer m.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, c.height(), "No empty block is mined")
+
+	tx := node.NewCoinbaseTx(addr, "")
+	c.mu.Lock()
+	c.txs = []*node.Transaction{tx}
+	c.mu.Unlock()
+	c.events.Publish(node.Event{Type: node.TxAccepted, Tx: tx})
+
+	assert.Eventually(t, func() bool { return c.height() == 1 }, 10 * time.Second, 10 * time.Millisecond)
+	assert.Equal(t, tx.ID, c.blocks[1].Txs[0].ID)
+	assert.True(t, node.NewProofOfWork(c.blocks[1]).Validate())
+	assert.Equal(t, 1, m.Stats().BlocksMined)
+}
+
+func TestMineEmptyBlocks(t *testing.T) {
+	node.MiningOutput = io.Discard
+	defer func() { node.MiningOutput = os.Stdout }()
+
+	addr := string(node.NewWallet(node.P256).GetAddress())
+	c := newChain(addr)
+	m := New(Config{Addr: addr, EmptyBlocks: true}, c, c.events)
+	m.Start()
+
+	assert.Eventually(t, func() bool { return c.height() >= 3 }, 10 * time.Second, 10 * time.Millisecond)
+	m.Stop()
+
+	stats := m.Stats()
+	assert.False(t, stats.Running)
+	assert.Equal(t, c.height(), stats.BlocksMined)
+	assert.True(t, stats.Hashes > 0)
+	for i := 1; i < len(c.blocks); i++ {
+		assert.Equal(t, c.blocks[i - 1].Hash, c.blocks[i].PrevHash, "Each block is mined on the tip")
+	}
+}
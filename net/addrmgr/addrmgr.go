@@ -0,0 +1,294 @@
+package addrmgr
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+  @ Address Manager
+    - A peer is not deleted on failures. It is retried after the backoff doubled by each consecutive failure.
+    - Up to maxAddrs addresses are kept. A new address evicts the worst one: the most failures, and then the oldest seen.
+    - A successful contact resets the failures, and updates the last seen time.
+    - Outbound peers are selected from different network groups first, so that a single network cannot surround the node.
+
+    failures   0    1     2     3     ...   n
+    backoff    0    30s   1m    2m    ...   min(30s * 2^(n-1), 1h)
+*/
+
+const (
+	baseBackoff = 30 * time.Second
+	maxBackoff  = time.Hour
+
+	maxAddrs       = 4096
+	MaxAddrsPerMsg = 1000
+)
+
+type KnownAddr struct {
+	Addr        string    `json:"addr"`
+	LastSeen    time.Time `json:"lastseen"`
+	LastAttempt time.Time `json:"lastattempt"`
+	Failures    int       `json:"failures"` // consecutive failures since the last successful contact
+}
+
+// retryAt returns the time when the address could be tried again.
+func (ka *KnownAddr) retryAt() time.Time {
+	if ka.Failures == 0 {
+		return time.Time{}
+	}
+
+	backoff := maxBackoff
+	if ka.Failures < 32 {
+		if b := baseBackoff << uint(ka.Failures - 1); b < maxBackoff {
+			backoff = b
+		}
+	}
+
+	return ka.LastAttempt.Add(backoff)
+}
+
+// Manager keeps the known peer addresses in the file.
+type Manager struct {
+	mu     sync.Mutex
+	saveMu sync.Mutex // serializes the saves, which share the temporary file
+	file   string
+	addrs  map[string]*KnownAddr
+	now    func() time.Time
+}
+
+// Add adds the new addresses, and returns the addresses which were not known.
+func (m *Manager) Add(addrs ...string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var added []string
+	for _, addr := range addrs {
+		if _, exist := m.addrs[addr]; exist || !valid(addr) {
+			continue
+		}
+
+		m.insert(&KnownAddr{Addr: addr})
+		added = append(added, addr)
+	}
+
+	return added
+}
+
+// insert inserts the known address, evicting the worst address if the manager is full.
+func (m *Manager) insert(ka *KnownAddr) {
+	if len(m.addrs) >= maxAddrs {
+		var worst *KnownAddr
+		for _, other := range m.addrs {
+			if worst == nil || other.Failures > worst.Failures ||
+				(other.Failures == worst.Failures && other.LastSeen.Before(worst.LastSeen)) {
+				worst = other
+			}
+		}
+		delete(m.addrs, worst.Addr)
+	}
+
+	m.addrs[ka.Addr] = ka
+}
+
+// Good marks the successful contact with the known address. A nil Manager ignores it.
+func (m *Manager) Good(addr string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ka, exist := m.addrs[addr]; exist {
+		ka.LastSeen = m.now()
+		ka.LastAttempt = ka.LastSeen
+		ka.Failures = 0
+	}
+}
+
+// Failed marks the failed attempt to the known address, which backs off before the next attempt.
+// A nil Manager ignores it.
+func (m *Manager) Failed(addr string) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if ka, exist := m.addrs[addr]; exist {
+		ka.LastAttempt = m.now()
+		ka.Failures++
+	}
+}
+
+// Known returns the copies of all the known addresses sorted by the address.
+func (m *Manager) Known() []KnownAddr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var known []KnownAddr
+	for _, ka := range m.addrs {
+		known = append(known, *ka)
+	}
+
+	sort.Slice(known, func(i, j int) bool {
+		return known[i].Addr < known[j].Addr
+	})
+	return known
+}
+
+func (m *Manager) Has(addr string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, exist := m.addrs[addr]
+	return exist
+}
+
+// Select selects up to n addresses not backing off, except the addresses in exclude.
+// It takes one address from each network group in turn, preferring the addresses seen recently in a group.
+func (m *Manager) Select(n int, exclude ...string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	excluded := make(map[string]bool)
+	for _, addr := range exclude {
+		excluded[addr] = true
+	}
+
+	now := m.now()
+	groups := make(map[string][]*KnownAddr)
+	for _, ka := range m.addrs {
+		if !excluded[ka.Addr] && !now.Before(ka.retryAt()) {
+			group := netGroup(ka.Addr)
+			groups[group] = append(groups[group], ka)
+		}
+	}
+
+	var groupNames []string
+	for group, kas := range groups {
+		groupNames = append(groupNames, group)
+		rand.Shuffle(len(kas), func(i, j int) { kas[i], kas[j] = kas[j], kas[i] })
+		sort.SliceStable(kas, func(i, j int) bool {
+			if kas[i].Failures != kas[j].Failures {
+				return kas[i].Failures < kas[j].Failures
+			}
+			return kas[i].LastSeen.After(kas[j].LastSeen)
+		})
+	}
+	rand.Shuffle(len(groupNames), func(i, j int) { groupNames[i], groupNames[j] = groupNames[j], groupNames[i] })
+
+	var selected []string
+	for round := 0; len(selected) < n; round++ {
+		picked := false
+		for _, group := range groupNames {
+			if kas := groups[group]; round < len(kas) && len(selected) < n {
+				selected = append(selected, kas[round].Addr)
+				picked = true
+			}
+		}
+
+		if !picked {
+			break
+		}
+	}
+
+	return selected
+}
+
+// Shareable returns up to MaxAddrsPerMsg addresses not failing now, to send to other peers.
+func (m *Manager) Shareable() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var addrs []string
+	for _, ka := range m.addrs {
+		if ka.Failures == 0 {
+			addrs = append(addrs, ka.Addr)
+		}
+	}
+
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	if len(addrs) > MaxAddrsPerMsg {
+		addrs = addrs[:MaxAddrsPerMsg]
+	}
+	return addrs
+}
+
+// Save saves the known addresses into the file, via a temporary file not to break the file on a crash.
+func (m *Manager) Save() error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(m.Known(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := m.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, m.file)
+}
+
+// New makes the manager of the addresses loaded from the file, if it exists.
+func New(file string) (*Manager, error) {
+	m := &Manager{
+		file:  file,
+		addrs: make(map[string]*KnownAddr),
+		now:   time.Now,
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var known []KnownAddr
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, err
+	}
+
+	for i := range known {
+		if valid(known[i].Addr) {
+			m.insert(&known[i])
+		}
+	}
+
+	return m, nil
+}
+
+func valid(addr string) bool {
+	host, port, err := net.SplitHostPort(addr)
+	return err == nil && host != "" && port != ""
+}
+
+// netGroup returns the network group of the address: /16 for IPv4, /32 for IPv6, or the host name.
+func netGroup(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return strings.ToLower(host)
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return net.IP(ip4).Mask(net.CIDRMask(16, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(32, 128)).String()
+}
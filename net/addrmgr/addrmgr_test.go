@@ -0,0 +1,96 @@
+package addrmgr
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	m, err := New(filepath.Join(t.TempDir(), "peers.json"))
+	assert.Nil(t, err)
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	assert.Equal(t, []string{"10.0.0.1:3000"}, m.Add("10.0.0.1:3000", "invalid", "10.0.0.1:3000"))
+
+	m.Failed("10.0.0.1:3000")
+	m.Failed("10.0.0.1:3000")
+	assert.Empty(t, m.Select(8))
+	assert.Equal(t, 2, m.Known()[0].Failures, "A failed address is kept")
+
+	now = now.Add(2 * baseBackoff)
+	assert.Equal(t, []string{"10.0.0.1:3000"}, m.Select(8))
+
+	m.Good("10.0.0.2:3000")
+	assert.False(t, m.Has("10.0.0.2:3000"), "Good ignores an unknown address")
+
+	m.Good("10.0.0.1:3000")
+	assert.Equal(t, 0, m.Known()[0].Failures)
+	assert.Equal(t, now, m.Known()[0].LastSeen)
+
+	for i := 0; i < 100; i++ {
+		m.Failed("10.0.0.1:3000")
+	}
+	assert.Equal(t, now.Add(maxBackoff), m.Known()[0].retryAt())
+}
+
+func TestSelectDiverse(t *testing.T) {
+	m, err := New(filepath.Join(t.TempDir(), "peers.json"))
+	assert.Nil(t, err)
+
+	m.Add("10.0.0.1:3000", "10.0.0.2:3000", "10.0.0.3:3000", "10.1.0.1:3000", "[2001:db8::1]:3000", "localhost:3001")
+
+	selected := m.Select(4, "localhost:3001")
+	assert.Len(t, selected, 4)
+
+	groups := make(map[string]bool)
+	for _, addr := range selected {
+		groups[netGroup(addr)] = true
+	}
+	assert.Len(t, groups, 3, "The first round takes one address from each group")
+
+	assert.Len(t, m.Select(10), 6)
+}
+
+func TestEvict(t *testing.T) {
+	m, err := New(filepath.Join(t.TempDir(), "peers.json"))
+	assert.Nil(t, err)
+
+	m.Add("10.0.0.1:3000", "10.0.0.2:3000")
+	m.Good("10.0.0.1:3000")
+	m.Failed("10.0.0.2:3000")
+
+	for i := 0; i < maxAddrs - 2; i++ {
+		m.Add(fmt.Sprintf("10.1.%d.%d:3000", i / 256, i % 256))
+	}
+
+	m.Add("10.2.0.1:3000")
+	assert.Len(t, m.Known(), maxAddrs)
+	assert.False(t, m.Has("10.0.0.2:3000"), "The failed address is evicted first")
+
+	m.Add("10.2.0.2:3000")
+	assert.Len(t, m.Known(), maxAddrs)
+	assert.True(t, m.Has("10.0.0.1:3000"), "The address never seen is evicted before the address seen")
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "peers.json")
+	m, err := New(file)
+	assert.Nil(t, err)
+
+	m.Add("localhost:3000", "localhost:3001")
+	m.Failed("localhost:3001")
+	assert.Equal(t, []string{"localhost:3000"}, m.Shareable())
+	assert.Nil(t, m.Save())
+
+	loaded, err := New(file)
+	assert.Nil(t, err)
+	assert.Len(t, loaded.Known(), 2)
+	assert.True(t, loaded.Has("localhost:3001"))
+	assert.Equal(t, 1, loaded.Known()[1].Failures)
+}
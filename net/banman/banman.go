@@ -0,0 +1,198 @@
+package banman
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+  @ Ban Manager
+    - A peer gets the ban score for each misbehavior, and is banned when its score reaches the threshold.
+    - A peer is identified by its host, because the port of an inbound connection is not the listening port.
+      So, the port of an address given instead of a host is ignored.
+    - A ban expires after its duration. The bans are kept in the file across restarts, but the scores are not.
+
+    score   0 ... 99   100
+            (allowed)  (banned for BanDuration, and the score is reset)
+*/
+
+const (
+	BanThreshold = 100
+	BanDuration  = 24 * time.Hour
+	ManualReason = "manually banned"
+)
+
+type BanEntry struct {
+	Host   string    `json:"host"`
+	Until  time.Time `json:"until"`
+	Reason string    `json:"reason"`
+}
+
+// Manager keeps the ban scores of the peers, and the bans in the file.
+type Manager struct {
+	mu     sync.Mutex
+	saveMu sync.Mutex // serializes the saves, which share the temporary file
+	file   string
+	scores map[string]int
+	bans   map[string]*BanEntry
+	now    func() time.Time
+}
+
+// Misbehaving adds the score to the host, and bans it when the score reaches BanThreshold.
+// It returns true if the host is banned by this misbehavior.
+func (m *Manager) Misbehaving(host string, score int, reason string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	host = Host(host)
+	if m.isBanned(host) {
+		return false
+	}
+
+	m.scores[host] += score
+	if m.scores[host] < BanThreshold {
+		return false
+	}
+
+	delete(m.scores, host)
+	m.bans[host] = &BanEntry{Host: host, Until: m.now().Add(BanDuration), Reason: reason}
+	return true
+}
+
+func (m *Manager) Score(host string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.scores[Host(host)]
+}
+
+// Ban bans the host for the duration, replacing the existing ban of it, and returns the copy of the ban.
+func (m *Manager) Ban(host string, duration time.Duration, reason string) BanEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	host = Host(host)
+	delete(m.scores, host)
+	m.bans[host] = &BanEntry{Host: host, Until: m.now().Add(duration), Reason: reason}
+	return *m.bans[host]
+}
+
+// Unban lifts the ban of the host. It returns false if the host is not banned.
+func (m *Manager) Unban(host string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	host = Host(host)
+	banned := m.isBanned(host)
+	delete(m.bans, host)
+	return banned
+}
+
+func (m *Manager) IsBanned(host string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.isBanned(Host(host))
+}
+
+// isBanned removes the expired ban of the host.
+func (m *Manager) isBanned(host string) bool {
+	ban, exist := m.bans[host]
+	if !exist {
+		return false
+	}
+
+	if !m.now().Before(ban.Until) {
+		delete(m.bans, host)
+		return false
+	}
+	return true
+}
+
+// Banned returns the copies of the bans not expired, sorted by the host.
+func (m *Manager) Banned() []BanEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	banned := []BanEntry{}
+	for host, ban := range m.bans {
+		if m.isBanned(host) {
+			banned = append(banned, *ban)
+		}
+	}
+
+	sort.Slice(banned, func(i, j int) bool {
+		return banned[i].Host < banned[j].Host
+	})
+	return banned
+}
+
+// Save saves the bans into the file, via a temporary file not to break the file on a crash.
+func (m *Manager) Save() error {
+	m.saveMu.Lock()
+	defer m.saveMu.Unlock()
+
+	data, err := json.MarshalIndent(m.Banned(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := m.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, m.file)
+}
+
+// New makes the manager of the bans loaded from the file, if it exists.
+func New(file string) (*Manager, error) {
+	m := &Manager{
+		file:   file,
+		scores: make(map[string]int),
+		bans:   make(map[string]*BanEntry),
+		now:    time.Now,
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var bans []BanEntry
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return nil, err
+	}
+
+	for i := range bans {
+		bans[i].Host = Host(bans[i].Host)
+		m.bans[bans[i].Host] = &bans[i]
+	}
+
+	return m, nil
+}
+
+// Host returns the host of the address, or the address itself if it has no port.
+func Host(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return normalize(addr)
+	}
+	return normalize(host)
+}
+
+func normalize(host string) string {
+	host = strings.ToLower(strings.Trim(host, "[]"))
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
@@ -0,0 +1,69 @@
+package banman
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMisbehaving(t *testing.T) {
+	m, err := New(filepath.Join(t.TempDir(), "bans.json"))
+	assert.Nil(t, err)
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	assert.False(t, m.Misbehaving("10.0.0.1", 60, "invalid"))
+	assert.Equal(t, 60, m.Score("10.0.0.1:3000"), "The port is ignored")
+	assert.False(t, m.IsBanned("10.0.0.1"))
+
+	assert.True(t, m.Misbehaving("10.0.0.1", 40, "invalid"))
+	assert.True(t, m.IsBanned("10.0.0.1"))
+	assert.Equal(t, 0, m.Score("10.0.0.1"), "The score is reset on the ban")
+	assert.False(t, m.Misbehaving("10.0.0.1", 100, "invalid"), "A banned host is not banned again")
+
+	now = now.Add(BanDuration)
+	assert.False(t, m.IsBanned("10.0.0.1"), "The ban expires")
+	assert.Empty(t, m.Banned())
+}
+
+func TestBanAndUnban(t *testing.T) {
+	m, err := New(filepath.Join(t.TempDir(), "bans.json"))
+	assert.Nil(t, err)
+
+	m.Ban("[::1]:3000", time.Hour, "manual")
+	m.Ban("10.0.0.2", time.Hour, "manual")
+	assert.True(t, m.IsBanned("::1"))
+	assert.Equal(t, []string{"10.0.0.2", "::1"}, hosts(m.Banned()))
+
+	assert.True(t, m.Unban("10.0.0.2"))
+	assert.False(t, m.Unban("10.0.0.2"))
+	assert.False(t, m.IsBanned("10.0.0.2"))
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bans.json")
+	m, err := New(file)
+	assert.Nil(t, err)
+
+	m.Ban("10.0.0.1", time.Hour, "manual")
+	m.Ban("10.0.0.2", -time.Hour, "expired")
+	m.Misbehaving("10.0.0.3", 50, "invalid")
+	assert.Nil(t, m.Save())
+
+	loaded, err := New(file)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.0.0.1"}, hosts(loaded.Banned()))
+	assert.Equal(t, "manual", loaded.Banned()[0].Reason)
+	assert.Equal(t, 0, loaded.Score("10.0.0.3"), "The scores are not kept")
+}
+
+func hosts(bans []BanEntry) []string {
+	var hosts []string
+	for _, ban := range bans {
+		hosts = append(hosts, ban.Host)
+	}
+	return hosts
+}
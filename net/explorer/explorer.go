@@ -0,0 +1,307 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hansung080/gchain/net/rpc"
+	"github.com/hansung080/gchain/node"
+)
+
+/**
+  @ Explorer Pages
+    - /                          latest blocks, /?from=<height> for older blocks
+    - /block/<hash or height>    block detail
+    - /tx/<txid>                 transaction detail in the blockchain or the mempool
+    - /address/<address>         balance and history, /address/<pkey hash> for outputs without address
+    - /search?q=<query>          redirect to one of the above
+*/
+
+const (
+	blocksPerPage  = 20
+	historyPerPage = 20
+	maxHistoryPage = math.MaxInt32 / historyPerPage // the last page whose offset does not overflow
+	pkeyHashHexLen = 40
+	hashHexLen     = 64
+)
+
+var templates = map[string]*template.Template{
+	"blocks":  newTemplate(blocksTemplate),
+	"block":   newTemplate(blockTemplate, txPartial),
+	"tx":      newTemplate(txTemplate, txPartial),
+	"address": newTemplate(addressTemplate),
+	"error":   newTemplate(errorTemplate),
+}
+
+// Explorer serves the read-only pages of the blockchain and the mempool.
+type Explorer struct {
+	bc      *node.Blockchain
+	mempool *node.Mempool
+	mux     *http.ServeMux
+}
+
+func (e *Explorer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// recover the panic of node functions into an error page, so that the node keeps running.
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("Explorer %s panic: %v", r.URL.Path, rec)
+			e.renderError(w, http.StatusInternalServerError, "Internal error")
+		}
+	}()
+
+	e.mux.ServeHTTP(w, r)
+}
+
+func (e *Explorer) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, e)
+}
+
+func (e *Explorer) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		e.renderError(w, http.StatusNotFound, "Page not found")
+		return
+	}
+
+	bestHeight := e.bc.GetBestHeight()
+	from := bestHeight
+	if s := r.URL.Query().Get("from"); s != "" {
+		height, err := strconv.Atoi(s)
+		if err != nil || height < 0 {
+			e.renderError(w, http.StatusBadRequest, "Invalid height: " + s)
+			return
+		}
+		from = height
+	}
+
+	var blocks []rpc.BlockResult
+	iter := e.bc.Iterator()
+	for iter.HasNext() && len(blocks) < blocksPerPage {
+		block := iter.Next()
+		if block.Height <= from {
+			blocks = append(blocks, rpc.NewBlockResult(block, bestHeight))
+		}
+	}
+
+	older := -1
+	if len(blocks) > 0 {
+		older = blocks[len(blocks) - 1].Height - 1
+	}
+
+	e.render(w, "blocks", map[string]interface{}{
+		"Title":        "Latest Blocks",
+		"BestHeight":   bestHeight,
+		"MempoolCount": e.mempool.Count(),
+		"Blocks":       blocks,
+		"Older":        older,
+	})
+}
+
+func (e *Explorer) handleBlock(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/block/")
+
+	var block node.Block
+	var err error
+	if height, convErr := strconv.Atoi(id); convErr == nil && len(id) < hashHexLen {
+		block, err = e.bc.GetBlockByHeight(height)
+	} else if hash, decodeErr := hex.DecodeString(id); decodeErr == nil && len(id) == hashHexLen {
+		block, err = e.bc.GetBlock(hash)
+	} else {
+		e.renderError(w, http.StatusBadRequest, "Invalid block hash or height: " + id)
+		return
+	}
+
+	if err != nil {
+		e.renderError(w, http.StatusNotFound, "Block not found: " + id)
+		return
+	}
+
+	e.render(w, "block", map[string]interface{}{
+		"Title": fmt.Sprintf("Block %d", block.Height),
+		"Block": rpc.NewBlockResult(&block, e.bc.GetBestHeight()),
+	})
+}
+
+func (e *Explorer) handleTx(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/tx/")
+	txid, err := hex.DecodeString(id)
+	if err != nil || len(id) != hashHexLen {
+		e.renderError(w, http.StatusBadRequest, "Invalid transaction ID: " + id)
+		return
+	}
+
+	result, found := e.findTx(txid)
+	if !found {
+		e.renderError(w, http.StatusNotFound, "Transaction not found: " + id)
+		return
+	}
+
+	e.render(w, "tx", map[string]interface{}{
+		"Title": "Transaction",
+		"Tx":    result,
+	})
+}
+
+func (e *Explorer) handleAddress(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/address/")
+
+	var addr string
+	var pkeyHash []byte
+	if node.ValidateAddress(id) {
+		addr = id
+		pkeyHash = node.GetPkeyHashFromAddress([]byte(id))
+	} else if decoded, err := hex.DecodeString(id); err == nil && len(id) == pkeyHashHexLen {
+		pkeyHash = decoded
+	} else {
+		e.renderError(w, http.StatusBadRequest, "Invalid address or public key hash: " + id)
+		return
+	}
+
+	page := 1
+	if s := r.URL.Query().Get("page"); s != "" {
+		if page, _ = strconv.Atoi(s); page < 1 || page > maxHistoryPage {
+			e.renderError(w, http.StatusBadRequest, "Invalid page: " + s)
+			return
+		}
+	}
+
+	balance := 0
+	for _, out := range (node.UTXOSet{e.bc}).FindUTXOs(pkeyHash) {
+		balance += out.Value
+	}
+
+	type entry struct {
+		node.HistoryEntry
+		Txid          string
+		Confirmations int
+	}
+
+	index := node.HistoryIndex{e.bc}
+	bestHeight := e.bc.GetBestHeight()
+	historyEntries, total := index.History(pkeyHash, (page - 1) * historyPerPage, historyPerPage)
+
+	var entries []entry
+	for _, h := range historyEntries {
+		entries = append(entries, entry{h, hex.EncodeToString(h.Txid), bestHeight - h.Height + 1})
+	}
+
+	next := 0
+	if page * historyPerPage < total {
+		next = page + 1
+	}
+
+	title := "Address"
+	if addr == "" {
+		title = "Public Key Hash"
+	}
+
+	e.render(w, "address", map[string]interface{}{
+		"Title":          title,
+		"Address":        addr,
+		"PkeyHash":       hex.EncodeToString(pkeyHash),
+		"Balance":        balance,
+		"HistoryEnabled": index.Enabled(),
+		"Entries":        entries,
+		"Total":          total,
+		"Prev":           page - 1,
+		"Next":           next,
+	})
+}
+
+// handleSearch redirects to the page of the query: a block height, an address, a public key hash,
+// or a hash of a block or a transaction.
+func (e *Explorer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var location string
+	if _, err := strconv.Atoi(q); err == nil && len(q) < hashHexLen {
+		location = "/block/" + q
+	} else if node.ValidateAddress(q) {
+		location = "/address/" + q
+	} else if _, err := hex.DecodeString(q); err == nil && len(q) == pkeyHashHexLen {
+		location = "/address/" + q
+	} else if hash, err := hex.DecodeString(q); err == nil && len(q) == hashHexLen {
+		if _, err := e.bc.GetBlock(hash); err == nil {
+			location = "/block/" + q
+		} else {
+			location = "/tx/" + q
+		}
+	} else {
+		e.renderError(w, http.StatusNotFound, "Nothing found: " + q)
+		return
+	}
+
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+// findTx finds the transaction in the mempool first, and then in the blockchain.
+func (e *Explorer) findTx(txid []byte) (rpc.TxResult, bool) {
+	if entry, exist := e.mempool.Get(txid); exist {
+		return rpc.NewTxResult(&entry.Tx), true
+	}
+
+	tx, block, err := e.bc.FindTxWithBlock(txid)
+	if err != nil {
+		return rpc.TxResult{}, false
+	}
+
+	result := rpc.NewTxResult(&tx)
+	result.BlockHash = hex.EncodeToString(block.Hash)
+	result.Confirmations = e.bc.GetBestHeight() - block.Height + 1
+	return result, true
+}
+
+func (e *Explorer) render(w http.ResponseWriter, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := templates[name].ExecuteTemplate(w, "layout", data); err != nil {
+		log.Print(err)
+	}
+}
+
+func (e *Explorer) renderError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	e.render(w, "error", map[string]interface{}{
+		"Title":   http.StatusText(status),
+		"Message": message,
+	})
+}
+
+func New(bc *node.Blockchain, mempool *node.Mempool) *Explorer {
+	e := &Explorer{
+		bc:      bc,
+		mempool: mempool,
+		mux:     http.NewServeMux(),
+	}
+
+	e.mux.HandleFunc("/", e.handleBlocks)
+	e.mux.HandleFunc("/block/", e.handleBlock)
+	e.mux.HandleFunc("/tx/", e.handleTx)
+	e.mux.HandleFunc("/address/", e.handleAddress)
+	e.mux.HandleFunc("/search", e.handleSearch)
+	return e
+}
+
+func newTemplate(texts ...string) *template.Template {
+	t := template.New("").Funcs(template.FuncMap{
+		"formatTime": func(timestamp int64) string {
+			return time.Unix(timestamp, 0).UTC().Format("2006-01-02 15:04:05 UTC")
+		},
+	})
+
+	for _, text := range append([]string{layoutTemplate}, texts...) {
+		template.Must(t.Parse(text))
+	}
+
+	return t
+}
@@ -0,0 +1,72 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/hansung080/gchain/node"
+)
+
+func TestExplorer(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	wallet := node.NewWallet(node.Secp256k1)
+	addr := string(wallet.GetAddress())
+	bc := node.CreateBlockchain("test", addr)
+	defer bc.Close()
+	node.UTXOSet{bc}.Reindex()
+
+	genesis, err := bc.GetBlockByHeight(0)
+	assert.Nil(t, err)
+	hash := hex.EncodeToString(genesis.Hash)
+	txid := hex.EncodeToString(genesis.Txs[0].ID)
+
+	e := New(bc, node.NewMempool(node.UTXOSet{bc}, nil))
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	w := get("/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), hash)
+
+	for _, url := range []string{"/block/0", "/block/" + hash} {
+		w = get(url)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "/tx/" + txid)
+	}
+
+	w = get("/tx/" + txid)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/address/" + hex.EncodeToString(wallet.GetPkeyHash()))
+
+	w = get("/address/" + addr)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<td>10</td>")
+	assert.Contains(t, w.Body.String(), "History index is not built")
+
+	node.HistoryIndex{bc}.Reindex()
+	w = get("/address/" + hex.EncodeToString(wallet.GetPkeyHash()))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "1 entries")
+
+	for q, location := range map[string]string{"0": "/block/0", hash: "/block/" + hash, txid: "/tx/" + txid, addr: "/address/" + addr} {
+		w = get("/search?q=" + q)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, location, w.Header().Get("Location"))
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("/block/1").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/tx/xyz").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/address/" + addr + "?page=" + strconv.Itoa(maxHistoryPage + 1)).Code)
+	assert.Equal(t, http.StatusBadRequest, get("/address/" + addr + "?page=99999999999999999999").Code)
+	assert.Equal(t, http.StatusOK, get("/address/" + addr + "?page=" + strconv.Itoa(maxHistoryPage)).Code)
+	assert.Equal(t, http.StatusNotFound, get("/search?q=nothing").Code)
+}
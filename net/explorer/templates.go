@@ -0,0 +1,121 @@
+package explorer
+
+const layoutTemplate = `{{define "layout"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} - gChain Explorer</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border-bottom: 1px solid #ddd; padding: 4px 8px; text-align: left; }
+.hash { font-family: monospace; }
+.error { color: #c00; }
+</style>
+</head>
+<body>
+<h1><a href="/">gChain Explorer</a></h1>
+<form action="/search" method="get">
+<input type="text" name="q" size="70" placeholder="Block hash, block height, transaction ID, address or public key hash">
+<input type="submit" value="Search">
+</form>
+<h2>{{.Title}}</h2>
+{{template "content" .}}
+</body>
+</html>
+{{end}}`
+
+const blocksTemplate = `{{define "content"}}
+<p>Best height: {{.BestHeight}}, mempool transactions: {{.MempoolCount}}</p>
+<table>
+<tr><th>Height</th><th>Hash</th><th>Time</th><th>Transactions</th></tr>
+{{range .Blocks}}
+<tr>
+<td><a href="/block/{{.Height}}">{{.Height}}</a></td>
+<td class="hash"><a href="/block/{{.Hash}}">{{.Hash}}</a></td>
+<td>{{formatTime .Timestamp}}</td>
+<td>{{len .Txs}}</td>
+</tr>
+{{end}}
+</table>
+{{if ge .Older 0}}<p><a href="/?from={{.Older}}">Older blocks</a></p>{{end}}
+{{end}}`
+
+const blockTemplate = `{{define "content"}}
+{{with .Block}}
+<table>
+<tr><th>Hash</th><td class="hash">{{.Hash}}</td></tr>
+<tr><th>Previous Hash</th><td class="hash">{{if .PrevHash}}<a href="/block/{{.PrevHash}}">{{.PrevHash}}</a>{{end}}</td></tr>
+<tr><th>Height</th><td>{{.Height}}</td></tr>
+<tr><th>Time</th><td>{{formatTime .Timestamp}}</td></tr>
+<tr><th>Nonce</th><td>{{.Nonce}}</td></tr>
+<tr><th>Confirmations</th><td>{{.Confirmations}}</td></tr>
+</table>
+<h3>Transactions</h3>
+{{range .Txs}}{{template "tx" .}}{{end}}
+{{end}}
+{{end}}`
+
+const txTemplate = `{{define "content"}}
+{{with .Tx}}
+<table>
+<tr><th>Block</th><td class="hash">{{if .BlockHash}}<a href="/block/{{.BlockHash}}">{{.BlockHash}}</a>{{else}}(mempool){{end}}</td></tr>
+<tr><th>Confirmations</th><td>{{.Confirmations}}</td></tr>
+</table>
+{{template "tx" .}}
+{{end}}
+{{end}}`
+
+// txPartial is shared by the block page and the transaction page.
+const txPartial = `{{define "tx"}}
+<h4 class="hash">Transaction <a href="/tx/{{.Txid}}">{{.Txid}}</a></h4>
+<table>
+<tr><th>Inputs</th><th>Outputs</th></tr>
+<tr>
+<td>
+{{range .Vins}}
+{{if .Coinbase}}Coinbase
+{{else}}<div class="hash"><a href="/tx/{{.Txid}}">{{.Txid}}:{{.Vout}}</a>
+{{if .Address}}<br><a href="/address/{{.Address}}">{{.Address}}</a>{{end}}</div>{{end}}
+{{end}}
+</td>
+<td>
+{{range .Vouts}}
+<div class="hash"><a href="/address/{{.PkeyHash}}">{{.PkeyHash}}</a>: {{.Value}}</div>
+{{end}}
+</td>
+</tr>
+</table>
+{{end}}`
+
+const addressTemplate = `{{define "content"}}
+<table>
+{{if .Address}}<tr><th>Address</th><td class="hash">{{.Address}}</td></tr>{{end}}
+<tr><th>Public Key Hash</th><td class="hash">{{.PkeyHash}}</td></tr>
+<tr><th>Balance</th><td>{{.Balance}}</td></tr>
+</table>
+<h3>History</h3>
+{{if not .HistoryEnabled}}
+<p>History index is not built. Run reindexhistory to build it.</p>
+{{else}}
+<p>{{.Total}} entries</p>
+<table>
+<tr><th>Transaction</th><th>Direction</th><th>Amount</th><th>Height</th><th>Confirmations</th></tr>
+{{range .Entries}}
+<tr>
+<td class="hash"><a href="/tx/{{.Txid}}">{{.Txid}}</a></td>
+<td>{{.Direction}}</td>
+<td>{{.Amount}}</td>
+<td><a href="/block/{{.Height}}">{{.Height}}</a></td>
+<td>{{.Confirmations}}</td>
+</tr>
+{{end}}
+</table>
+{{if .Prev}}<a href="?page={{.Prev}}">Newer</a>{{end}}
+{{if .Next}}<a href="?page={{.Next}}">Older</a>{{end}}
+{{end}}
+{{end}}`
+
+const errorTemplate = `{{define "content"}}
+<p class="error">{{.Message}}</p>
+{{end}}`
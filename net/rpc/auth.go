@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+/**
+  @ Authentication
+    - A request must have the HTTP basic auth of the server, and Content-Type: application/json,
+      so that a web page cannot send a request to the node by a simple form or a text/plain POST.
+    - The node writes a random password of CookieUser into its cookie file on start, which only its owner could read,
+      unless the user and the password are configured.
+
+    cookie file:  __cookie__:<random password>
+*/
+
+const (
+	CookieUser = "__cookie__"
+	CookieFile = "rpc_cookie_%s" // the cookie file of the node ID
+
+	cookiePerm = 0600
+	cookieLen  = 32
+)
+
+var errInvalidAuth = errors.New("Invalid RPC auth: it must be <user>:<password>")
+
+// NewCookie writes the random password of CookieUser into the cookie file, and returns the password.
+func NewCookie(file string) (string, error) {
+	buf := make([]byte, cookieLen)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	password := hex.EncodeToString(buf)
+	if err := ioutil.WriteFile(file, []byte(CookieUser + ":" + password), cookiePerm); err != nil {
+		return "", err
+	}
+
+	return password, nil
+}
+
+// ReadCookie reads the user and the password from the cookie file.
+func ReadCookie(file string) (string, string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", "", err
+	}
+
+	return SplitAuth(strings.TrimSpace(string(data)))
+}
+
+// SplitAuth splits <user>:<password> into the user and the password.
+func SplitAuth(auth string) (string, string, error) {
+	i := strings.Index(auth, ":")
+	if i <= 0 || i == len(auth) - 1 {
+		return "", "", errInvalidAuth
+	}
+
+	return auth[:i], auth[i + 1:], nil
+}
+
+// SetAuth requires the HTTP basic auth of the user and the password for every request.
+func (s *Server) SetAuth(user, password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.user, s.password = user, password
+}
+
+// authorized reports whether the request has the basic auth of the server, or the server requires no auth.
+func (s *Server) authorized(r *http.Request) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.user == "" {
+		return true
+	}
+
+	user, password, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(s.user)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) == 1
+}
+
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// SetAuth sends the HTTP basic auth of the user and the password with every request.
+func (c *Client) SetAuth(user, password string) {
+	c.user, c.password = user, password
+}
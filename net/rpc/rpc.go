@@ -0,0 +1,310 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+/**
+  @ JSON-RPC 2.0 over HTTP
+    - request:  POST / {"jsonrpc": "2.0", "method": "getblock", "params": ["<hash>"], "id": 1}
+    - response: {"jsonrpc": "2.0", "result": {...}, "id": 1}
+                {"jsonrpc": "2.0", "error": {"code": -32601, "message": "Method not found"}, "id": 1}
+    - A batch is an array of requests, and its response is an array of responses.
+    - A notification has no id, and gets no response.
+    - See Authentication for the auth of a request.
+*/
+
+const (
+	Version = "2.0"
+
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+
+	maxRequestSize = 1 << 22
+)
+
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type Response struct {
+	JSONRPC string
+	Result  interface{}
+	Error   *Error
+	ID      json.RawMessage
+}
+
+// MarshalJSON marshals either result or error, because a zero result must not be omitted.
+func (r *Response) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(struct {
+			JSONRPC string          `json:"jsonrpc"`
+			Error   *Error          `json:"error"`
+			ID      json.RawMessage `json:"id"`
+		}{r.JSONRPC, r.Error, r.ID})
+	}
+
+	return json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Result  interface{}     `json:"result"`
+		ID      json.RawMessage `json:"id"`
+	}{r.JSONRPC, r.Result, r.ID})
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("RPC error %d: %s", e.Code, e.Message)
+}
+
+func NewError(code int, format string, a ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// Handler handles the params of a method. An error is sent as InternalError, unless it is an *Error.
+type Handler func(params json.RawMessage) (interface{}, error)
+
+type Server struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+	user     string // no auth is required if empty
+	password string
+}
+
+func (s *Server) Register(method string, handler Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[method] = handler
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isJSON(r) {
+		http.Error(w, "Unsupported media type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="jsonrpc"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		writeJSON(w, newErrorResponse(nil, NewError(ParseError, "Parse error")))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		var reqs []json.RawMessage
+		if err := json.Unmarshal(body, &reqs); err != nil || len(reqs) == 0 {
+			writeJSON(w, newErrorResponse(nil, NewError(InvalidRequest, "Invalid request")))
+			return
+		}
+
+		var resps []*Response
+		for _, req := range reqs {
+			if resp := s.handle(req); resp != nil {
+				resps = append(resps, resp)
+			}
+		}
+
+		if len(resps) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, resps)
+		return
+	}
+
+	if resp := s.handle(body); resp != nil {
+		writeJSON(w, resp)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handle handles a request, and returns nil for a notification.
+func (s *Server) handle(data []byte) (resp *Response) {
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return newErrorResponse(nil, NewError(ParseError, "Parse error"))
+	}
+
+	if req.JSONRPC != Version || req.Method == "" {
+		return newErrorResponse(req.ID, NewError(InvalidRequest, "Invalid request"))
+	}
+
+	s.mu.RLock()
+	handler, exist := s.handlers[req.Method]
+	s.mu.RUnlock()
+
+	if !exist {
+		resp = newErrorResponse(req.ID, NewError(MethodNotFound, "Method not found: %s", req.Method))
+	} else {
+		resp = call(handler, req)
+	}
+
+	if req.ID == nil {
+		return nil
+	}
+	return resp
+}
+
+// call calls the handler, and recovers the panic of the handler into InternalError.
+func call(handler Handler, req Request) (resp *Response) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("RPC %s panic: %v", req.Method, r)
+			resp = newErrorResponse(req.ID, NewError(InternalError, "%v", r))
+		}
+	}()
+
+	result, err := handler(req.Params)
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = NewError(InternalError, "%s", err)
+		}
+		return newErrorResponse(req.ID, rpcErr)
+	}
+
+	return &Response{JSONRPC: Version, Result: result, ID: req.ID}
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	return http.ListenAndServe(addr, s)
+}
+
+func NewServer() *Server {
+	return &Server{handlers: make(map[string]Handler)}
+}
+
+// UnmarshalParams unmarshals the positional params into the pointers of v in order.
+// The params missing at the end keep the values of v, so that they are optional.
+func UnmarshalParams(params json.RawMessage, v ...interface{}) error {
+	var raws []json.RawMessage
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &raws); err != nil {
+			return NewError(InvalidParams, "Params must be an array")
+		}
+	}
+
+	if len(raws) > len(v) {
+		return NewError(InvalidParams, "Too many params: %d > %d", len(raws), len(v))
+	}
+
+	for i, raw := range raws {
+		if err := json.Unmarshal(raw, v[i]); err != nil {
+			return NewError(InvalidParams, "Invalid param %d: %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+type Client struct {
+	url      string
+	http     *http.Client
+	id       int64
+	user     string // no auth is sent if empty
+	password string
+}
+
+// Call calls the method with the positional params, and unmarshals the result into result if it is not nil.
+func (c *Client) Call(method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+
+	encodedParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	id, _ := json.Marshal(atomic.AddInt64(&c.id, 1))
+	body, err := json.Marshal(Request{
+		JSONRPC: Version,
+		Method:  method,
+		Params:  encodedParams,
+		ID:      id,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.user != "" {
+		httpReq.SetBasicAuth(c.user, c.password)
+	}
+
+	httpResp, err := c.http.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	var resp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+
+	if err := json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return fmt.Errorf("Invalid RPC response: %s", httpResp.Status)
+	}
+
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, result)
+}
+
+func NewClient(addr string) *Client {
+	return &Client{
+		url:  fmt.Sprintf("http://%s/", addr),
+		http: &http.Client{},
+	}
+}
+
+func newErrorResponse(id json.RawMessage, err *Error) *Response {
+	return &Response{JSONRPC: Version, Error: err, ID: id}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Print(err)
+	}
+}
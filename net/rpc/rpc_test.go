@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestServer() *httptest.Server {
+	s := NewServer()
+	s.Register("add", func(params json.RawMessage) (interface{}, error) {
+		var a, b int
+		if err := UnmarshalParams(params, &a, &b); err != nil {
+			return nil, err
+		}
+		return a + b, nil
+	})
+	s.Register("fail", func(params json.RawMessage) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	s.Register("panic", func(params json.RawMessage) (interface{}, error) {
+		panic("panicked")
+	})
+
+	return httptest.NewServer(s)
+}
+
+func TestClientCall(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+	client := NewClient(strings.TrimPrefix(ts.URL, "http://"))
+
+	var sum int
+	assert.Nil(t, client.Call("add", []interface{}{1, 2}, &sum))
+	assert.Equal(t, 3, sum)
+
+	// a zero result is not omitted.
+	assert.Nil(t, client.Call("add", []interface{}{0}, &sum))
+	assert.Equal(t, 0, sum)
+
+	err := client.Call("add", []interface{}{1, 2, 3}, &sum)
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, InvalidParams, err.(*Error).Code)
+	}
+
+	err = client.Call("nothing", nil, nil)
+	if assert.IsType(t, &Error{}, err) {
+		assert.Equal(t, MethodNotFound, err.(*Error).Code)
+	}
+
+	for _, method := range []string{"fail", "panic"} {
+		err = client.Call(method, nil, nil)
+		if assert.IsType(t, &Error{}, err) {
+			assert.Equal(t, InternalError, err.(*Error).Code)
+		}
+	}
+}
+
+func TestServerBatch(t *testing.T) {
+	ts := newTestServer()
+	defer ts.Close()
+
+	post := func(body string) (int, string) {
+		resp, err := http.Post(ts.URL, "application/json", strings.NewReader(body))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(data))
+	}
+
+	_, body := post(`[{"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": 1},` +
+		`{"jsonrpc": "2.0", "method": "add", "params": [3, 4]},` +
+		`{"jsonrpc": "1.0", "method": "add", "id": "x"}]`)
+	assert.Equal(t, `[{"jsonrpc":"2.0","result":3,"id":1},{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid request"},"id":"x"}]`, body)
+
+	// a notification gets no response.
+	status, body := post(`{"jsonrpc": "2.0", "method": "add", "params": [1, 2]}`)
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.Empty(t, body)
+
+	_, body = post(`{"jsonrpc": `)
+	assert.Equal(t, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`, body)
+}
+
+func TestServerAuth(t *testing.T) {
+	s := NewServer()
+	s.Register("add", func(params json.RawMessage) (interface{}, error) {
+		return 0, nil
+	})
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	cookie := filepath.Join(t.TempDir(), "cookie")
+	password, err := NewCookie(cookie)
+	assert.Nil(t, err)
+	s.SetAuth(CookieUser, password)
+
+	client := NewClient(strings.TrimPrefix(ts.URL, "http://"))
+	assert.NotNil(t, client.Call("add", nil, nil), "A request without auth is refused")
+
+	user, password, err := ReadCookie(cookie)
+	assert.Nil(t, err)
+	client.SetAuth(user, password)
+	assert.Nil(t, client.Call("add", nil, nil))
+
+	client.SetAuth(user, "wrong")
+	assert.NotNil(t, client.Call("add", nil, nil))
+
+	// a web page could post text/plain without a preflight.
+	resp, err := http.Post(ts.URL, "text/plain", strings.NewReader(`{"jsonrpc": "2.0", "method": "add", "id": 1}`))
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	_, _, err = SplitAuth("user")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,232 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hansung080/gchain/mining"
+	"github.com/hansung080/gchain/net/addrmgr"
+	"github.com/hansung080/gchain/net/banman"
+	"github.com/hansung080/gchain/node"
+)
+
+type BlockResult struct {
+	Hash          string     `json:"hash"`
+	PrevHash      string     `json:"prevhash"`
+	Height        int        `json:"height"`
+	Timestamp     int64      `json:"timestamp"`
+	Nonce         int        `json:"nonce"`
+	Confirmations int        `json:"confirmations"`
+	Txs           []TxResult `json:"txs"`
+}
+
+type TxResult struct {
+	Txid          string        `json:"txid"`
+	Vins          []TxInResult  `json:"vins"`
+	Vouts         []TxOutResult `json:"vouts"`
+	BlockHash     string        `json:"blockhash,omitempty"`
+	Confirmations int           `json:"confirmations"` // 0 for a mempool transaction
+}
+
+type TxInResult struct {
+	Txid     string `json:"txid,omitempty"`
+	Vout     int    `json:"vout"`
+	Address  string `json:"address,omitempty"`
+	Coinbase string `json:"coinbase,omitempty"`
+}
+
+// TxOutResult has no address, because the address version is not kept in the output.
+type TxOutResult struct {
+	Value    int    `json:"value"`
+	PkeyHash string `json:"pkeyhash"`
+}
+
+type MempoolEntryResult struct {
+	Txid        string `json:"txid"`
+	Fee         int    `json:"fee"`
+	Size        int    `json:"size"`
+	Replaceable bool   `json:"replaceable"`
+	Time        int64  `json:"time"`
+}
+
+type PeerResult struct {
+	Addr     string `json:"addr"`
+	LastSeen int64  `json:"lastseen"` // 0 if never seen
+	Failures int    `json:"failures"`
+	BanScore int    `json:"banscore"`
+}
+
+type BanResult struct {
+	Host   string `json:"host"`
+	Until  int64  `json:"until"`
+	Reason string `json:"reason"`
+}
+
+type MiningInfoResult struct {
+	Mining         bool    `json:"mining"`
+	Height         int     `json:"height"` // the height of the block being mined
+	Txs            int     `json:"txs"`
+	BlocksMined    int     `json:"blocksmined"`
+	RejectedBlocks int     `json:"rejectedblocks"`
+	Hashes         int64   `json:"hashes"`
+	HashRate       float64 `json:"hashrate"`
+}
+
+// WorkResult is the block template for an external miner. The block is mined by the nonce making
+// sha256(header || nonce) less than target, where nonce is the 8-byte big-endian integer.
+type WorkResult struct {
+	WorkID    string   `json:"workid"`
+	Header    string   `json:"header"`
+	Target    string   `json:"target"`
+	PrevHash  string   `json:"prevhash"`
+	Height    int      `json:"height"`
+	Timestamp int64    `json:"timestamp"`
+	Txs       int      `json:"txs"` // the transactions except the coinbase
+	Coinbase  TxResult `json:"coinbase"`
+}
+
+// SignRawTxResult is the raw transaction signed by the wallet of the node, as the hex of the file of createrawtx.
+type SignRawTxResult struct {
+	Hex      string `json:"hex"`
+	Txid     string `json:"txid"`
+	Signed   int    `json:"signed"` // the number of the inputs signed by the wallet
+	Inputs   int    `json:"inputs"`
+	Complete bool   `json:"complete"`
+}
+
+type EventResult struct {
+	Type   node.EventType `json:"type"`
+	Block  *BlockResult   `json:"block,omitempty"`
+	Tx     *TxResult      `json:"tx,omitempty"`
+	Reason string         `json:"reason,omitempty"`
+}
+
+func NewBlockResult(b *node.Block, bestHeight int) BlockResult {
+	result := BlockResult{
+		Hash:          hex.EncodeToString(b.Hash),
+		PrevHash:      hex.EncodeToString(b.PrevHash),
+		Height:        b.Height,
+		Timestamp:     b.Timestamp,
+		Nonce:         b.Nonce,
+		Confirmations: bestHeight - b.Height + 1,
+		Txs:           []TxResult{},
+	}
+
+	for _, tx := range b.Txs {
+		txResult := NewTxResult(tx)
+		txResult.BlockHash = result.Hash
+		txResult.Confirmations = result.Confirmations
+		result.Txs = append(result.Txs, txResult)
+	}
+
+	return result
+}
+
+func NewTxResult(tx *node.Transaction) TxResult {
+	result := TxResult{
+		Txid:  hex.EncodeToString(tx.ID),
+		Vins:  []TxInResult{},
+		Vouts: []TxOutResult{},
+	}
+
+	for _, in := range tx.Vins {
+		if tx.IsCoinbase() {
+			result.Vins = append(result.Vins, TxInResult{Vout: in.Vout, Coinbase: hex.EncodeToString(in.Pkey)})
+			continue
+		}
+
+		inResult := TxInResult{Txid: hex.EncodeToString(in.Txid), Vout: in.Vout}
+		if wallet, err := node.NewPkeyWallet(in.Pkey); err == nil {
+			inResult.Address = string(wallet.GetAddress())
+		}
+		result.Vins = append(result.Vins, inResult)
+	}
+
+	for _, out := range tx.Vouts {
+		result.Vouts = append(result.Vouts, TxOutResult{Value: out.Value, PkeyHash: hex.EncodeToString(out.PkeyHash)})
+	}
+
+	return result
+}
+
+func NewMempoolEntryResult(entry node.MempoolEntry) MempoolEntryResult {
+	return MempoolEntryResult{
+		Txid:        hex.EncodeToString(entry.Tx.ID),
+		Fee:         entry.Fee,
+		Size:        entry.Size,
+		Replaceable: entry.Replaceable,
+		Time:        entry.Time.Unix(),
+	}
+}
+
+// NewEventResult makes the result of the event. A disconnected block has no confirmations.
+func NewEventResult(e node.Event, bestHeight int) EventResult {
+	result := EventResult{Type: e.Type, Reason: e.Reason}
+	if e.Block != nil {
+		block := NewBlockResult(e.Block, bestHeight)
+		if e.Type == node.BlockDisconnected {
+			block.Confirmations = 0
+			for i := range block.Txs {
+				block.Txs[i].Confirmations = 0
+			}
+		}
+		result.Block = &block
+	}
+
+	if e.Tx != nil {
+		tx := NewTxResult(e.Tx)
+		result.Tx = &tx
+	}
+
+	return result
+}
+
+func NewPeerResult(ka addrmgr.KnownAddr) PeerResult {
+	result := PeerResult{Addr: ka.Addr, Failures: ka.Failures}
+	if !ka.LastSeen.IsZero() {
+		result.LastSeen = ka.LastSeen.Unix()
+	}
+
+	return result
+}
+
+func NewBanResult(ban banman.BanEntry) BanResult {
+	return BanResult{Host: ban.Host, Until: ban.Until.Unix(), Reason: ban.Reason}
+}
+
+func NewMiningInfoResult(stats mining.Stats) MiningInfoResult {
+	return MiningInfoResult{
+		Mining:         stats.Running,
+		Height:         stats.Height,
+		Txs:            stats.Txs,
+		BlocksMined:    stats.BlocksMined,
+		RejectedBlocks: stats.RejectedBlocks,
+		Hashes:         stats.Hashes,
+		HashRate:       stats.HashRate,
+	}
+}
+
+func NewSignRawTxResult(p *node.PartialTx, signed int) SignRawTxResult {
+	return SignRawTxResult{
+		Hex:      hex.EncodeToString(p.Marshal()),
+		Txid:     hex.EncodeToString(p.Tx.ID),
+		Signed:   signed,
+		Inputs:   len(p.Tx.Vins),
+		Complete: p.IsComplete(),
+	}
+}
+
+// NewWorkResult makes the work of the block template, whose coinbase is the last transaction.
+func NewWorkResult(workID string, template *node.Block) WorkResult {
+	pow := node.NewProofOfWork(template)
+	return WorkResult{
+		WorkID:    workID,
+		Header:    hex.EncodeToString(pow.Header()),
+		Target:    fmt.Sprintf("%064x", pow.Target()),
+		PrevHash:  hex.EncodeToString(template.PrevHash),
+		Height:    template.Height,
+		Timestamp: template.Timestamp,
+		Txs:       len(template.Txs) - 1,
+		Coinbase:  NewTxResult(template.Txs[len(template.Txs) - 1]),
+	}
+}
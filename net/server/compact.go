@@ -0,0 +1,170 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/hansung080/gchain/node"
+)
+
+/**
+  @ Compact Block Relay
+    A new block is announced with its header and the short IDs of its transactions instead of the full block,
+    because the peers have most of its transactions in their mempools already.
+
+    sender                                   receiver
+      | -- cmpctblock: header, short IDs ----> | reconstructs the block from the mempool
+      |    and the prefilled coinbase          |
+      | <- getblocktxn: missing indexes ------ | if some transactions are not in the mempool
+      | -- blocktxn: missing transactions ---> | completes the block
+      |                                        |
+      | <- getdata block --------------------- | if the completed block is invalid by a short ID collision
+
+    - A short ID is the first 6 bytes of sha256(block hash || txid), which is salted by the block hash,
+      so that the collisions are different for every block.
+    - The blocks requested by getdata, such as in the sync, are sent in full.
+*/
+
+const (
+	shortIDLen       = 6
+	maxPartialBlocks = 16
+)
+
+// partialBlock is the compact block being reconstructed, whose transactions at missing are nil yet.
+type partialBlock struct {
+	block   *node.Block
+	missing []int
+	from    string
+	created time.Time
+}
+
+func shortTxID(blockHash, txid []byte) []byte {
+	hash := sha256.Sum256(bytes.Join([][]byte{blockHash, txid}, []byte{}))
+	return hash[:shortIDLen]
+}
+
+// newCmpctBlock makes the compact block of the block, whose coinbase is prefilled because no peer has it.
+func newCmpctBlock(from string, b *node.Block) cmpctblock {
+	payload := cmpctblock{
+		From:      from,
+		Timestamp: b.Timestamp,
+		PrevHash:  b.PrevHash,
+		Hash:      b.Hash,
+		Nonce:     b.Nonce,
+		Height:    b.Height,
+	}
+
+	for i, tx := range b.Txs {
+		if tx.IsCoinbase() {
+			payload.Prefilled = append(payload.Prefilled, prefilledTx{Index: i, Tx: tx.Marshal()})
+		} else {
+			payload.ShortIDs = append(payload.ShortIDs, shortTxID(b.Hash, tx.ID))
+		}
+	}
+
+	return payload
+}
+
+// reconstruct places the prefilled transactions, and the transactions of the short IDs found in the mempool.
+// A short ID matching more than one transaction in the mempool is missing too.
+func (s *Server) reconstruct(payload cmpctblock) (*partialBlock, error) {
+	txs := make([]*node.Transaction, len(payload.ShortIDs) + len(payload.Prefilled))
+	for _, prefilled := range payload.Prefilled {
+		if prefilled.Index < 0 || prefilled.Index >= len(txs) || txs[prefilled.Index] != nil {
+			return nil, misbehaving(scoreMalformed, "Malformed cmpctblock: prefilled index %d", prefilled.Index)
+		}
+
+		tx, err := node.DecodeTx(prefilled.Tx)
+		if err != nil {
+			return nil, misbehaving(scoreMalformed, "Malformed cmpctblock: %s", err)
+		}
+		txs[prefilled.Index] = &tx
+	}
+
+	mempoolTxs := make(map[string]*node.Transaction)
+	for _, entry := range s.mempool.Entries() {
+		tx := entry.Tx
+		id := hex.EncodeToString(shortTxID(payload.Hash, tx.ID))
+		if _, exist := mempoolTxs[id]; exist {
+			mempoolTxs[id] = nil
+		} else {
+			mempoolTxs[id] = &tx
+		}
+	}
+
+	p := &partialBlock{from: payload.From, created: time.Now()}
+	next := 0
+	for i := range txs {
+		if txs[i] != nil {
+			continue
+		}
+
+		if len(payload.ShortIDs[next]) != shortIDLen {
+			return nil, misbehaving(scoreMalformed, "Malformed cmpctblock: short ID of %d bytes", len(payload.ShortIDs[next]))
+		}
+
+		if txs[i] = mempoolTxs[hex.EncodeToString(payload.ShortIDs[next])]; txs[i] == nil {
+			p.missing = append(p.missing, i)
+		}
+		next++
+	}
+
+	p.block = &node.Block{
+		Timestamp: payload.Timestamp,
+		Txs:       txs,
+		PrevHash:  payload.PrevHash,
+		Hash:      payload.Hash,
+		Nonce:     payload.Nonce,
+		Height:    payload.Height,
+	}
+	return p, nil
+}
+
+// fill places the missing transactions of blocktxn.
+func (p *partialBlock) fill(txs [][]byte) error {
+	if len(txs) != len(p.missing) {
+		return misbehaving(scoreInvalid, "Invalid blocktxn: %d transactions for %d missing", len(txs), len(p.missing))
+	}
+
+	for i, data := range txs {
+		tx, err := node.DecodeTx(data)
+		if err != nil {
+			return misbehaving(scoreMalformed, "Malformed blocktxn: %s", err)
+		}
+		p.block.Txs[p.missing[i]] = &tx
+	}
+
+	p.missing = nil
+	return nil
+}
+
+// addPartialBlock keeps the partial block until its missing transactions arrive. The oldest one is dropped over maxPartialBlocks.
+func (s *Server) addPartialBlock(p *partialBlock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.partialBlocks) >= maxPartialBlocks {
+		var oldest string
+		for hash, partial := range s.partialBlocks {
+			if oldest == "" || partial.created.Before(s.partialBlocks[oldest].created) {
+				oldest = hash
+			}
+		}
+		delete(s.partialBlocks, oldest)
+	}
+
+	s.partialBlocks[hex.EncodeToString(p.block.Hash)] = p
+}
+
+// takePartialBlock removes the partial block of the hash, and returns it, or nil if it is not requested.
+func (s *Server) takePartialBlock(hash []byte) *partialBlock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := hex.EncodeToString(hash)
+	p := s.partialBlocks[key]
+	delete(s.partialBlocks, key)
+	return p
+}
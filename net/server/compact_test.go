@@ -0,0 +1,51 @@
+package server
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/hansung080/gchain/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconstructCmpctBlock(t *testing.T) {
+	t.Chdir(t.TempDir())
+	node.MiningOutput = io.Discard
+	defer func() { node.MiningOutput = os.Stdout }()
+
+	wallet := node.NewWallet(node.DefaultKeyType)
+	addr := string(wallet.GetAddress())
+	node.CreateBlockchain("a", addr).Close()
+
+	s := NewServer(Config{NodeID: "a"})
+	defer s.bc.Close()
+
+	utxoSet := &node.UTXOSet{s.bc}
+	utxoSet.Reindex()
+	known := node.NewTransaction(wallet, string(node.NewWallet(node.DefaultKeyType).GetAddress()), 3, "", nil, utxoSet)
+	missing := node.NewTransaction(wallet, string(node.NewWallet(node.DefaultKeyType).GetAddress()), 4, "", nil, utxoSet)
+	_, err := s.mempool.Add(*known)
+	assert.Nil(t, err)
+
+	tip, height := s.bc.Tip()
+	block := node.NewBlock([]*node.Transaction{known, missing, node.NewCoinbaseTx(addr, "")}, tip, height + 1)
+	payload := newCmpctBlock("peer", block)
+	assert.Len(t, payload.ShortIDs, 2)
+	assert.Len(t, payload.Prefilled, 1, "The coinbase is prefilled")
+
+	p, err := s.reconstruct(payload)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{1}, p.missing)
+	assert.Equal(t, known.ID, p.block.Txs[0].ID)
+	assert.Equal(t, block.Txs[2].ID, p.block.Txs[2].ID)
+
+	assert.NotNil(t, p.fill(nil), "The missing transactions must be filled all")
+	assert.Nil(t, p.fill([][]byte{missing.Marshal()}))
+	assert.Nil(t, checkBlock(p.block))
+	assert.Equal(t, block.Hash, node.NewProofOfWork(p.block).Hash())
+
+	payload.Prefilled[0].Index = 5
+	_, err = s.reconstruct(payload)
+	assert.IsType(t, &misbehavior{}, err)
+}
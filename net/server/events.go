@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hansung080/gchain/net/rpc"
+	"github.com/hansung080/gchain/node"
+)
+
+/**
+  @ Server-Sent Events
+    GET /events?types=<type>,...&addr=<address or pkey hash>,...
+
+    event: blockconnected
+    data: {"type":"blockconnected","block":{...}}
+
+    Without types, all the event types are sent. With addr, only the events touching one of the addresses are sent.
+    A comment line is sent every heartbeat interval to keep the connection alive.
+*/
+
+const (
+	eventsBufSize     = 64
+	heartbeatInterval = 15 * time.Second
+	pkeyHashLen       = 20
+)
+
+func handleEvents(bc *node.Blockchain) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		filter, err := newEventFilter(r.URL.Query().Get("types"), r.URL.Query().Get("addr"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		sub := bc.Events().Subscribe(filter, eventsBufSize)
+		defer bc.Events().Unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case e := <-sub.C:
+				data, err := json.Marshal(rpc.NewEventResult(e, bc.GetBestHeight()))
+				if err != nil {
+					return
+				}
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data); err != nil {
+					return
+				}
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// newEventFilter makes the filter of the comma-separated event types and addresses. An empty list passes all.
+func newEventFilter(types, addrs string) (func(node.Event) bool, error) {
+	typeSet := make(map[node.EventType]bool)
+	for _, t := range splitList(types) {
+		switch eventType := node.EventType(t); eventType {
+		case node.BlockConnected, node.BlockDisconnected, node.TxAccepted, node.TxRemoved:
+			typeSet[eventType] = true
+		default:
+			return nil, fmt.Errorf("Invalid event type: %s", t)
+		}
+	}
+
+	var pkeyHashes [][]byte
+	for _, addr := range splitList(addrs) {
+		if node.ValidateAddress(addr) {
+			pkeyHashes = append(pkeyHashes, node.GetPkeyHashFromAddress([]byte(addr)))
+		} else if pkeyHash, err := hex.DecodeString(addr); err == nil && len(pkeyHash) == pkeyHashLen {
+			pkeyHashes = append(pkeyHashes, pkeyHash)
+		} else {
+			return nil, fmt.Errorf("Invalid address or public key hash: %s", addr)
+		}
+	}
+
+	return func(e node.Event) bool {
+		if len(typeSet) > 0 && !typeSet[e.Type] {
+			return false
+		}
+
+		if len(pkeyHashes) == 0 {
+			return true
+		}
+		for _, pkeyHash := range pkeyHashes {
+			if e.Touches(pkeyHash) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -2,197 +2,585 @@ package server
 
 import (
 	"bytes"
-	"encoding/hex"
+	"crypto/tls"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
 	"net"
+	"time"
 
+	"github.com/hansung080/gchain/net/addrmgr"
+	"github.com/hansung080/gchain/net/banman"
 	"github.com/hansung080/gchain/node"
 )
 
-func handleConnection(conn net.Conn, bc *node.Blockchain) {
+func (s *Server) handleConnection(conn net.Conn) {
 	defer conn.Close()
 
-	req, err := ioutil.ReadAll(conn)
+	host := banman.Host(conn.RemoteAddr().String())
+	if s.banMgr.IsBanned(host) {
+		fmt.Printf("Disconnected banned peer: %s\n", host)
+		return
+	}
+
+	var peerID string
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		tlsConn.SetDeadline(time.Now().Add(handshakeTimeout))
+		if err := tlsConn.Handshake(); err != nil {
+			fmt.Printf("Cannot handshake with %s: %s\n", host, err)
+			return
+		}
+		tlsConn.SetDeadline(time.Time{})
+		peerID = peerIDOf(tlsConn)
+	}
+
+	req, err := ioutil.ReadAll(io.LimitReader(conn, maxMessageSize + 1))
 	if err != nil {
-		log.Panic(err)
+		fmt.Printf("Cannot read from %s: %s\n", host, err)
+		return
+	}
+
+	// a message of another network is dropped, but not punished.
+	magic := node.Params().Magic
+	if !bytes.HasPrefix(req, magic[:]) {
+		fmt.Printf("Dropped the message of another network from %s\n", host)
+		return
+	}
+	req = req[len(magic):]
+
+	if err := s.handleMessage(host, peerID, req); err != nil {
+		if m, ok := err.(*misbehavior); ok {
+			s.punish(host, peerID, m)
+		} else {
+			fmt.Printf("Cannot handle the message from %s: %s\n", host, err)
+		}
+	}
+}
+
+// handleMessage handles the message from the host, whose peer ID is empty for plaintext TCP or an anonymous peer.
+// A *misbehavior error adds the ban score to the host.
+func (s *Server) handleMessage(host, peerID string, req []byte) error {
+	if len(req) < commandLen || len(req) > maxMessageSize {
+		return misbehaving(scoreMalformed, "Malformed message of %d bytes", len(req))
 	}
 
 	cmd := bytesToCommand(req[:commandLen])
 	fmt.Printf("Received command: %s\n", cmd)
 
+	if !s.limiter.allow(host, cmd) {
+		return misbehaving(scoreRateLimited, "Rate limit exceeded: %s", cmd)
+	}
+
+	if s.tlsConfig != nil {
+		if err := s.authenticate(peerID, req); err != nil {
+			return err
+		}
+	}
+
 	switch cmd {
 	case "addr":
-		handleAddress(req)
+		return s.handleAddress(peerID, req)
 	case "block":
-		handleBlock(req, bc)
+		return s.handleBlock(req)
+	case "blocktxn":
+		return s.handleBlockTxn(req)
+	case "cmpctblock":
+		return s.handleCmpctBlock(req)
+	case "getaddr":
+		return s.handleGetAddr(peerID, req)
 	case "getblocks":
-		handleGetBlocks(req, bc)
+		return s.handleGetBlocks(req)
+	case "getblocktxn":
+		return s.handleGetBlockTxn(req)
 	case "getdata":
-		handleGetData(req, bc)
+		return s.handleGetData(req)
 	case "inv":
-		handleInventory(req, bc)
+		return s.handleInventory(req)
 	case "tx":
-		handleTx(req, bc)
+		return s.handleTx(host, peerID, req)
 	case "version":
-		handleVersion(req, bc)
+		return s.handleVersion(peerID, req)
 	default:
-		fmt.Printf("Invalid command: %s\n", cmd)
+		return misbehaving(scoreUnknownCommand, "Invalid command: %q", cmd)
+	}
+}
+
+// unmarshalPayload decodes the payload of the message. An undecodable payload is a misbehavior.
+func unmarshalPayload(req []byte, v interface{}) error {
+	if err := unmarshalGob(req[commandLen:], v); err != nil {
+		return misbehaving(scoreMalformed, "Malformed %s: %s", bytesToCommand(req[:commandLen]), err)
 	}
+	return nil
 }
 
-func handleAddress(req []byte) {
+func (s *Server) handleAddress(peerID string, req []byte) error {
 	var payload address
 
-	unmarshalGob(req[commandLen:], &payload)
-	knownAddrs = append(knownAddrs, payload.Addrs...)
-	fmt.Printf("Known addresses count: %d", len(knownAddrs))
-	requestBlocks()
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
+
+	if len(payload.Addrs) > addrmgr.MaxAddrsPerMsg {
+		return misbehaving(scoreOversized, "Too many addresses: %d", len(payload.Addrs))
+	}
+	s.markGood(payload.From, peerID)
+
+	var addrs []string
+	for _, addr := range payload.Addrs {
+		if addr != s.nodeAddr && !s.isBannedAddr(addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+
+	// a new address is not dialed here, but selected later by the outbound peers.
+	added := s.addrMgr.Add(addrs...)
+	fmt.Printf("Known addresses: %d (%d new)\n", len(s.addrMgr.Known()), len(added))
+
+	if len(added) > 0 {
+		s.savePeers()
+	}
+	return nil
 }
 
-func handleBlock(req []byte, bc *node.Blockchain) {
+func (s *Server) handleGetAddr(peerID string, req []byte) error {
+	var payload getaddr
+
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
+
+	s.markGood(payload.From, peerID)
+	s.sendAddress(payload.From)
+	return nil
+}
+
+func (s *Server) handleBlock(req []byte) error {
 	var payload block
 
-	unmarshalGob(req[commandLen:], &payload)
-	block := node.UnmarshalBlock(payload.Block)
-	fmt.Printf("Received a new block: %x", block.Hash)
-	bc.AddBlock(block)
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
+
+	block, err := node.DecodeBlock(payload.Block)
+	if err != nil {
+		return misbehaving(scoreMalformed, "Malformed block: %s", err)
+	}
 
-	if len(blocksInTransit) > 0 {
-		blockHash := blocksInTransit[0]
-		sendGetData(payload.From, "block", blockHash)
-		blocksInTransit = blocksInTransit[1:]
-	} else {
-		node.UTXOSet{bc}.Reindex()
+	if err := checkBlock(block); err != nil {
+		return err
 	}
+
+	return s.processBlock(block, payload.From)
 }
 
-func handleGetBlocks(req []byte, bc *node.Blockchain) {
+// processBlock verifies the block on its parent and adds it, and then requests the next block in transit,
+// or relays the new block to the other peers. The block of an unknown parent is not added, but the blocks are requested
+// from the peer, because the block could be verified only on its parent.
+func (s *Server) processBlock(block *node.Block, from string) error {
+	fmt.Printf("Received a new block: %x\n", block.Hash)
+
+	s.chainMu.Lock()
+	_, err := s.bc.GetBlock(block.Hash)
+	isNew := err != nil
+	parent, err := s.bc.GetBlock(block.PrevHash)
+	orphan := isNew && err != nil
+	if isNew && !orphan {
+		if err := s.verifyBlock(block, &parent); err != nil {
+			s.chainMu.Unlock()
+			s.clearInTransit()
+			return err
+		}
+
+		oldTip, _ := s.bc.Tip()
+		s.bc.AddBlock(block)
+		s.updateChainState(block, oldTip)
+	}
+	nextHash := s.nextInTransit()
+	s.chainMu.Unlock()
+
+	if orphan {
+		s.clearInTransit()
+		s.sendGetBlocks(from)
+	} else if nextHash != nil {
+		s.sendGetData(from, "block", nextHash)
+	} else if s.takeMoreBlocks() {
+		s.sendGetBlocks(from)
+	} else if isNew {
+		// a new block out of the sync is relayed to the other peers.
+		for _, addr := range s.outboundPeers(from) {
+			s.sendCmpctBlock(addr, block)
+		}
+	}
+	return nil
+}
+
+// verifyBlock verifies the block from a peer on its parent, such as the signatures, the spent outputs and the coinbase.
+func (s *Server) verifyBlock(block, parent *node.Block) error {
+	if block.Height != parent.Height + 1 {
+		return misbehaving(scoreInvalid, "Invalid block %x: height %d on parent height %d", block.Hash, block.Height, parent.Height)
+	}
+
+	failures := append(s.bc.VerifyBlock(block), s.bc.VerifyBlockValues(block)...)
+	if len(failures) > 0 {
+		return misbehaving(scoreInvalid, "Invalid block %x: %s", block.Hash, failures[0])
+	}
+	return nil
+}
+
+// updateChainState updates the UTXO set and the mempool for the block added. The block extending the old tip is applied
+// incrementally. The UTXO set is rebuilt if the block moves the tip to another branch, and the transactions of the disconnected
+// blocks return to the mempool. The block on a side branch changes nothing.
+// The history index is not updated here, because it follows the block events by itself.
+func (s *Server) updateChainState(block *node.Block, oldTip []byte) {
+	if tip, _ := s.bc.Tip(); !bytes.Equal(tip, block.Hash) {
+		return
+	}
+
+	if bytes.Equal(block.PrevHash, oldTip) {
+		node.UTXOSet{s.bc}.Update(block)
+		s.mempool.RemoveBlockTxs(block)
+		return
+	}
+
+	node.UTXOSet{s.bc}.Reindex()
+	s.mempool.Reorg(s.bc.FindFork(oldTip, block))
+}
+
+func (s *Server) handleBlockTxn(req []byte) error {
+	var payload blocktxn
+
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
+
+	// the transactions not requested are ignored.
+	p := s.takePartialBlock(payload.Hash)
+	if p == nil {
+		return nil
+	}
+
+	if err := p.fill(payload.Txs); err != nil {
+		return err
+	}
+
+	return s.processCmpctBlock(p)
+}
+
+func (s *Server) handleCmpctBlock(req []byte) error {
+	var payload cmpctblock
+
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
+
+	if len(payload.ShortIDs) + len(payload.Prefilled) > maxInvItems {
+		return misbehaving(scoreOversized, "Too many compact block transactions: %d", len(payload.ShortIDs) + len(payload.Prefilled))
+	}
+
+	if _, err := s.bc.GetBlock(payload.Hash); err == nil {
+		return nil
+	}
+
+	p, err := s.reconstruct(payload)
+	if err != nil {
+		return err
+	}
+
+	if len(p.missing) > 0 {
+		fmt.Printf("Requesting %d missing transactions of block %x\n", len(p.missing), payload.Hash)
+		s.addPartialBlock(p)
+		s.sendGetBlockTxn(payload.From, payload.Hash, p.missing)
+		return nil
+	}
+
+	return s.processCmpctBlock(p)
+}
+
+// processCmpctBlock processes the reconstructed block. The full block is requested instead, if the block is invalid,
+// because a short ID could match the other transaction in the mempool.
+func (s *Server) processCmpctBlock(p *partialBlock) error {
+	if err := checkBlock(p.block); err != nil {
+		fmt.Printf("Cannot reconstruct block %x: %s\n", p.block.Hash, err)
+		s.sendGetData(p.from, "block", p.block.Hash)
+		return nil
+	}
+
+	return s.processBlock(p.block, p.from)
+}
+
+// nextInTransit pops the hash of the next block to request, or returns nil if no block is in transit.
+func (s *Server) nextInTransit() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.blocksInTransit) == 0 {
+		return nil
+	}
+
+	blockHash := s.blocksInTransit[0]
+	s.blocksInTransit = s.blocksInTransit[1:]
+	return blockHash
+}
+
+// clearInTransit drops the blocks in transit, whose parents are not added.
+func (s *Server) clearInTransit() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blocksInTransit = [][]byte{}
+	s.moreBlocks = false
+}
+
+// takeMoreBlocks reports whether more blocks follow the blocks in transit received all, and resets it.
+func (s *Server) takeMoreBlocks() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	more := s.moreBlocks
+	s.moreBlocks = false
+	return more
+}
+
+// checkBlock checks the block without the blockchain, before the block is reconstructed or verified on its parent.
+func checkBlock(block *node.Block) error {
+	if len(block.Txs) == 0 {
+		return misbehaving(scoreInvalid, "Invalid block %x: no transaction", block.Hash)
+	}
+
+	pow := node.NewProofOfWork(block)
+	if !bytes.Equal(pow.Hash(), block.Hash) {
+		return misbehaving(scoreInvalid, "Invalid block %x: hash mismatch", block.Hash)
+	}
+	if !pow.Validate() {
+		return misbehaving(scoreInvalid, "Invalid block %x: proof of work", block.Hash)
+	}
+
+	return nil
+}
+
+func (s *Server) handleGetBlocks(req []byte) error {
 	var payload getblocks
 
-	unmarshalGob(req[commandLen:], &payload)
-	blockHashes := bc.GetBlockHashes()
-	sendInventory(payload.From, "block", blockHashes)
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
+
+	if len(payload.Locator) > maxLocatorSize {
+		return misbehaving(scoreOversized, "Too many locator hashes: %d", len(payload.Locator))
+	}
+
+	// the inventory is capped, and the peer requests the next blocks with its new locator after getting these.
+	blockHashes := s.bc.BlockHashesAfter(payload.Locator, maxInvItems)
+	if len(blockHashes) > 0 {
+		s.sendInventory(payload.From, "block", blockHashes)
+	}
+	return nil
 }
 
-func handleGetData(req []byte, bc *node.Blockchain) {
-	var payload getdata
+func (s *Server) handleGetBlockTxn(req []byte) error {
+	var payload getblocktxn
 
-	unmarshalGob(req[commandLen:], &payload)
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
 
-	if payload.Type == "block" {
-		block, err := bc.GetBlock(payload.ID)
-		if err != nil {
-			return
+	block, err := s.bc.GetBlock(payload.Hash)
+	if err != nil {
+		return nil
+	}
+
+	if len(payload.Indexes) > len(block.Txs) {
+		return misbehaving(scoreOversized, "Too many getblocktxn indexes: %d", len(payload.Indexes))
+	}
+
+	var txs [][]byte
+	for _, i := range payload.Indexes {
+		if i < 0 || i >= len(block.Txs) {
+			return misbehaving(scoreInvalid, "Invalid getblocktxn index: %d", i)
 		}
+		txs = append(txs, block.Txs[i].Marshal())
+	}
+
+	s.sendBlockTxn(payload.From, payload.Hash, txs)
+	return nil
+}
+
+func (s *Server) handleGetData(req []byte) error {
+	var payload getdata
+
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
+
+	if len(payload.Items) > maxInvItems {
+		return misbehaving(scoreOversized, "Too many getdata items: %d", len(payload.Items))
+	}
 
-		sendBlock(payload.From, &block)
+	// the unknown items are skipped.
+	for _, id := range payload.Items {
+		if payload.Type == "block" {
+			block, err := s.bc.GetBlock(id)
+			if err != nil {
+				continue
+			}
+
+			s.sendBlock(payload.From, &block)
+
+		} else if payload.Type == "tx" {
+			entry, exist := s.mempool.Get(id)
+			if !exist {
+				continue
+			}
 
-	} else if payload.Type == "tx" {
-		txid := hex.EncodeToString(payload.ID)
-		tx := mempool[txid]
-		sendTx(payload.From, &tx)
-		//delete(mempool, txid)
+			s.relay.markKnown(payload.From, id)
+			s.sendTx(payload.From, &entry.Tx)
+		}
 	}
+
+	return nil
 }
 
-func handleInventory(req []byte, bc *node.Blockchain) {
+func (s *Server) handleInventory(req []byte) error {
 	var payload inventory
 
-	unmarshalGob(req[commandLen:], &payload)
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
+
 	fmt.Printf("Received inventory: type: %s, items: %d\n", payload.Type, len(payload.Items))
+	if len(payload.Items) > maxInvItems {
+		return misbehaving(scoreOversized, "Too many inventory items: %d", len(payload.Items))
+	}
 
 	if payload.Type == "block" {
-		blocksInTransit = payload.Items
-		blockHash := payload.Items[0]
-		sendGetData(payload.From, "block", blockHash)
+		// the blocks already known are not requested, so that a relayed block does not come back.
+		// the items are from the newest, and the blocks are requested from the oldest, to be verified on their parents.
+		var items [][]byte
+		for i := len(payload.Items) - 1; i >= 0; i-- {
+			if _, err := s.bc.GetBlock(payload.Items[i]); err != nil {
+				items = append(items, payload.Items[i])
+			}
+		}
+		if len(items) == 0 {
+			return nil
+		}
 
-		newInTransit := [][]byte{}
-		for _, b := range blocksInTransit {
+		// the first block is requested now, and the others are requested one by one as each block arrives.
+		blockHash := items[0]
+		s.mu.Lock()
+		s.blocksInTransit = [][]byte{}
+		s.moreBlocks = len(payload.Items) == maxInvItems
+		for _, b := range items {
 			if bytes.Compare(b, blockHash) != 0 {
-				newInTransit = append(newInTransit, b)
+				s.blocksInTransit = append(s.blocksInTransit, b)
 			}
 		}
+		s.mu.Unlock()
 
-		blocksInTransit = newInTransit
+		s.sendGetData(payload.From, "block", blockHash)
 
-	} else if payload.Type == "tx" {
-		txid := payload.Items[0]
-		if _, exist := mempool[hex.EncodeToString(txid)]; !exist {
-			sendGetData(payload.From, "tx", txid)
+	} else if payload.Type == "tx" && !s.cfg.BlocksOnly {
+		// all the unknown transactions are requested by one getdata.
+		var txids [][]byte
+		for _, txid := range payload.Items {
+			s.relay.markKnown(payload.From, txid)
+			if !s.mempool.Has(txid) {
+				txids = append(txids, txid)
+			}
+		}
+
+		if len(txids) > 0 {
+			s.sendGetData(payload.From, "tx", txids...)
 		}
 	}
+
+	return nil
 }
 
-func handleTx(req []byte, bc *node.Blockchain) {
+func (s *Server) handleTx(host, peerID string, req []byte) error {
 	var payload transaction
 
-	unmarshalGob(req[commandLen:], &payload)
-	tx := node.UnmarshalTx(payload.Tx)
-	mempool[hex.EncodeToString(tx.ID)] = tx
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
 
-	if nodeAddr == knownAddrs[0] {
-		for _, addr := range knownAddrs {
-			if addr != nodeAddr && addr != payload.From {
-				sendInventory(addr, "tx", [][]byte{tx.ID})
-			}
-		}
-	} else {
-		if len(mempool) >= 2 && len(minerAddr) > 0 {
-		MineTransactions:
-			var txs []*node.Transaction
-			for id := range mempool {
-				tx := mempool[id]
-				if bc.VerifyTx(&tx) {
-					txs = append(txs, &tx)
-				}
-			}
+	tx, err := node.DecodeTx(payload.Tx)
+	if err != nil {
+		return misbehaving(scoreMalformed, "Malformed transaction: %s", err)
+	}
 
-			if len(txs) < 1 {
-				fmt.Println("All transactions are failed to verify.")
-				return
-			}
+	// a transaction sent from outside of the network, such as CLI, is accepted by a blocks-only node too.
+	// It is decided by the connection, because any peer could send a message without the From address.
+	if s.cfg.BlocksOnly && !s.isLocalConn(host, peerID) {
+		return nil
+	}
 
-			coinbase := node.NewCoinbaseTx(minerAddr, "")
-			txs = append(txs, coinbase)
+	// only an invalid transaction is a misbehavior, because the others could be rejected by the mempool state.
+	if err := s.processTx(tx, payload.From); err != nil {
+		if err == node.ErrInvalidTx || err == node.ErrTxVerification {
+			return misbehaving(scoreInvalid, "Invalid transaction %x: %s", tx.ID, err)
+		}
+		fmt.Printf("Rejected transaction %x: %s\n", tx.ID, err)
+	}
 
-			newBlock := bc.MineBlock(txs)
-			node.UTXOSet{bc}.Reindex()
-			fmt.Println("Mined a new block.")
+	return nil
+}
 
-			for _, tx := range txs {
-				delete(mempool, hex.EncodeToString(tx.ID))
-			}
+// isLocalConn reports whether the connection is from outside of the network, such as CLI:
+// from a loopback host, or anonymous over TLS, where every peer presents its node key.
+func (s *Server) isLocalConn(host, peerID string) bool {
+	return isLoopbackHost(host) || (s.tlsConfig != nil && peerID == "")
+}
 
-			for _, addr := range knownAddrs {
-				if addr != nodeAddr {
-					sendInventory(addr, "block", [][]byte{newBlock.Hash})
-				}
-			}
+// processTx adds the transaction into the mempool, and then queues it to relay to the other peers.
+// The miner is notified of the transaction accepted by the mempool.
+func (s *Server) processTx(tx node.Transaction, from string) error {
+	replaced, err := s.mempool.Add(tx)
+	if err != nil {
+		return err
+	}
 
-			if len(mempool) > 0 {
-				goto MineTransactions
-			}
-		}
+	for _, r := range replaced {
+		fmt.Printf("Replaced transaction %x by %x\n", r.ID, tx.ID)
+	}
+
+	// the transaction is announced to the peers on their trickles, except the peer which sent it.
+	if from != "" {
+		s.relay.markKnown(from, tx.ID)
+	}
+	for _, addr := range s.outboundPeers(from) {
+		s.relay.queue(addr, tx.ID)
 	}
+
+	return nil
 }
 
-func handleVersion(req []byte, bc *node.Blockchain) {
+func (s *Server) handleVersion(peerID string, req []byte) error {
 	var payload version
 
-	unmarshalGob(req[commandLen:], &payload)
-	myHeight := bc.GetBestHeight()
+	if err := unmarshalPayload(req, &payload); err != nil {
+		return err
+	}
+
+	myHeight := s.bc.GetBestHeight()
 	yourHeight := payload.BestHeight
 
 	if myHeight < yourHeight {
-		sendGetBlocks(payload.From)
+		s.sendGetBlocks(payload.From)
 	} else if myHeight > yourHeight {
-		sendVersion(payload.From, bc)
+		s.sendVersion(payload.From)
+	}
+
+	if s.isBannedAddr(payload.From) {
+		return nil
 	}
 
-	//sendAddress(payload.From)
-	if !isNodeKnown(payload.From) {
-		knownAddrs = append(knownAddrs, payload.From)
+	// a new peer gets the known addresses at once, instead of waiting for its getaddr.
+	isNew := len(s.addrMgr.Add(payload.From)) > 0
+	s.markGood(payload.From, peerID)
+	if isNew {
+		s.sendAddress(payload.From)
+		s.savePeers()
 	}
+	return nil
 }
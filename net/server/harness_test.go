@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hansung080/gchain/node"
+	"github.com/stretchr/testify/assert"
+)
+
+/**
+  @ Test Network
+    testNetwork runs N nodes in the test process on regtest, which mine the blocks at once by generate.
+    - The nodes listen on the ephemeral ports, and keep their data files in the temporary directory of the test.
+    - Every node starts from the same genesis block, whose reward goes to the wallet of the network,
+      and the node i is seeded with the nodes before it.
+    - partition cuts the links between the groups of the nodes by dropping the messages across them,
+      and heal restores the links, and lets the nodes exchange their versions as if they reconnect.
+*/
+
+const convergeTimeout = 20 * time.Second
+
+type testNetwork struct {
+	t      *testing.T
+	nodes  []*Server
+	wallet *node.Wallet // gets the genesis reward and the block rewards
+
+	mu     sync.Mutex
+	groups map[string]int // the partition group of each node address, or nil if not partitioned
+}
+
+func newTestNetwork(t *testing.T, n int) *testNetwork {
+	t.Chdir(t.TempDir())
+	node.MiningOutput = io.Discard
+	assert.Nil(t, node.SelectNetwork(node.RegTestParams.Name))
+	t.Cleanup(func() {
+		node.SelectNetwork(node.MainNetParams.Name)
+		node.MiningOutput = os.Stdout
+	})
+
+	tn := &testNetwork{t: t, wallet: node.NewWallet(node.DefaultKeyType)}
+	bc := node.CreateBlockchain("0", tn.addr())
+	node.UTXOSet{bc}.Reindex()
+	bc.Close()
+
+	var seeds []string
+	for i := 0; i < n; i++ {
+		nodeID := fmt.Sprint(i)
+		if i > 0 {
+			copyFile(t, node.Params().DataFile("blockchain_%s.db", "0"), node.Params().DataFile("blockchain_%s.db", nodeID))
+		}
+
+		s := NewServer(Config{NodeID: nodeID, ListenAddr: "127.0.0.1:0", Seeds: seeds})
+		dial := s.dialer
+		s.dialer = func(addr string, resp []byte) error {
+			// the message across the partition is lost silently, not to back off the peer after heal.
+			if !tn.linked(s.Addr(), addr) {
+				return nil
+			}
+			return dial(addr, resp)
+		}
+
+		s.Start()
+		tn.nodes = append(tn.nodes, s)
+		seeds = append(seeds, s.Addr())
+	}
+
+	t.Cleanup(func() {
+		for i := len(tn.nodes) - 1; i >= 0; i-- {
+			tn.nodes[i].Stop()
+		}
+	})
+
+	tn.waitConverged()
+	return tn
+}
+
+func (tn *testNetwork) addr() string {
+	return string(tn.wallet.GetAddress())
+}
+
+func (tn *testNetwork) linked(from, to string) bool {
+	tn.mu.Lock()
+	defer tn.mu.Unlock()
+
+	fromGroup, fromExist := tn.groups[from]
+	toGroup, toExist := tn.groups[to]
+	return !fromExist || !toExist || fromGroup == toGroup
+}
+
+// partition cuts the links between the groups of the node indexes. The nodes not in any group are linked to all.
+func (tn *testNetwork) partition(groups ...[]int) {
+	tn.mu.Lock()
+	defer tn.mu.Unlock()
+
+	tn.groups = make(map[string]int)
+	for g, group := range groups {
+		for _, i := range group {
+			tn.groups[tn.nodes[i].Addr()] = g
+		}
+	}
+}
+
+func (tn *testNetwork) heal() {
+	tn.mu.Lock()
+	tn.groups = nil
+	tn.mu.Unlock()
+
+	for _, s := range tn.nodes {
+		for _, peer := range tn.nodes {
+			if peer != s {
+				s.sendVersion(peer.Addr())
+			}
+		}
+	}
+}
+
+// generate mines n blocks on the node i, and returns the tip.
+func (tn *testNetwork) generate(i, n int) []byte {
+	hashes, err := tn.nodes[i].Generate(n, tn.addr())
+	assert.Nil(tn.t, err)
+	assert.Len(tn.t, hashes, n)
+	return hashes[len(hashes) - 1]
+}
+
+// sendTx sends the amount from the wallet of the network to the address, through the node i as CLI does.
+func (tn *testNetwork) sendTx(i int, to string, amount int) *node.Transaction {
+	tx := node.NewTransaction(tn.wallet, to, amount, "", nil, &node.UTXOSet{tn.nodes[i].bc})
+	assert.Nil(tn.t, SendTx(tn.nodes[i].Addr(), tx, ""))
+	return tx
+}
+
+// waitMempool waits until the transaction reaches the mempools of the nodes.
+func (tn *testNetwork) waitMempool(txid []byte, indexes ...int) {
+	tn.t.Helper()
+
+	assert.Eventually(tn.t, func() bool {
+		for _, i := range tn.indexes(indexes) {
+			if !tn.nodes[i].mempool.Has(txid) {
+				return false
+			}
+		}
+		return true
+	}, convergeTimeout, 50 * time.Millisecond, "The transaction %x reaches the mempools", txid)
+}
+
+// waitConverged waits until the nodes, or all the nodes by default, have the same tip and the same UTXO set.
+func (tn *testNetwork) waitConverged(indexes ...int) {
+	tn.t.Helper()
+
+	if !assert.Eventually(tn.t, func() bool {
+		converged, _ := tn.tips(indexes)
+		return converged
+	}, convergeTimeout, 50 * time.Millisecond) {
+		_, tips := tn.tips(indexes)
+		tn.t.Fatalf("The nodes do not converge: %v", tips)
+	}
+}
+
+// tips returns whether the nodes have the same tip and the same UTXO set, and their tips.
+func (tn *testNetwork) tips(indexes []int) (bool, []string) {
+	var tips []string
+	var firstTip, firstUTXOs []byte
+	converged := true
+	for n, i := range tn.indexes(indexes) {
+		tip, height := tn.nodes[i].bc.Tip()
+		utxos := node.UTXOSet{tn.nodes[i].bc}.Hash()
+		tips = append(tips, fmt.Sprintf("node %d: %x at %d", i, tip, height))
+
+		if n == 0 {
+			firstTip, firstUTXOs = tip, utxos
+		} else if !bytes.Equal(tip, firstTip) || !bytes.Equal(utxos, firstUTXOs) {
+			converged = false
+		}
+	}
+
+	return converged, tips
+}
+
+func (tn *testNetwork) indexes(indexes []int) []int {
+	if len(indexes) > 0 {
+		return indexes
+	}
+
+	for i := range tn.nodes {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// balance returns the balance of the address in the UTXO set of the node i.
+func (tn *testNetwork) balance(i int, addr string) int {
+	balance := 0
+	for _, out := range (node.UTXOSet{tn.nodes[i].bc}).FindUTXOs(node.GetPkeyHashFromAddress([]byte(addr))) {
+		balance += out.Value
+	}
+	return balance
+}
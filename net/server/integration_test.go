@@ -0,0 +1,60 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/hansung080/gchain/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkSyncsBlocksAndTxs(t *testing.T) {
+	tn := newTestNetwork(t, 3)
+
+	tip := tn.generate(0, 3)
+	tn.waitConverged()
+	for _, s := range tn.nodes {
+		assert.Equal(t, 3, s.bc.GetBestHeight())
+	}
+
+	to := string(node.NewWallet(node.DefaultKeyType).GetAddress())
+	tx := tn.sendTx(2, to, 4)
+	tn.waitMempool(tx.ID)
+
+	tip = tn.generate(1, 1)
+	tn.waitConverged()
+	for i, s := range tn.nodes {
+		current, _ := s.bc.Tip()
+		assert.Equal(t, tip, current)
+		assert.Equal(t, 4, tn.balance(i, to))
+		assert.False(t, s.mempool.Has(tx.ID), "The mined transaction leaves the mempool of node %d", i)
+	}
+}
+
+func TestNetworkReorgsAfterPartition(t *testing.T) {
+	tn := newTestNetwork(t, 4)
+	tn.generate(0, 1)
+	tn.waitConverged()
+
+	tn.partition([]int{0, 1}, []int{2, 3})
+
+	to := string(node.NewWallet(node.DefaultKeyType).GetAddress())
+	tx := tn.sendTx(0, to, 4)
+	tn.waitMempool(tx.ID, 0, 1)
+	tn.generate(0, 2)
+	tn.waitConverged(0, 1)
+	assert.Equal(t, 4, tn.balance(1, to))
+
+	longest := tn.generate(2, 3)
+	tn.waitConverged(2, 3)
+	assert.Equal(t, 0, tn.balance(3, to), "The transaction is not seen across the partition")
+
+	tn.heal()
+	tn.waitConverged()
+	for i, s := range tn.nodes {
+		tip, height := s.bc.Tip()
+		assert.Equal(t, longest, tip, "Node %d reorganizes to the longest chain", i)
+		assert.Equal(t, 4, height)
+		assert.Equal(t, 0, tn.balance(i, to), "The transaction of the shorter chain is disconnected")
+	}
+	tn.waitMempool(tx.ID, 0, 1)
+}
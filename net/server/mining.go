@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+
+	"github.com/hansung080/gchain/node"
+)
+
+/**
+  @ External Mining
+    An external miner gets the work of a block template, searches the nonce only with its header, and then submits the nonce.
+
+    miner                             node
+      | -- getwork [addr] ------------> | keeps the template by the work ID
+      | <- workid, header, target ----- |
+      |                                 |
+      | (searches the nonce)            |
+      |                                 |
+      | -- submitblock workid nonce --> | completes the block of the template, and connects it
+      | <- block hash ----------------- |
+
+    The works on the old tip are dropped, because their blocks would be stale.
+*/
+
+const maxWorks = 16
+
+var (
+	errStaleBlock      = errors.New("Stale block: the tip has changed")
+	errUnknownWork     = errors.New("Unknown work: it could be stale")
+	errNotMineOnDemand = errors.New("Blocks are generated only on regtest")
+)
+
+// BlockTemplate makes the block template on the tip, with the transactions selected from the mempool
+// and the coinbase sending the block reward to addr.
+func (s *Server) BlockTemplate(addr string) *node.Block {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	tip, height := s.bc.Tip()
+	txs, fee := s.mempool.SelectTxs(maxBlockTxsSize)
+	txs = append(txs, node.NewCoinbaseTxWithFee(addr, "", height + 1, fee))
+	return node.NewBlockTemplate(txs, tip, height + 1)
+}
+
+// SubmitBlock connects the mined block on the tip, and then announces it to the peers by the compact block.
+func (s *Server) SubmitBlock(block *node.Block) error {
+	if err := checkBlock(block); err != nil {
+		return err
+	}
+
+	if err := s.connectBlock(block); err != nil {
+		return err
+	}
+
+	for _, addr := range s.outboundPeers() {
+		s.sendCmpctBlock(addr, block)
+	}
+	return nil
+}
+
+func (s *Server) connectBlock(block *node.Block) error {
+	s.chainMu.Lock()
+	defer s.chainMu.Unlock()
+
+	tip, height := s.bc.Tip()
+	if !bytes.Equal(block.PrevHash, tip) || block.Height != height + 1 {
+		return errStaleBlock
+	}
+
+	// the transactions failed are evicted from the mempool, so that the next template does not fail again.
+	failures := append(s.bc.VerifyBlock(block), s.bc.VerifyBlockValues(block)...)
+	if len(failures) > 0 {
+		var txids [][]byte
+		for _, failure := range failures {
+			var txErr *node.TxError
+			if errors.As(failure, &txErr) {
+				txids = append(txids, txErr.Txid)
+			}
+		}
+		s.mempool.RemoveInvalid(txids...)
+		return failures[0]
+	}
+
+	s.bc.AddBlock(block)
+	s.updateChainState(block, tip)
+	return nil
+}
+
+// Generate mines n blocks on the tip at once, sending the block rewards to addr, for the tests on regtest.
+// It stops at the first block failed, such as stale by a block from a peer, and returns the hashes of the blocks mined before.
+func (s *Server) Generate(n int, addr string) ([][]byte, error) {
+	if !node.Params().MineOnDemand {
+		return nil, errNotMineOnDemand
+	}
+
+	var hashes [][]byte
+	for i := 0; i < n; i++ {
+		block := s.BlockTemplate(addr)
+		block.Nonce, block.Hash = node.NewProofOfWork(block).Run()
+		if err := s.SubmitBlock(block); err != nil {
+			return hashes, err
+		}
+		hashes = append(hashes, block.Hash)
+	}
+
+	return hashes, nil
+}
+
+// addWork keeps the template for submitWork, and returns its work ID, which is its merkle root.
+// The works on the old tip are dropped, and the oldest one is dropped over maxWorks.
+func (s *Server) addWork(template *node.Block) string {
+	workID := hex.EncodeToString(template.HashTxs())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var oldest string
+	for id, work := range s.works {
+		if !bytes.Equal(work.PrevHash, template.PrevHash) {
+			delete(s.works, id)
+		} else if oldest == "" || work.Timestamp < s.works[oldest].Timestamp {
+			oldest = id
+		}
+	}
+
+	if len(s.works) >= maxWorks {
+		delete(s.works, oldest)
+	}
+
+	s.works[workID] = template
+	return workID
+}
+
+// submitWork submits the block of the work mined with the nonce. The work is kept until its block is connected,
+// because an invalid nonce could be submitted.
+func (s *Server) submitWork(workID string, nonce int) (*node.Block, error) {
+	s.mu.Lock()
+	work, exist := s.works[workID]
+	s.mu.Unlock()
+
+	if !exist {
+		return nil, errUnknownWork
+	}
+
+	block := *work
+	block.Nonce = nonce
+	block.Hash = node.NewProofOfWork(&block).Hash()
+	if err := s.SubmitBlock(&block); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	delete(s.works, workID)
+	s.mu.Unlock()
+
+	return &block, nil
+}
@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/hansung080/gchain/net/rpc"
+	"github.com/hansung080/gchain/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWorkAndSubmitBlock(t *testing.T) {
+	t.Chdir(t.TempDir())
+	node.MiningOutput = io.Discard
+	defer func() { node.MiningOutput = os.Stdout }()
+
+	addr := string(node.NewWallet(node.DefaultKeyType).GetAddress())
+	createTestBlockchain("a", addr)
+
+	s := NewServer(Config{NodeID: "a", Miner: addr})
+	defer s.bc.Close()
+
+	work, nonce := getWork(t, s)
+	stale, staleNonce := getWork(t, s)
+	assert.Equal(t, 1, work.Height)
+	assert.Equal(t, 0, work.Txs)
+
+	_, err := s.rpcSubmitBlock(json.RawMessage(fmt.Sprintf(`["%s", %d]`, work.WorkID, nonce + 1)))
+	assert.NotNil(t, err, "The hash of the wrong nonce is not less than the target")
+
+	hash, err := s.rpcSubmitBlock(json.RawMessage(fmt.Sprintf(`["%s", %d]`, work.WorkID, nonce)))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, s.bc.GetBestHeight())
+
+	block, err := s.bc.GetBlockByHeight(1)
+	assert.Nil(t, err)
+	assert.Equal(t, hex.EncodeToString(block.Hash), hash)
+	assert.Empty(t, s.bc.VerifyBlock(&block))
+
+	_, err = s.rpcSubmitBlock(json.RawMessage(fmt.Sprintf(`["%s", %d]`, work.WorkID, nonce)))
+	assert.Equal(t, errUnknownWork, err, "The work is dropped after its block is connected")
+
+	_, err = s.rpcSubmitBlock(json.RawMessage(fmt.Sprintf(`["%s", %d]`, stale.WorkID, staleNonce)))
+	assert.Equal(t, errStaleBlock, err)
+}
+
+// getWork gets the work from the server, and finds its nonce as an external miner.
+func getWork(t *testing.T, s *Server) (rpc.WorkResult, int) {
+	result, err := s.rpcGetWork(nil)
+	assert.Nil(t, err)
+	work := result.(rpc.WorkResult)
+
+	header, err := hex.DecodeString(work.Header)
+	assert.Nil(t, err)
+	target, ok := new(big.Int).SetString(work.Target, 16)
+	assert.True(t, ok)
+
+	nonce, _, found := node.FindNonce(header, target, nil)
+	assert.True(t, found)
+	return work, nonce
+}
+
+func TestGenerate(t *testing.T) {
+	t.Chdir(t.TempDir())
+	node.MiningOutput = io.Discard
+	defer func() { node.MiningOutput = os.Stdout }()
+
+	addr := string(node.NewWallet(node.DefaultKeyType).GetAddress())
+	createTestBlockchain("a", addr)
+	s := NewServer(Config{NodeID: "a"})
+	_, err := s.Generate(1, addr)
+	assert.Equal(t, errNotMineOnDemand, err)
+	s.bc.Close()
+
+	assert.Nil(t, node.SelectNetwork(node.RegTestParams.Name))
+	defer node.SelectNetwork(node.MainNetParams.Name)
+
+	addr = string(node.NewWallet(node.DefaultKeyType).GetAddress())
+	createTestBlockchain("a", addr)
+	s = NewServer(Config{NodeID: "a"})
+	defer s.bc.Close()
+
+	hashes, err := s.Generate(3, addr)
+	assert.Nil(t, err)
+	assert.Len(t, hashes, 3)
+	assert.Equal(t, 3, s.bc.GetBestHeight())
+
+	tip, _ := s.bc.Tip()
+	assert.Equal(t, hashes[2], tip)
+
+	utxos := node.UTXOSet{s.bc}.Hash()
+	node.UTXOSet{s.bc}.Reindex()
+	assert.Equal(t, utxos, node.UTXOSet{s.bc}.Hash(), "The UTXO set updated by each block is the same as rebuilt")
+}
+
+func TestSubmitBlockEvictsInvalidTxs(t *testing.T) {
+	t.Chdir(t.TempDir())
+	node.MiningOutput = io.Discard
+	defer func() { node.MiningOutput = os.Stdout }()
+
+	assert.Nil(t, node.SelectNetwork(node.RegTestParams.Name))
+	defer node.SelectNetwork(node.MainNetParams.Name)
+
+	wallet := node.NewWallet(node.DefaultKeyType)
+	addr := string(wallet.GetAddress())
+	createTestBlockchain("a", addr)
+	s := NewServer(Config{NodeID: "a"})
+	defer s.bc.Close()
+
+	utxoSet := node.UTXOSet{s.bc}
+	to := string(node.NewWallet(node.DefaultKeyType).GetAddress())
+	tx := node.NewTransaction(wallet, to, 1, "", nil, &utxoSet)
+	_, err := s.mempool.Add(*tx)
+	assert.Nil(t, err)
+
+	// the output spent by tx is spent in a block connected without the server, so that tx fails in the next template.
+	double := node.NewTransaction(wallet, to, 2, "", nil, &utxoSet)
+	utxoSet.Update(s.bc.MineBlock([]*node.Transaction{node.NewCoinbaseTx(addr, ""), double}))
+
+	_, err = s.Generate(1, addr)
+	assert.NotNil(t, err)
+	assert.False(t, s.mempool.Has(tx.ID), "The failed transaction is evicted from the mempool")
+
+	hashes, err := s.Generate(1, addr)
+	assert.Nil(t, err)
+	assert.Len(t, hashes, 1, "The next template does not fail again")
+}
+
+// createTestBlockchain creates the blockchain with its UTXO set, as createblockchain does.
+func createTestBlockchain(nodeID, addr string) {
+	bc := node.CreateBlockchain(nodeID, addr)
+	node.UTXOSet{bc}.Reindex()
+	bc.Close()
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hansung080/gchain/net/banman"
+	"github.com/hansung080/gchain/node"
+)
+
+/**
+  @ Misbehavior
+    A peer gets the ban score for each misbehavior, and its host is banned for a day at 100.
+    A banned host is disconnected at once, and is neither sent to nor selected as a peer.
+    The loopback hosts and the allowed peers are never scored, because a ban of their host
+    would cut the local CLI and the other nodes on the same host, or the private peers, by a single bad message.
+
+    misbehavior                                          score
+    malformed message: too short, too large, bad gob     100
+    invalid block: hash mismatch, proof of work          100
+    invalid transaction: structure, signature            100
+    too many items in inv, getdata, addr or cmpctblock   20
+    unknown command                                      10
+    message over the rate limit of its command           1 (the message is dropped)
+*/
+
+const (
+	scoreMalformed      = 100
+	scoreInvalid        = 100
+	scoreOversized      = 20
+	scoreUnknownCommand = 10
+	scoreRateLimited    = 1
+
+	bansFile       = "bans_%s.json"
+	maxMessageSize = 32 << 20
+	maxInvItems    = 50000
+	maxLocatorSize = 101
+	maxRateBuckets = 10000
+)
+
+// rateLimits are the messages per second and the burst of each command, allowed to a host.
+var rateLimits = map[string]struct{ rate, burst float64 }{
+	"addr":        {1, 10},
+	"block":       {50, 500},
+	"blocktxn":    {50, 500},
+	"cmpctblock":  {50, 500},
+	"getaddr":     {1, 10},
+	"getblocks":   {1, 10},
+	"getblocktxn": {50, 500},
+	"getdata":     {50, 500},
+	"inv":         {20, 200},
+	"tx":          {20, 200},
+	"version":     {1, 20},
+}
+
+// misbehavior is the error of the message, which adds the score to the peer sent it.
+type misbehavior struct {
+	score  int
+	reason string
+}
+
+func (m *misbehavior) Error() string {
+	return m.reason
+}
+
+func misbehaving(score int, format string, a ...interface{}) error {
+	return &misbehavior{score: score, reason: fmt.Sprintf(format, a...)}
+}
+
+// punish adds the score of the misbehavior to the host, and saves the bans when the host is banned.
+// The loopback hosts and the allowed peers are exempt from the score.
+func (s *Server) punish(host, peerID string, m *misbehavior) {
+	if isLoopbackHost(host) || (peerID != "" && s.isAllowedPeer(peerID)) {
+		fmt.Printf("Misbehaving exempt peer %s: %s\n", host, m.reason)
+		return
+	}
+
+	fmt.Printf("Misbehaving peer %s (+%d): %s\n", host, m.score, m.reason)
+	if s.banMgr.Misbehaving(host, m.score, m.reason) {
+		fmt.Printf("Banned peer %s for %s\n", host, banman.BanDuration)
+		s.saveBans()
+	}
+}
+
+func isLoopbackHost(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) saveBans() {
+	if err := s.banMgr.Save(); err != nil {
+		fmt.Printf("Cannot save bans: %s\n", err)
+	}
+}
+
+// BansFile returns the file of the bans of the node.
+func BansFile(nodeID string) string {
+	return node.Params().DataFile(bansFile, nodeID)
+}
+
+// isBannedAddr checks the host of the address, and the IPs of the host name too, such as localhost.
+func (s *Server) isBannedAddr(addr string) bool {
+	host := banman.Host(addr)
+	if s.banMgr.IsBanned(host) {
+		return true
+	}
+
+	if net.ParseIP(host) != nil || len(s.banMgr.Banned()) == 0 {
+		return false
+	}
+
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		return false
+	}
+
+	for _, ip := range ips {
+		if s.banMgr.IsBanned(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// bannedPeers returns the known addresses of the banned hosts.
+func (s *Server) bannedPeers() []string {
+	var banned []string
+	for _, ka := range s.addrMgr.Known() {
+		if s.isBannedAddr(ka.Addr) {
+			banned = append(banned, ka.Addr)
+		}
+	}
+	return banned
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter limits the messages of each command from a host by the token bucket of rateLimits.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+func (l *rateLimiter) allow(host, cmd string) bool {
+	limit, exist := rateLimits[cmd]
+	if !exist {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if len(l.buckets) >= maxRateBuckets {
+		l.prune(now)
+	}
+
+	key := host + " " + cmd
+	b, exist := l.buckets[key]
+	if !exist {
+		b = &bucket{tokens: limit.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(limit.burst, b.tokens + now.Sub(b.last).Seconds() * limit.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// prune removes the buckets idle for a minute, which would be full again.
+func (l *rateLimiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > time.Minute {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hansung080/gchain/net/banman"
+	"github.com/hansung080/gchain/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 10; i++ {
+		assert.True(t, l.allow("10.0.0.1", "addr"), "The burst is allowed")
+	}
+	assert.False(t, l.allow("10.0.0.1", "addr"))
+	assert.True(t, l.allow("10.0.0.2", "addr"), "Each host has its own limit")
+	assert.True(t, l.allow("10.0.0.1", "getaddr"), "Each command has its own limit")
+	assert.True(t, l.allow("10.0.0.1", "unknown"), "A command without the limit is allowed")
+
+	now = now.Add(time.Second)
+	assert.True(t, l.allow("10.0.0.1", "addr"), "A token is refilled per second")
+	assert.False(t, l.allow("10.0.0.1", "addr"))
+}
+
+func TestHandleMalformedMessage(t *testing.T) {
+	cases := []struct {
+		req   []byte
+		score int
+	}{
+		{[]byte("inv"), scoreMalformed},
+		{append(commandToBytes("version"), 0xff, 0x00, 0x13), scoreMalformed},
+		{append(commandToBytes("block"), marshalGob(block{From: "localhost:3001", Block: []byte("not a block")})...), scoreMalformed},
+		{append(commandToBytes("inv"), marshalGob(inventory{Type: "tx", Items: make([][]byte, maxInvItems + 1)})...), scoreOversized},
+		{commandToBytes("unknown"), scoreUnknownCommand},
+	}
+
+	// a malformed message is rejected before the state of the server is touched.
+	s := &Server{limiter: newRateLimiter()}
+	for _, c := range cases {
+		err := s.handleMessage("10.0.0.1", "", c.req)
+		if assert.IsType(t, &misbehavior{}, err) {
+			assert.Equal(t, c.score, err.(*misbehavior).score, err.Error())
+		}
+	}
+}
+
+func TestProcessInvalidBlock(t *testing.T) {
+	t.Chdir(t.TempDir())
+	node.MiningOutput = io.Discard
+	defer func() { node.MiningOutput = os.Stdout }()
+	assert.Nil(t, node.SelectNetwork(node.RegTestParams.Name))
+	defer node.SelectNetwork(node.MainNetParams.Name)
+
+	addr := string(node.NewWallet(node.DefaultKeyType).GetAddress())
+	createTestBlockchain("a", addr)
+
+	s := NewServer(Config{NodeID: "a"})
+	defer s.bc.Close()
+	genesis, _ := s.bc.Tip()
+
+	// the coinbase of the block from a peer gets more than the block subsidy.
+	template := s.BlockTemplate(addr)
+	coinbase := template.Txs[len(template.Txs) - 1]
+	coinbase.Vouts[0].Value++
+	coinbase.ID = coinbase.Hash()
+	inflated := node.NewBlockTemplate(template.Txs, template.PrevHash, template.Height)
+	inflated.Nonce, inflated.Hash = node.NewProofOfWork(inflated).Run()
+
+	err := s.processBlock(inflated, "")
+	if assert.IsType(t, &misbehavior{}, err) {
+		assert.Equal(t, scoreInvalid, err.(*misbehavior).score, err.Error())
+	}
+	tip, _ := s.bc.Tip()
+	assert.Equal(t, genesis, tip, "The invalid block does not move the tip")
+
+	valid := s.BlockTemplate(addr)
+	valid.Nonce, valid.Hash = node.NewProofOfWork(valid).Run()
+	assert.Nil(t, s.processBlock(valid, ""))
+	tip, _ = s.bc.Tip()
+	assert.Equal(t, valid.Hash, tip)
+}
+
+func TestPunishExemptPeers(t *testing.T) {
+	t.Chdir(t.TempDir())
+	banMgr, err := banman.New(BansFile("a"))
+	assert.Nil(t, err)
+	s := &Server{cfg: Config{AllowedPeers: []string{"allowed"}}, banMgr: banMgr}
+
+	invalid := misbehaving(scoreInvalid, "Invalid block").(*misbehavior)
+	s.punish("127.0.0.1", "", invalid)
+	s.punish("::1", "", invalid)
+	s.punish("10.0.0.1", "allowed", invalid)
+	assert.False(t, s.banMgr.IsBanned("127.0.0.1"), "The loopback host is exempt")
+	assert.False(t, s.banMgr.IsBanned("::1"))
+	assert.False(t, s.banMgr.IsBanned("10.0.0.1"), "The allowed peer is exempt")
+
+	s.punish("10.0.0.1", "other", invalid)
+	assert.True(t, s.banMgr.IsBanned("10.0.0.1"))
+}
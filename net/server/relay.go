@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/hex"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/**
+  @ Transaction Relay
+    A transaction accepted to the mempool is not announced at once, but queued for each peer,
+    and the queue of a peer is announced by one inv at a random time, the trickle.
+    - The trickle of each peer is independent and exponentially distributed around invTrickleInterval,
+      so that the origin of a transaction is not revealed by the order of the announcements.
+    - A transaction known to a peer, because it is announced to or received from the peer, is not announced to it again.
+
+    accepted tx --> queue of peer A --(random timer)--> inv [tx1, tx2, ...] --> peer A
+                \-> queue of peer B --(random timer)--> inv [tx1, tx2, ...] --> peer B
+*/
+
+const (
+	invTrickleInterval = 2 * time.Second
+	relayTick          = 100 * time.Millisecond
+	maxKnownInv        = 5000 // the transactions known to a peer
+	maxRelayPeers      = 1000
+)
+
+// knownInv is the set of the transactions known to a peer, which forgets the oldest one over maxKnownInv.
+type knownInv struct {
+	ids   map[string]bool
+	order []string
+}
+
+func (k *knownInv) add(id string) bool {
+	if k.ids[id] {
+		return false
+	}
+
+	if len(k.order) >= maxKnownInv {
+		delete(k.ids, k.order[0])
+		k.order = k.order[1:]
+	}
+
+	k.ids[id] = true
+	k.order = append(k.order, id)
+	return true
+}
+
+type txRelay struct {
+	mu     sync.Mutex
+	queues map[string][][]byte // the transactions to announce to each peer
+	known  map[string]*knownInv
+	next   map[string]time.Time // the next trickle of each peer
+	now    func() time.Time
+}
+
+// markKnown marks the transaction known to the peer. It returns false if it is already known.
+func (r *txRelay) markKnown(peer string, txid []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.markKnownLocked(peer, txid)
+}
+
+func (r *txRelay) markKnownLocked(peer string, txid []byte) bool {
+	known, exist := r.known[peer]
+	if !exist {
+		// the peers are forgotten at random over maxRelayPeers, because the peers come and go.
+		if len(r.known) >= maxRelayPeers {
+			for p := range r.known {
+				delete(r.known, p)
+				delete(r.queues, p)
+				delete(r.next, p)
+				break
+			}
+		}
+
+		known = &knownInv{ids: make(map[string]bool)}
+		r.known[peer] = known
+	}
+
+	return known.add(hex.EncodeToString(txid))
+}
+
+// queue queues the transaction for the next trickle of the peer, unless the peer knows it.
+func (r *txRelay) queue(peer string, txid []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.markKnownLocked(peer, txid) {
+		return
+	}
+
+	if _, exist := r.next[peer]; !exist {
+		r.next[peer] = r.now().Add(trickleDelay())
+	}
+	r.queues[peer] = append(r.queues[peer], txid)
+}
+
+// due takes the queues of the peers whose trickles have come, at most maxInvItems for each peer.
+func (r *txRelay) due() map[string][][]byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	batches := make(map[string][][]byte)
+	for peer, queue := range r.queues {
+		if now.Before(r.next[peer]) {
+			continue
+		}
+
+		n := len(queue)
+		if n > maxInvItems {
+			n = maxInvItems
+		}
+		batches[peer] = queue[:n]
+
+		if n == len(queue) {
+			delete(r.queues, peer)
+			delete(r.next, peer)
+		} else {
+			r.queues[peer] = queue[n:]
+			r.next[peer] = now.Add(trickleDelay())
+		}
+	}
+
+	return batches
+}
+
+func trickleDelay() time.Duration {
+	return time.Duration(rand.ExpFloat64() * float64(invTrickleInterval))
+}
+
+func newTxRelay() *txRelay {
+	return &txRelay{
+		queues: make(map[string][][]byte),
+		known:  make(map[string]*knownInv),
+		next:   make(map[string]time.Time),
+		now:    time.Now,
+	}
+}
+
+// relayTxs announces the queued transactions still in the mempool to each peer on its trickle.
+func (s *Server) relayTxs() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(relayTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.quit:
+			return
+		}
+
+		for peer, txids := range s.relay.due() {
+			var items [][]byte
+			for _, txid := range txids {
+				if s.mempool.Has(txid) {
+					items = append(items, txid)
+				}
+			}
+
+			if len(items) > 0 {
+				s.sendInventory(peer, "tx", items)
+			}
+		}
+	}
+}
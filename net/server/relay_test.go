@@ -0,0 +1,45 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxRelayTrickle(t *testing.T) {
+	r := newTxRelay()
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	r.markKnown("a:3000", []byte{1})
+	r.queue("a:3000", []byte{1})
+	r.queue("a:3000", []byte{2})
+	r.queue("a:3000", []byte{2})
+	r.queue("b:3000", []byte{1})
+
+	for peer, next := range r.next {
+		assert.False(t, next.Before(now), "The trickle of %s is not due before now", peer)
+	}
+
+	now = now.Add(time.Hour)
+	batches := r.due()
+	assert.Equal(t, [][]byte{{2}}, batches["a:3000"], "The known transactions are not announced again")
+	assert.Equal(t, [][]byte{{1}}, batches["b:3000"])
+	assert.Empty(t, r.due(), "The queues are taken")
+
+	r.queue("a:3000", []byte{2})
+	assert.Empty(t, r.queues, "An announced transaction is known")
+}
+
+func TestKnownInvForgetsOldest(t *testing.T) {
+	k := &knownInv{ids: make(map[string]bool)}
+	for i := 0; i < maxKnownInv + 1; i++ {
+		assert.True(t, k.add(fmt.Sprint(i)))
+	}
+
+	assert.Len(t, k.ids, maxKnownInv)
+	assert.False(t, k.add(fmt.Sprint(maxKnownInv)))
+	assert.True(t, k.add("0"), "The oldest one is forgotten")
+}
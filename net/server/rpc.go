@@ -0,0 +1,398 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hansung080/gchain/net/banman"
+	"github.com/hansung080/gchain/net/rpc"
+	"github.com/hansung080/gchain/node"
+)
+
+func (s *Server) rpcHandler() http.Handler {
+	rpcSrv := rpc.NewServer()
+	rpcSrv.Register("getbestheight", s.rpcGetBestHeight)
+	rpcSrv.Register("getblock", s.rpcGetBlock)
+	rpcSrv.Register("getblockbyheight", s.rpcGetBlockByHeight)
+	rpcSrv.Register("gettransaction", s.rpcGetTransaction)
+	rpcSrv.Register("getbalance", s.rpcGetBalance)
+	rpcSrv.Register("sendrawtransaction", s.rpcSendRawTransaction)
+	rpcSrv.Register("getmempool", s.rpcGetMempool)
+	rpcSrv.Register("getpeerinfo", s.rpcGetPeerInfo)
+	rpcSrv.Register("listbanned", s.rpcListBanned)
+	rpcSrv.Register("setban", s.rpcSetBan)
+	rpcSrv.Register("getmininginfo", s.rpcGetMiningInfo)
+	rpcSrv.Register("getwork", s.rpcGetWork)
+	rpcSrv.Register("submitblock", s.rpcSubmitBlock)
+	rpcSrv.Register("generate", s.rpcGenerate)
+
+	// the wallet methods sign with the keys of the node, so that they are served only to the same host.
+	if isLoopbackAddr(s.cfg.RPCAddr) {
+		rpcSrv.Register("walletpassphrase", s.rpcWalletPassphrase)
+		rpcSrv.Register("walletlock", s.rpcWalletLock)
+		rpcSrv.Register("signrawtransaction", s.rpcSignRawTransaction)
+	} else {
+		fmt.Println("Wallet methods are disabled on the JSON-RPC server not bound to a loopback address.")
+	}
+
+	user, password, err := s.rpcAuth()
+	if err != nil {
+		log.Panic(err)
+	}
+	rpcSrv.SetAuth(user, password)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", rpcSrv)
+	mux.Handle("/events", handleEvents(s.bc))
+	return mux
+}
+
+// rpcAuth returns the user and the password of RPCAuth, or CookieUser and the random password written into the cookie file.
+func (s *Server) rpcAuth() (string, string, error) {
+	if s.cfg.RPCAuth != "" {
+		return rpc.SplitAuth(s.cfg.RPCAuth)
+	}
+
+	password, err := rpc.NewCookie(node.Params().DataFile(rpc.CookieFile, s.cfg.NodeID))
+	return rpc.CookieUser, password, err
+}
+
+// isLoopbackAddr reports whether the host of the address is a loopback address. An empty host binds all the interfaces.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	return host == "localhost" || isLoopbackHost(host)
+}
+
+func (s *Server) rpcGetBestHeight(params json.RawMessage) (interface{}, error) {
+	if err := rpc.UnmarshalParams(params); err != nil {
+		return nil, err
+	}
+
+	return s.bc.GetBestHeight(), nil
+}
+
+func (s *Server) rpcGetBlock(params json.RawMessage) (interface{}, error) {
+	var hash string
+	if err := rpc.UnmarshalParams(params, &hash); err != nil {
+		return nil, err
+	}
+
+	hashBytes, err := decodeHexParam(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := s.bc.GetBlock(hashBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return rpc.NewBlockResult(&block, s.bc.GetBestHeight()), nil
+}
+
+func (s *Server) rpcGetBlockByHeight(params json.RawMessage) (interface{}, error) {
+	height := -1
+	if err := rpc.UnmarshalParams(params, &height); err != nil {
+		return nil, err
+	}
+
+	if height < 0 {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid height: %d", height)
+	}
+
+	block, err := s.bc.GetBlockByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return rpc.NewBlockResult(&block, s.bc.GetBestHeight()), nil
+}
+
+// rpcGetTransaction finds the transaction in the mempool first, and then in the blockchain.
+func (s *Server) rpcGetTransaction(params json.RawMessage) (interface{}, error) {
+	var txid string
+	if err := rpc.UnmarshalParams(params, &txid); err != nil {
+		return nil, err
+	}
+
+	txidBytes, err := decodeHexParam(txid)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry, exist := s.mempool.Get(txidBytes); exist {
+		return rpc.NewTxResult(&entry.Tx), nil
+	}
+
+	tx, block, err := s.bc.FindTxWithBlock(txidBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	result := rpc.NewTxResult(&tx)
+	result.BlockHash = hex.EncodeToString(block.Hash)
+	result.Confirmations = s.bc.GetBestHeight() - block.Height + 1
+	return result, nil
+}
+
+func (s *Server) rpcGetBalance(params json.RawMessage) (interface{}, error) {
+	var addr string
+	if err := rpc.UnmarshalParams(params, &addr); err != nil {
+		return nil, err
+	}
+
+	if !node.ValidateAddress(addr) {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid address: %s", addr)
+	}
+
+	balance := 0
+	for _, out := range (node.UTXOSet{s.bc}).FindUTXOs(node.GetPkeyHashFromAddress([]byte(addr))) {
+		balance += out.Value
+	}
+
+	return balance, nil
+}
+
+// rpcSendRawTransaction takes the hex-encoded transaction, and returns the transaction ID.
+// The transaction is replaceable, if its inputs signal replace-by-fee.
+func (s *Server) rpcSendRawTransaction(params json.RawMessage) (interface{}, error) {
+	var rawTx string
+	if err := rpc.UnmarshalParams(params, &rawTx); err != nil {
+		return nil, err
+	}
+
+	data, err := decodeHexParam(rawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := node.DecodeTx(data)
+	if err != nil {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid transaction: %s", err)
+	}
+
+	if err := s.processTx(tx, ""); err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(tx.ID), nil
+}
+
+func (s *Server) rpcGetMempool(params json.RawMessage) (interface{}, error) {
+	if err := rpc.UnmarshalParams(params); err != nil {
+		return nil, err
+	}
+
+	result := []rpc.MempoolEntryResult{}
+	for _, entry := range s.mempool.Entries() {
+		result = append(result, rpc.NewMempoolEntryResult(entry))
+	}
+
+	return result, nil
+}
+
+func (s *Server) rpcGetPeerInfo(params json.RawMessage) (interface{}, error) {
+	if err := rpc.UnmarshalParams(params); err != nil {
+		return nil, err
+	}
+
+	result := []rpc.PeerResult{}
+	for _, ka := range s.addrMgr.Known() {
+		peer := rpc.NewPeerResult(ka)
+		peer.BanScore = s.banMgr.Score(ka.Addr)
+		result = append(result, peer)
+	}
+
+	return result, nil
+}
+
+func (s *Server) rpcListBanned(params json.RawMessage) (interface{}, error) {
+	if err := rpc.UnmarshalParams(params); err != nil {
+		return nil, err
+	}
+
+	result := []rpc.BanResult{}
+	for _, ban := range s.banMgr.Banned() {
+		result = append(result, rpc.NewBanResult(ban))
+	}
+
+	return result, nil
+}
+
+// rpcSetBan takes the host, add or remove, and the ban time in seconds for add, and returns the ban of add.
+func (s *Server) rpcSetBan(params json.RawMessage) (interface{}, error) {
+	var host, command string
+	banTime := int64(banman.BanDuration / time.Second)
+	if err := rpc.UnmarshalParams(params, &host, &command, &banTime); err != nil {
+		return nil, err
+	}
+
+	if host == "" {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid host: %s", host)
+	}
+
+	switch command {
+	case "add":
+		if banTime <= 0 {
+			return nil, rpc.NewError(rpc.InvalidParams, "Invalid ban time: %d", banTime)
+		}
+
+		ban := s.banMgr.Ban(host, time.Duration(banTime) * time.Second, banman.ManualReason)
+		s.saveBans()
+		return rpc.NewBanResult(ban), nil
+	case "remove":
+		if !s.banMgr.Unban(host) {
+			return nil, rpc.NewError(rpc.InvalidParams, "Host not banned: %s", host)
+		}
+
+		s.saveBans()
+		return nil, nil
+	default:
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid command: %s", command)
+	}
+}
+
+// rpcWalletPassphrase takes the passphrase and the timeout in seconds, and unlocks the wallet of the node until the timeout.
+func (s *Server) rpcWalletPassphrase(params json.RawMessage) (interface{}, error) {
+	var passphrase string
+	var timeout int64
+	if err := rpc.UnmarshalParams(params, &passphrase, &timeout); err != nil {
+		return nil, err
+	}
+
+	if timeout <= 0 {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid timeout: %d", timeout)
+	}
+
+	if err := s.wallets.Unlock(passphrase, time.Duration(timeout) * time.Second); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+func (s *Server) rpcWalletLock(params json.RawMessage) (interface{}, error) {
+	if err := rpc.UnmarshalParams(params); err != nil {
+		return nil, err
+	}
+
+	if !s.wallets.IsEncrypted() {
+		return nil, node.ErrWalletNotEncrypted
+	}
+
+	s.wallets.Lock()
+	return nil, nil
+}
+
+// rpcSignRawTransaction takes the hex of the file of createrawtx, and signs it with the wallet of the node.
+func (s *Server) rpcSignRawTransaction(params json.RawMessage) (interface{}, error) {
+	var rawTx string
+	if err := rpc.UnmarshalParams(params, &rawTx); err != nil {
+		return nil, err
+	}
+
+	data, err := decodeHexParam(rawTx)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := node.UnmarshalPartialTx(data)
+	if err != nil {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid raw transaction: %s", err)
+	}
+
+	signed, err := s.wallets.SignPartialTx(p)
+	if err != nil {
+		return nil, err
+	}
+	return rpc.NewSignRawTxResult(p, signed), nil
+}
+
+func decodeHexParam(s string) ([]byte, error) {
+	data, err := hex.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid hex: %s", s)
+	}
+
+	return data, nil
+}
+
+func (s *Server) rpcGetMiningInfo(params json.RawMessage) (interface{}, error) {
+	if err := rpc.UnmarshalParams(params); err != nil {
+		return nil, err
+	}
+
+	return rpc.NewMiningInfoResult(s.miner.Stats()), nil
+}
+
+// rpcGetWork issues the work of a block template sending the block reward to the address, or the miner address of the node.
+func (s *Server) rpcGetWork(params json.RawMessage) (interface{}, error) {
+	addr := s.cfg.Miner
+	if err := rpc.UnmarshalParams(params, &addr); err != nil {
+		return nil, err
+	}
+
+	if addr == "" {
+		return nil, rpc.NewError(rpc.InvalidParams, "No address to send the block reward to, because the node is not mining")
+	}
+	if !node.ValidateAddress(addr) {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid address: %s", addr)
+	}
+
+	template := s.BlockTemplate(addr)
+	return rpc.NewWorkResult(s.addWork(template), template), nil
+}
+
+// rpcGenerate mines n blocks at once on regtest, sending the block rewards to the address, or the miner address of the node.
+func (s *Server) rpcGenerate(params json.RawMessage) (interface{}, error) {
+	n := 0
+	addr := s.cfg.Miner
+	if err := rpc.UnmarshalParams(params, &n, &addr); err != nil {
+		return nil, err
+	}
+
+	if n <= 0 {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid number of blocks: %d", n)
+	}
+	if !node.ValidateAddress(addr) {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid address: %q", addr)
+	}
+
+	hashes, err := s.Generate(n, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for _, hash := range hashes {
+		result = append(result, hex.EncodeToString(hash))
+	}
+	return result, nil
+}
+
+func (s *Server) rpcSubmitBlock(params json.RawMessage) (interface{}, error) {
+	var workID string
+	nonce := -1
+	if err := rpc.UnmarshalParams(params, &workID, &nonce); err != nil {
+		return nil, err
+	}
+
+	if nonce < 0 {
+		return nil, rpc.NewError(rpc.InvalidParams, "Invalid nonce: %d", nonce)
+	}
+
+	block, err := s.submitWork(workID, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return hex.EncodeToString(block.Hash), nil
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hansung080/gchain/net/rpc"
+	"github.com/hansung080/gchain/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletPassphraseAndLock(t *testing.T) {
+	t.Chdir(t.TempDir())
+	node.MiningOutput = io.Discard
+	defer func() { node.MiningOutput = os.Stdout }()
+
+	wallets, err := node.NewWallets("a")
+	assert.Nil(t, err)
+	addr := wallets.CreateWallet(node.DefaultKeyType)
+	assert.Nil(t, wallets.Encrypt("passphrase"))
+	wallets.SaveFile("a")
+
+	createTestBlockchain("a", addr)
+
+	s := NewServer(Config{NodeID: "a"})
+	defer s.bc.Close()
+
+	wallet := wallets.GetWallet(addr)
+	p, err := node.NewTxBuilder(&node.UTXOSet{s.bc}).AddSource(&wallet).AddRecipient(addr, 1).BuildUnsigned()
+	assert.Nil(t, err)
+	signParams := json.RawMessage(fmt.Sprintf(`["%s"]`, hex.EncodeToString(p.Marshal())))
+
+	_, err = s.rpcSignRawTransaction(signParams)
+	assert.Equal(t, node.ErrWalletLocked, err, "The wallet is loaded locked")
+
+	_, err = s.rpcWalletPassphrase(json.RawMessage(`["wrong", 60]`))
+	assert.Equal(t, node.ErrInvalidPassphrase, err)
+	_, err = s.rpcWalletPassphrase(json.RawMessage(`["passphrase", 0]`))
+	assert.NotNil(t, err, "The timeout is required")
+
+	_, err = s.rpcWalletPassphrase(json.RawMessage(`["passphrase", 60]`))
+	assert.Nil(t, err)
+	result, err := s.rpcSignRawTransaction(signParams)
+	assert.Nil(t, err)
+	signed := result.(rpc.SignRawTxResult)
+	assert.Equal(t, 1, signed.Signed)
+	assert.True(t, signed.Complete)
+
+	_, err = s.rpcWalletLock(nil)
+	assert.Nil(t, err)
+	assert.True(t, s.wallets.IsLocked())
+	_, err = s.rpcSignRawTransaction(signParams)
+	assert.Equal(t, node.ErrWalletLocked, err)
+
+	_, err = s.rpcWalletPassphrase(json.RawMessage(`["passphrase", 1]`))
+	assert.Nil(t, err)
+	assert.False(t, s.wallets.IsLocked())
+	assert.Eventually(t, s.wallets.IsLocked, 5 * time.Second, 50 * time.Millisecond, "The wallet is locked after the timeout")
+}
+
+func TestIsLoopbackAddr(t *testing.T) {
+	for addr, loopback := range map[string]bool{
+		"localhost:8332": true,
+		"127.0.0.1:8332": true,
+		"[::1]:8332":     true,
+		":8332":          false,
+		"0.0.0.0:8332":   false,
+		"10.0.0.1:8332":  false,
+		"localhost":      false,
+	} {
+		assert.Equal(t, loopback, isLoopbackAddr(addr), addr)
+	}
+}
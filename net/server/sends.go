@@ -1,105 +1,185 @@
 package server
 
 import (
-	"log"
 	"net"
 	"fmt"
 	"io"
 	"bytes"
+	"crypto/tls"
 
 	"github.com/hansung080/gchain/node"
 )
 
-func requestBlocks() {
-	for _, addr := range knownAddrs {
-		sendGetBlocks(addr)
+func (s *Server) sendAddress(addr string) {
+	payload := address{
+		From:  s.nodeAddr,
+		Addrs: append(s.addrMgr.Shareable(), s.nodeAddr),
 	}
-}
 
-func sendAddress(addr string) {
-	payload := address{knownAddrs}
-	payload.Addrs = append(payload.Addrs, nodeAddr)
 	resp := append(commandToBytes("addr"), marshalGob(payload)...)
-	send(addr, resp)
+	s.send(addr, resp)
 }
 
-func sendBlock(addr string, b *node.Block) {
+func (s *Server) sendBlock(addr string, b *node.Block) {
 	payload := block{
-		From:  nodeAddr,
+		From:  s.nodeAddr,
 		Block: b.Marshal(),
 	}
 
 	resp := append(commandToBytes("block"), marshalGob(payload)...)
-	send(addr, resp)
+	s.send(addr, resp)
+}
+
+func (s *Server) sendBlockTxn(addr string, hash []byte, txs [][]byte) {
+	payload := blocktxn{
+		From: s.nodeAddr,
+		Hash: hash,
+		Txs:  txs,
+	}
+
+	resp := append(commandToBytes("blocktxn"), marshalGob(payload)...)
+	s.send(addr, resp)
 }
 
-func sendGetBlocks(addr string) {
-	payload := getblocks{nodeAddr}
+func (s *Server) sendCmpctBlock(addr string, b *node.Block) {
+	resp := append(commandToBytes("cmpctblock"), marshalGob(newCmpctBlock(s.nodeAddr, b))...)
+	s.send(addr, resp)
+}
+
+func (s *Server) sendGetAddr(addr string) {
+	payload := getaddr{s.nodeAddr}
+	resp := append(commandToBytes("getaddr"), marshalGob(payload)...)
+	s.send(addr, resp)
+}
+
+func (s *Server) sendGetBlocks(addr string) {
+	payload := getblocks{
+		From:    s.nodeAddr,
+		Locator: s.bc.Locator(),
+	}
+
 	resp := append(commandToBytes("getblocks"), marshalGob(payload)...)
-	send(addr, resp)
+	s.send(addr, resp)
+}
+
+func (s *Server) sendGetBlockTxn(addr string, hash []byte, indexes []int) {
+	payload := getblocktxn{
+		From:    s.nodeAddr,
+		Hash:    hash,
+		Indexes: indexes,
+	}
+
+	resp := append(commandToBytes("getblocktxn"), marshalGob(payload)...)
+	s.send(addr, resp)
 }
 
-func sendGetData(addr, typ string, id []byte) {
+func (s *Server) sendGetData(addr, typ string, ids ...[]byte) {
 	payload := getdata{
-		From: nodeAddr,
-		Type: typ,
-		ID:   id,
+		From:  s.nodeAddr,
+		Type:  typ,
+		Items: ids,
 	}
 
 	resp := append(commandToBytes("getdata"), marshalGob(payload)...)
-	send(addr, resp)
+	s.send(addr, resp)
 }
 
-func sendInventory(addr, typ string, items [][]byte) {
+func (s *Server) sendInventory(addr, typ string, items [][]byte) {
 	payload := inventory{
-		From:  nodeAddr,
+		From:  s.nodeAddr,
 		Type:  typ,
 		Items: items,
 	}
 
 	resp := append(commandToBytes("inv"), marshalGob(payload)...)
-	send(addr, resp)
+	s.send(addr, resp)
 }
 
-func sendTx(addr string, tx *node.Transaction) {
+func (s *Server) sendTx(addr string, tx *node.Transaction) {
 	payload := transaction{
-		From: nodeAddr,
+		From: s.nodeAddr,
 		Tx:   tx.Marshal(),
 	}
 
 	resp := append(commandToBytes("tx"), marshalGob(payload)...)
-	send(addr, resp)
+	s.send(addr, resp)
+}
+
+// SendTx sends the transaction to the node of addr from outside of the network, such as CLI.
+// With the peer ID printed by the node of the secure transport, it is sent anonymously over TLS,
+// only if the node of addr has the peer ID.
+func SendTx(addr string, tx *node.Transaction, peerID string) error {
+	payload := transaction{
+		Tx: tx.Marshal(),
+	}
+
+	resp := append(commandToBytes("tx"), marshalGob(payload)...)
+	if peerID != "" {
+		// the certificate is verified by the peer ID instead of the certificate authorities, as between the peers.
+		cfg := &tls.Config{MinVersion: tls.VersionTLS13, InsecureSkipVerify: true}
+		return dialTLS(addr, cfg, resp, func(id string) error {
+			if id != peerID {
+				return fmt.Errorf("Peer %s is not %s expected at %s", id, peerID, addr)
+			}
+			return nil
+		})
+	}
+	return dial(addr, resp)
 }
 
-func sendVersion(addr string, bc *node.Blockchain) {
+func (s *Server) sendVersion(addr string) {
 	payload := version{
-		From:       nodeAddr,
+		From:       s.nodeAddr,
 		Version:    nodeVersion,
-		BestHeight: bc.GetBestHeight(),
+		BestHeight: s.bc.GetBestHeight(),
 	}
 
 	resp := append(commandToBytes("version"), marshalGob(payload)...)
-	send(addr, resp)
+	s.send(addr, resp)
 }
 
-func send(addr string, resp []byte) {
-	conn, err := net.Dial(protocol, addr)
-	if err != nil {
-		fmt.Printf("Cannot create connection: %s\n", addr)
-		var updatedAddrs []string
-		for _, a := range knownAddrs {
-			if a != addr {
-				updatedAddrs = append(updatedAddrs, a)
-			}
-		}
+func (s *Server) send(addr string, resp []byte) {
+	if s.isBannedAddr(addr) {
+		fmt.Printf("Not sent to banned peer: %s\n", addr)
+		return
+	}
 
-		knownAddrs = updatedAddrs
+	// a failed peer is kept, and backs off before the next attempt.
+	if err := s.dialer(addr, resp); err != nil {
+		fmt.Printf("Cannot send to %s: %s\n", addr, err)
+		s.addrMgr.Failed(addr)
 		return
 	}
-	defer conn.Close()
+	s.addrMgr.Good(addr)
+}
+
+// dial sends the message over the transport of the node. Over TLS, the address must belong to the same peer ID.
+func (s *Server) dial(addr string, resp []byte) error {
+	if s.tlsConfig == nil {
+		return dial(addr, resp)
+	}
+
+	return dialTLS(addr, s.tlsConfig, resp, func(peerID string) error {
+		if !s.bindPeerID(addr, peerID) {
+			return fmt.Errorf("Peer %s is not the one bound to %s", peerID, addr)
+		}
+		return nil
+	})
+}
 
-	_, err = io.Copy(conn, bytes.NewReader(resp))
+func dial(addr string, resp []byte) error {
+	conn, err := net.DialTimeout(protocol, addr, dialTimeout)
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
+	defer conn.Close()
+
+	_, err = io.Copy(conn, newMessageReader(resp))
+	return err
+}
+
+// newMessageReader reads the message prefixed by the magic of the network.
+func newMessageReader(resp []byte) io.Reader {
+	magic := node.Params().Magic
+	return io.MultiReader(bytes.NewReader(magic[:]), bytes.NewReader(resp))
 }
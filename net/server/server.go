@@ -1,10 +1,20 @@
 package server
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
+	"net/http"
 	"log"
+	"os"
+	"sync"
+	"time"
 
+	"github.com/hansung080/gchain/mining"
+	"github.com/hansung080/gchain/net/addrmgr"
+	"github.com/hansung080/gchain/net/banman"
+	"github.com/hansung080/gchain/net/explorer"
+	"github.com/hansung080/gchain/net/rpc"
 	"github.com/hansung080/gchain/node"
 )
 
@@ -12,33 +22,66 @@ const (
 	protocol    = "tcp"
 	nodeVersion = 1
 	commandLen  = 12
-)
 
-var (
-	nodeAddr  string
-	minerAddr string
-	knownAddrs      = []string{"localhost:3000"}
-	blocksInTransit = [][]byte{}
-	mempool         = make(map[string]node.Transaction)
+	maxBlockTxsSize = 1 << 16 // the maximum size in bytes of the transactions mined into a block
+
+	peersFile            = "peers_%s.json"
+	maxOutboundPeers     = 8
+	addrExchangeInterval = time.Minute
+	dialTimeout          = 5 * time.Second
 )
 
 type address struct {
+	From  string
 	Addrs []string
 }
 
+type getaddr struct {
+	From string
+}
+
 type block struct {
 	From  string
 	Block []byte
 }
 
-type getblocks struct {
+type blocktxn struct {
 	From string
+	Hash []byte
+	Txs  [][]byte // the transactions requested by getblocktxn, in the order of the indexes
+}
+
+type cmpctblock struct {
+	From      string
+	Timestamp int64
+	PrevHash  []byte
+	Hash      []byte
+	Nonce     int
+	Height    int
+	ShortIDs  [][]byte // the short IDs of the transactions not prefilled, in the order of the block
+	Prefilled []prefilledTx
+}
+
+type prefilledTx struct {
+	Index int
+	Tx    []byte
+}
+
+type getblocks struct {
+	From    string
+	Locator [][]byte // the hashes of the best chain of the sender by Blockchain.Locator
+}
+
+type getblocktxn struct {
+	From    string
+	Hash    []byte
+	Indexes []int
 }
 
 type getdata struct {
-	From string
-	Type string
-	ID   []byte
+	From  string
+	Type  string
+	Items [][]byte
 }
 
 type inventory struct {
@@ -58,28 +101,278 @@ type version struct {
 	BestHeight int
 }
 
-func Start(nodeID, miner string) {
-	nodeAddr = fmt.Sprintf("localhost:%s", nodeID)
-	minerAddr = miner
+/**
+  @ Node Roles
+    Every node validates and relays the blocks and the transactions to its peers in the same way.
+    - miner:      mines the transactions in the mempool in the background, and sends the block reward to the miner address.
+    - blocksonly: neither accepts nor relays the transactions from the peers, to save the bandwidth.
+                  It accepts the transactions from a loopback host, or anonymously over TLS, such as CLI.
+*/
+
+type Config struct {
+	NodeID       string
+	ListenAddr   string   // localhost:<NodeID> by default
+	ExternalAddr string   // the address advertised to the peers, ListenAddr or the address bound to its port 0 by default
+	Seeds        []string // the peers to connect to first
+	Miner        string   // the address to send the block reward to, or empty not to mine
+	EmptyBlocks  bool     // mines the blocks without a transaction too
+	BlocksOnly   bool
+	RPCAddr      string // the address to serve JSON-RPC on, or empty
+	RPCAuth      string // <user>:<password> of JSON-RPC, or a random password in the cookie file by default
+	ExplorerAddr string // the address to serve the explorer on, or empty
+	Secure       bool     // talks to the peers over TLS authenticated by the node keys
+	AllowedPeers []string // the peer IDs only accepted and dialed, which implies Secure
+}
+
+/**
+  @ Server State
+    Each connection is handled in its own goroutine, so the state shared by the handlers is synchronized.
+    - mempool, addrMgr, banMgr, limiter and relay lock themselves.
+    - chainMu serializes the changes of the blockchain: adding, mining and reindexing the blocks.
+    - mu guards blocksInTransit and moreBlocks, the works issued to the external miners, the partial compact blocks
+      and the peer IDs bound to the addresses.
+    Nothing is kept in the package-level variables, so that multiple nodes could run in one process.
+*/
+
+type Server struct {
+	cfg        Config
+	listenAddr string
+	nodeAddr   string // the external address advertised to the peers
+
+	bc      *node.Blockchain
+	mempool *node.Mempool
+	addrMgr *addrmgr.Manager
+	banMgr  *banman.Manager
+	limiter *rateLimiter
+	relay   *txRelay
+	miner   *mining.Miner // nil if not mining
+	wallets *node.Wallets // the wallet of the node loaded on start, which is unlocked by walletpassphrase if encrypted
+
+	chainMu sync.Mutex
+	mu      sync.Mutex
+	blocksInTransit [][]byte
+	moreBlocks      bool                     // the last block inventory was full, so that more blocks follow the blocks in transit
+	works           map[string]*node.Block   // the block templates of getwork by the work ID
+	partialBlocks   map[string]*partialBlock // the compact blocks waiting for their missing transactions by the hash
+	peerIDs         map[string]string        // the peer IDs bound to the From addresses
+
+	tlsConfig *tls.Config                          // nil for plaintext TCP
+	keyID     string                               // the peer ID of this node
+	dialer    func(addr string, resp []byte) error // s.dial, which the tests replace to cut the links
+
+	ln       net.Listener
+	httpSrvs []*http.Server
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer opens the blockchain, the known peers and bans, and the wallet of the node.
+func NewServer(cfg Config) *Server {
+	s := &Server{
+		cfg:           cfg,
+		listenAddr:    cfg.ListenAddr,
+		nodeAddr:      cfg.ExternalAddr,
+		limiter:       newRateLimiter(),
+		relay:         newTxRelay(),
+		works:         make(map[string]*node.Block),
+		partialBlocks: make(map[string]*partialBlock),
+		peerIDs:       make(map[string]string),
+		quit:          make(chan struct{}),
+	}
+	s.dialer = s.dial
+
+	if s.listenAddr == "" {
+		s.listenAddr = fmt.Sprintf("localhost:%s", cfg.NodeID)
+	}
+
+	s.bc = node.NewBlockchain(cfg.NodeID)
+	s.mempool = node.NewMempool(node.UTXOSet{s.bc}, s.bc.Events())
+
+	var err error
+	if s.addrMgr, err = addrmgr.New(node.Params().DataFile(peersFile, cfg.NodeID)); err != nil {
+		log.Panic(err)
+	}
+
+	if s.banMgr, err = banman.New(BansFile(cfg.NodeID)); err != nil {
+		log.Panic(err)
+	}
+
+	if s.wallets, err = node.NewWallets(cfg.NodeID); err != nil {
+		log.Panic(err)
+	}
+
+	if cfg.Secure || len(cfg.AllowedPeers) > 0 {
+		key, err := loadNodeKey(node.Params().DataFile(nodeKeyFile, cfg.NodeID))
+		if err != nil {
+			log.Panic(err)
+		}
+
+		if s.keyID, err = keyID(&key.PublicKey); err != nil {
+			log.Panic(err)
+		}
+
+		if s.tlsConfig, err = newTLSConfig(key, cfg.AllowedPeers); err != nil {
+			log.Panic(err)
+		}
+	}
+
+	return s
+}
 
-	ln, err := net.Listen(protocol, nodeAddr)
-	if err != nil {
+// Start listens on the address, and then connects to the peers in the background.
+// The JSON-RPC server and the explorer are started too, if their addresses are set.
+func (s *Server) Start() {
+	var err error
+	if s.ln, err = net.Listen(protocol, s.listenAddr); err != nil {
 		log.Panic(err)
 	}
-	defer ln.Close()
 
-	bc := node.NewBlockchain(nodeID)
+	if s.tlsConfig != nil {
+		s.ln = tls.NewListener(s.ln, s.tlsConfig)
+		fmt.Printf("Secure transport with peer ID %s\n", s.keyID)
+	}
+
+	// the port 0 is chosen by the system, which is advertised instead.
+	if s.nodeAddr == "" {
+		s.nodeAddr = s.listenAddr
+		if _, port, _ := net.SplitHostPort(s.listenAddr); port == "0" {
+			s.nodeAddr = s.ln.Addr().String()
+		}
+	}
+
+	for _, seed := range s.cfg.Seeds {
+		if seed != s.nodeAddr {
+			s.addrMgr.Add(seed)
+		}
+	}
+
+	if s.cfg.RPCAddr != "" {
+		s.serveHTTP("JSON-RPC server", s.cfg.RPCAddr, s.rpcHandler())
+	}
+
+	if s.cfg.ExplorerAddr != "" {
+		s.serveHTTP("Explorer", s.cfg.ExplorerAddr, explorer.New(s.bc, s.mempool))
+	}
 
-	if nodeAddr != knownAddrs[0] {
-		sendVersion(knownAddrs[0], bc)
+	for _, addr := range s.outboundPeers() {
+		s.sendVersion(addr)
 	}
 
+	s.wg.Add(3)
+	go s.exchangeAddrs()
+	go s.relayTxs()
+	go s.accept()
+
+	if s.cfg.Miner != "" {
+		s.miner = mining.New(mining.Config{Addr: s.cfg.Miner, EmptyBlocks: s.cfg.EmptyBlocks}, s, s.bc.Events())
+		s.miner.Start()
+	}
+}
+
+// Run starts the node, and blocks until it is stopped.
+func (s *Server) Run() {
+	s.Start()
+	<-s.quit
+}
+
+// Stop stops mining and listening, waits for the handlers in progress, and then closes the blockchain.
+func (s *Server) Stop() {
+	if s.miner != nil {
+		s.miner.Stop()
+	}
+
+	close(s.quit)
+	s.ln.Close()
+	for _, srv := range s.httpSrvs {
+		srv.Close()
+	}
+
+	s.wg.Wait()
+	s.savePeers()
+	if s.cfg.RPCAddr != "" && s.cfg.RPCAuth == "" {
+		os.Remove(node.Params().DataFile(rpc.CookieFile, s.cfg.NodeID))
+	}
+	s.bc.Close()
+}
+
+// Addr returns the address advertised to the peers.
+func (s *Server) Addr() string {
+	return s.nodeAddr
+}
+
+// PeerID returns the peer ID of the node key, or empty for plaintext TCP.
+func (s *Server) PeerID() string {
+	return s.keyID
+}
+
+func (s *Server) Blockchain() *node.Blockchain {
+	return s.bc
+}
+
+func (s *Server) Mempool() *node.Mempool {
+	return s.mempool
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+
 	for {
-		conn, err := ln.Accept()
+		conn, err := s.ln.Accept()
 		if err != nil {
+			select {
+			case <-s.quit:
+				return
+			default:
+				log.Panic(err)
+			}
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handleConnection(conn)
+		}()
+	}
+}
+
+func (s *Server) serveHTTP(name, addr string, handler http.Handler) {
+	srv := &http.Server{Addr: addr, Handler: handler}
+	s.httpSrvs = append(s.httpSrvs, srv)
+
+	fmt.Printf("%s listening on %s\n", name, addr)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Panic(err)
 		}
+	}()
+}
+
+// exchangeAddrs requests the addresses of the outbound peers, and saves the known addresses periodically.
+func (s *Server) exchangeAddrs() {
+	defer s.wg.Done()
+
+	for {
+		for _, addr := range s.outboundPeers() {
+			s.sendGetAddr(addr)
+		}
 
-		go handleConnection(conn, bc)
+		select {
+		case <-time.After(addrExchangeInterval):
+			s.savePeers()
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *Server) savePeers() {
+	if err := s.addrMgr.Save(); err != nil {
+		fmt.Printf("Cannot save peers: %s\n", err)
 	}
 }
+
+// outboundPeers selects the peers to send to from the different networks, except the addresses in exclude and the banned peers.
+func (s *Server) outboundPeers(exclude ...string) []string {
+	exclude = append(exclude, s.nodeAddr)
+	return s.addrMgr.Select(maxOutboundPeers, append(exclude, s.bannedPeers()...)...)
+}
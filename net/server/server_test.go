@@ -0,0 +1,64 @@
+package server
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hansung080/gchain/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServersInOneProcess(t *testing.T) {
+	t.Chdir(t.TempDir())
+	node.MiningOutput = io.Discard
+	defer func() { node.MiningOutput = os.Stdout }()
+
+	addr := string(node.NewWallet(node.DefaultKeyType).GetAddress())
+	createTestBlockchain("a", addr)
+	copyFile(t, "blockchain_a.db", "blockchain_b.db")
+
+	bc := node.NewBlockchain("a")
+	bc.MineBlock([]*node.Transaction{node.NewCoinbaseTx(addr, "")})
+	bc.Close()
+
+	a := NewServer(Config{NodeID: "a", ListenAddr: "127.0.0.1:0"})
+	a.Start()
+	defer a.Stop()
+
+	b := NewServer(Config{NodeID: "b", ListenAddr: "127.0.0.1:0", Seeds: []string{a.Addr()}})
+	b.Start()
+	defer b.Stop()
+
+	assert.Eventually(t, func() bool {
+		return b.Blockchain().GetBestHeight() == 1
+	}, 10 * time.Second, 50 * time.Millisecond, "b syncs the block of a")
+	assert.True(t, a.addrMgr.Has(b.Addr()), "a knows b from its version")
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	data, err := os.ReadFile(src)
+	assert.Nil(t, err)
+	assert.Nil(t, os.WriteFile(dst, data, 0600))
+}
+
+func TestBlocksOnlyAcceptsLocalTxs(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	wallet := node.NewWallet(node.DefaultKeyType)
+	createTestBlockchain("a", string(wallet.GetAddress()))
+	s := NewServer(Config{NodeID: "a", BlocksOnly: true})
+	defer s.bc.Close()
+
+	utxoSet := node.UTXOSet{s.bc}
+	tx := node.NewTransaction(wallet, string(node.NewWallet(node.DefaultKeyType).GetAddress()), 1, "", nil, &utxoSet)
+	req := append(commandToBytes("tx"), marshalGob(transaction{Tx: tx.Marshal()})...)
+
+	// a remote peer omitting the From address is not taken for CLI.
+	assert.Nil(t, s.handleTx("10.0.0.1", "", req))
+	assert.False(t, s.mempool.Has(tx.ID))
+
+	assert.Nil(t, s.handleTx("127.0.0.1", "", req))
+	assert.True(t, s.mempool.Has(tx.ID), "The transaction from the loopback host is accepted")
+}
@@ -0,0 +1,264 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+/**
+  @ Secure Transport
+    With Config.Secure, the peers talk over TLS 1.3 authenticated by their node keys, instead of plaintext TCP.
+    - The node key is the ECDSA P-256 key in nodekey_<NODE_ID>.pem, which is made on the first start,
+      and the self-signed certificate of the node key is made on every start.
+    - The peer ID is the hex of sha256 of the public key of the node key, which identifies the node
+      instead of the From address that any peer could claim.
+    - The From address is only the address to reply to. It is bound to the peer ID verified by dialing it,
+      or claimed by an allowed peer, and the message of another peer claiming the bound address is dropped.
+    - With Config.AllowedPeers, only the peers of the allowed IDs are accepted and dialed, for private deployments.
+    - The messages from outside of the network, such as CLI, have no From address, and are accepted anonymously
+      unless the peers are restricted to the allowed ones. CLI checks the peer ID of the node given by the user.
+
+    dialer                                     listener
+      | -- ClientHello -----------------------> |
+      | <- certificate of the node key -------- | the dialer binds the address to the peer ID, or checks it
+      | -- certificate of the node key -------> | the listener checks the peer ID bound to the From address
+      | == message ============================>|
+*/
+
+const (
+	nodeKeyFile      = "nodekey_%s.pem"
+	handshakeTimeout = 5 * time.Second
+	maxPeerIDs       = 10000
+)
+
+// loadNodeKey reads the node key from the file, or makes and saves a new one if the file does not exist.
+func loadNodeKey(file string) (*ecdsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+			return nil, err
+		}
+		return key, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("Invalid node key file: %s", file)
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+func keyID(pub interface{}) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func certKeyID(raw []byte) (string, error) {
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return "", err
+	}
+	return keyID(cert.PublicKey)
+}
+
+// peerIDOf returns the peer ID of the connection, or empty if the peer is anonymous.
+func peerIDOf(conn *tls.Conn) string {
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return ""
+	}
+
+	id, err := certKeyID(certs[0].Raw)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+func newCertificate(key *ecdsa.PrivateKey) (tls.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// newTLSConfig makes the config authenticating the peers by their node keys, instead of the certificate authorities.
+// The handshake proves that the peer has the private key of its certificate, so that the certificate needs no signer.
+func newTLSConfig(key *ecdsa.PrivateKey, allowedPeers []string) (*tls.Config, error) {
+	cert, err := newCertificate(key)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := make(map[string]bool)
+	for _, id := range allowedPeers {
+		allowed[id] = true
+	}
+
+	clientAuth := tls.RequestClientCert
+	if len(allowed) > 0 {
+		clientAuth = tls.RequireAnyClientCert
+	}
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS13,
+		Certificates:       []tls.Certificate{cert},
+		ClientAuth:         clientAuth,
+		InsecureSkipVerify: true, // verified by VerifyPeerCertificate instead
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return nil
+			}
+
+			id, err := certKeyID(rawCerts[0])
+			if err != nil {
+				return err
+			}
+
+			if len(allowed) > 0 && !allowed[id] {
+				return fmt.Errorf("Peer not allowed: %s", id)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// dialTLS sends the message over TLS, after verify accepts the peer ID of the listener.
+func dialTLS(addr string, cfg *tls.Config, resp []byte, verify func(peerID string) error) error {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, protocol, addr, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if verify != nil {
+		if err := verify(peerIDOf(conn)); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.Copy(conn, newMessageReader(resp))
+	return err
+}
+
+// bindPeerID binds the address to the peer ID verified by dialing the address, or allowed by Config.AllowedPeers.
+// It returns false if the address is bound to another peer. The bindings are never evicted,
+// so that a new address is not bound over maxPeerIDs, but only checked at the dial.
+func (s *Server) bindPeerID(addr, peerID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bound, exist := s.peerIDs[addr]
+	if exist {
+		return bound == peerID
+	}
+
+	if len(s.peerIDs) < maxPeerIDs {
+		s.peerIDs[addr] = peerID
+	}
+	return true
+}
+
+func (s *Server) boundPeerID(addr string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	peerID, exist := s.peerIDs[addr]
+	return peerID, exist
+}
+
+func (s *Server) isAllowedPeer(peerID string) bool {
+	for _, id := range s.cfg.AllowedPeers {
+		if id == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+// markGood marks the contact with the From address of the message, only if the address belongs to the peer.
+// Over TLS, the address must be bound to the peer ID, because any peer could claim an unbound address to keep it fresh.
+func (s *Server) markGood(from, peerID string) {
+	if s.tlsConfig != nil {
+		if bound, exist := s.boundPeerID(from); !exist || peerID == "" || bound != peerID {
+			return
+		}
+	}
+
+	s.addrMgr.Good(from)
+}
+
+// authenticate checks that the From address of the message does not belong to another peer.
+// The From address claimed by a peer is not bound, unless the peer is allowed, because any peer could claim it first.
+// It is only the address to reply to, which is authenticated by dialing it.
+// A conflicting claim is dropped without the ban score, because the score would go to the host of the address.
+func (s *Server) authenticate(peerID string, req []byte) error {
+	var header struct{ From string } // any message, because gob ignores the other fields
+	if err := unmarshalPayload(req, &header); err != nil {
+		return err
+	}
+
+	if header.From == "" {
+		return nil
+	}
+
+	if peerID == "" {
+		return fmt.Errorf("Anonymous peer claiming %s", header.From)
+	}
+
+	if bound, exist := s.boundPeerID(header.From); exist {
+		if bound != peerID {
+			return fmt.Errorf("Peer %s claiming %s of peer %s", peerID, header.From, bound)
+		}
+		return nil
+	}
+
+	if s.isAllowedPeer(peerID) && !s.bindPeerID(header.From, peerID) {
+		return fmt.Errorf("Peer %s claiming %s of another peer", peerID, header.From)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/hansung080/gchain/net/addrmgr"
+	"github.com/hansung080/gchain/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticate(t *testing.T) {
+	s := &Server{
+		cfg:       Config{AllowedPeers: []string{"allowed"}},
+		limiter:   newRateLimiter(),
+		tlsConfig: &tls.Config{},
+		peerIDs:   make(map[string]string),
+	}
+	req := func(from string) []byte {
+		return append(commandToBytes("version"), marshalGob(version{From: from, Version: nodeVersion})...)
+	}
+
+	assert.Nil(t, s.authenticate("attacker", req("a:3000")), "An unbound address is only the address to reply to")
+	assert.Empty(t, s.peerIDs, "The first claim does not bind the address")
+
+	assert.True(t, s.bindPeerID("a:3000", "honest"), "The address is bound by dialing it")
+	assert.Nil(t, s.authenticate("honest", req("a:3000")))
+	err := s.authenticate("attacker", req("a:3000"))
+	assert.NotNil(t, err, "The claim of another peer is dropped")
+	_, scored := err.(*misbehavior)
+	assert.False(t, scored, "The host of the address is not scored")
+	assert.NotNil(t, s.authenticate("", req("a:3000")), "An anonymous peer claims the address")
+
+	assert.Nil(t, s.authenticate("allowed", req("b:3000")), "An allowed peer binds its address")
+	assert.NotNil(t, s.authenticate("attacker", req("b:3000")))
+
+	tx := append(commandToBytes("tx"), marshalGob(transaction{Tx: []byte{1}})...)
+	assert.Nil(t, s.authenticate("", tx), "A message from outside of the network has no From address")
+
+	for i := len(s.peerIDs); i < maxPeerIDs; i++ {
+		s.bindPeerID(fmt.Sprintf("c:%d", i), "other")
+	}
+	assert.True(t, s.bindPeerID("d:3000", "attacker"), "A new address over maxPeerIDs is only checked at the dial")
+	assert.False(t, s.bindPeerID("a:3000", "attacker"), "The bindings are never evicted")
+}
+
+func TestMarkGood(t *testing.T) {
+	t.Chdir(t.TempDir())
+	addrMgr, err := addrmgr.New("peers.json")
+	assert.Nil(t, err)
+	s := &Server{addrMgr: addrMgr, tlsConfig: &tls.Config{}, peerIDs: make(map[string]string)}
+	addrMgr.Add("a:3000", "b:3000")
+	seen := func(addr string) bool {
+		for _, ka := range addrMgr.Known() {
+			if ka.Addr == addr {
+				return !ka.LastSeen.IsZero()
+			}
+		}
+		return false
+	}
+
+	s.markGood("a:3000", "attacker")
+	s.markGood("b:3000", "")
+	assert.False(t, seen("a:3000"), "An unbound address is not marked by the peer claiming it")
+	assert.False(t, seen("b:3000"), "An address is not marked by an anonymous peer")
+
+	s.bindPeerID("a:3000", "honest")
+	s.markGood("a:3000", "attacker")
+	assert.False(t, seen("a:3000"))
+	s.markGood("a:3000", "honest")
+	assert.True(t, seen("a:3000"), "The address is marked by the peer bound to it")
+
+	s.tlsConfig = nil
+	s.markGood("b:3000", "")
+	assert.True(t, seen("b:3000"), "Any address is marked without TLS")
+}
+
+func TestTLSAllowedPeers(t *testing.T) {
+	allowedKey := newTestKey(t)
+	allowedID, err := keyID(&allowedKey.PublicKey)
+	assert.Nil(t, err)
+
+	cfg, err := newTLSConfig(newTestKey(t), []string{allowedID})
+	assert.Nil(t, err)
+	ln, err := tls.Listen(protocol, "127.0.0.1:0", cfg)
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			data, _ := ioutil.ReadAll(conn)
+			received <- string(data)
+			conn.Close()
+		}
+	}()
+
+	magic := node.Params().Magic
+	allowedCfg, err := newTLSConfig(allowedKey, nil)
+	assert.Nil(t, err)
+	var listenerID string
+	assert.Nil(t, dialTLS(ln.Addr().String(), allowedCfg, []byte("hello"), func(peerID string) error {
+		listenerID = peerID
+		return nil
+	}))
+	assert.Equal(t, string(magic[:]) + "hello", <-received, "The message is prefixed by the magic")
+	assert.Len(t, listenerID, 64)
+
+	otherCfg, err := newTLSConfig(newTestKey(t), nil)
+	assert.Nil(t, err)
+	dialTLS(ln.Addr().String(), otherCfg, []byte("hello"), nil)
+	assert.Empty(t, <-received, "The peer not allowed is disconnected in the handshake")
+}
+
+func TestSendTxPeerID(t *testing.T) {
+	key := newTestKey(t)
+	peerID, err := keyID(&key.PublicKey)
+	assert.Nil(t, err)
+
+	cfg, err := newTLSConfig(key, nil)
+	assert.Nil(t, err)
+	ln, err := tls.Listen(protocol, "127.0.0.1:0", cfg)
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	received := make(chan int, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			data, _ := ioutil.ReadAll(conn)
+			received <- len(data)
+			conn.Close()
+		}
+	}()
+
+	tx := &node.Transaction{ID: []byte{1}}
+	assert.Nil(t, SendTx(ln.Addr().String(), tx, peerID))
+	assert.NotZero(t, <-received)
+
+	otherID, err := keyID(&newTestKey(t).PublicKey)
+	assert.Nil(t, err)
+	assert.NotNil(t, SendTx(ln.Addr().String(), tx, otherID), "The node of another peer ID is rejected")
+	assert.Zero(t, <-received, "The transaction is not sent to the node of another peer ID")
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	return key
+}
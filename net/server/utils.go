@@ -7,16 +7,6 @@ import (
 	"log"
 )
 
-func isNodeKnown(addr string) bool {
-	for _, a := range knownAddrs {
-		if a == addr {
-			return true
-		}
-	}
-
-	return false
-}
-
 func commandToBytes(cmd string) []byte {
 	var bytes [commandLen]byte
 
@@ -54,12 +44,11 @@ func marshalGob(v interface{}) []byte {
 	return buf.Bytes()
 }
 
-func unmarshalGob(data []byte, v interface{}) {
+// unmarshalGob decodes the data from a peer, which could be malformed.
+func unmarshalGob(data []byte, v interface{}) error {
 	var buf bytes.Buffer
 
 	buf.Write(data)
 	decoder := gob.NewDecoder(&buf)
-	if err := decoder.Decode(v); err != nil {
-		log.Panic(err)
-	}
+	return decoder.Decode(v)
 }
@@ -39,14 +39,7 @@ func (b *Block) HashTxs() []byte {
 }
 
 func NewBlock(txs []*Transaction, prevHash []byte, height int) *Block {
-	block := &Block{
-		Timestamp: time.Now().Unix(),
-		Txs:       txs,
-		PrevHash:  prevHash,
-		Hash:      []byte{},
-		Nonce:     0,
-		Height:    height,
-	}
+	block := NewBlockTemplate(txs, prevHash, height)
 
 	pow := NewProofOfWork(block)
 	nonce, hash := pow.Run()
@@ -56,16 +49,39 @@ func NewBlock(txs []*Transaction, prevHash []byte, height int) *Block {
 	return block
 }
 
+// NewBlockTemplate makes the block not mined yet, whose hash and nonce are found by the proof of work.
+func NewBlockTemplate(txs []*Transaction, prevHash []byte, height int) *Block {
+	return &Block{
+		Timestamp: time.Now().Unix(),
+		Txs:       txs,
+		PrevHash:  prevHash,
+		Hash:      []byte{},
+		Nonce:     0,
+		Height:    height,
+	}
+}
+
 func NewGenesisBlock(coinbase *Transaction) *Block {
 	return NewBlock([]*Transaction{coinbase}, []byte{}, 0)
 }
 
 func UnmarshalBlock(data []byte) *Block {
+	block, err := DecodeBlock(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return block
+}
+
+// DecodeBlock decodes the block from untrusted data, such as the message of a peer.
+func DecodeBlock(data []byte) (*Block, error) {
 	var block Block
+
 	decoder := gob.NewDecoder(bytes.NewReader(data))
 	if err := decoder.Decode(&block); err != nil {
-		log.Panic(err)
+		return nil, err
 	}
 
-	return &block
+	return &block, nil
 }
@@ -15,25 +15,56 @@ import (
 const (
 	dbFile       = "blockchain_%s.db"
 	blocksBucket = "blocks"
-	genesisCoinbaseData = "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks"
+
+	denseLocatorHashes = 10 // the number of the hashes taken one by one from the tip in a locator
 )
 
+// TxError is the failure of a transaction in a block, which tells the ID of the failed transaction.
+type TxError struct {
+	Txid []byte
+	Err  error
+}
+
+func newTxError(txid []byte, err error) *TxError {
+	return &TxError{Txid: txid, Err: err}
+}
+
+func (e *TxError) Error() string {
+	return fmt.Sprintf("Transaction %x: %s", e.Txid, e.Err)
+}
+
 type Blockchain struct {
-	tip []byte // last block hash
-	db  *bolt.DB
+	tip    []byte // last block hash
+	db     *bolt.DB
+	events *EventBus
+}
+
+// Events returns the event bus publishing the block events of the blockchain.
+func (bc *Blockchain) Events() *EventBus {
+	return bc.events
 }
 
 func (bc *Blockchain) Iterator() *BlockchainIterator {
+	return bc.iteratorFrom(bc.tip)
+}
+
+// iteratorFrom iterates the branch from the block of the hash to the genesis block, which could be off the best chain.
+func (bc *Blockchain) iteratorFrom(hash []byte) *BlockchainIterator {
 	return &BlockchainIterator{
-		currentHash: bc.tip,
+		currentHash: hash,
 		db:          bc.db,
 	}
 }
 
 func (bc *Blockchain) FindUTXOs() map[string]TxOuts {
+	return bc.findUTXOsFrom(bc.tip)
+}
+
+// findUTXOsFrom finds the unspent outputs on the branch from the block of the hash.
+func (bc *Blockchain) findUTXOsFrom(hash []byte) map[string]TxOuts {
 	utxos := make(map[string]TxOuts)
 	stxos := make(map[string][]int)
-	iter := bc.Iterator()
+	iter := bc.iteratorFrom(hash)
 
 	for iter.HasNext() {
 		block := iter.Next()
@@ -50,6 +81,7 @@ func (bc *Blockchain) FindUTXOs() map[string]TxOuts {
 
 				outs := utxos[txid]
 				outs.Outs = append(outs.Outs, out)
+				outs.Vouts = append(outs.Vouts, idx)
 				utxos[txid] = outs
 			}
 
@@ -66,73 +98,214 @@ func (bc *Blockchain) FindUTXOs() map[string]TxOuts {
 }
 
 func (bc *Blockchain) FindTx(id []byte) (Transaction, error) {
-	iter := bc.Iterator()
+	tx, _, err := bc.FindTxWithBlock(id)
+	return tx, err
+}
+
+// FindTxWithBlock finds the transaction and the block including it.
+func (bc *Blockchain) FindTxWithBlock(id []byte) (Transaction, *Block, error) {
+	return bc.findTxFrom(bc.tip, id)
+}
+
+// findTxFrom finds the transaction and the block including it on the branch from the block of the hash.
+func (bc *Blockchain) findTxFrom(hash, id []byte) (Transaction, *Block, error) {
+	iter := bc.iteratorFrom(hash)
 	for iter.HasNext() {
 		block := iter.Next()
 		for _, tx := range block.Txs {
 			if bytes.Compare(tx.ID, id) == 0 {
-				return *tx, nil
+				return *tx, block, nil
 			}
 		}
 	}
 
-	return Transaction{}, errors.New("Transaction not found")
+	return Transaction{}, nil, errors.New("Transaction not found")
 }
 
-func (bc *Blockchain) SignTx(tx *Transaction, skey ecdsa.PrivateKey) {
+// FindPrevTxs finds the previous transactions connected with the inputs of the transaction.
+func (bc *Blockchain) FindPrevTxs(tx *Transaction) map[string]Transaction {
+	prevTxs, err := bc.findPrevTxs(bc.tip, tx, nil)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return prevTxs
+}
+
+// findPrevTxs finds the previous transactions in pending first, and then on the branch from the block of the hash.
+func (bc *Blockchain) findPrevTxs(hash []byte, tx *Transaction, pending map[string]Transaction) (map[string]Transaction, error) {
 	prevTxs := make(map[string]Transaction)
 
 	for _, in := range tx.Vins {
-		prevTx, err := bc.FindTx(in.Txid)
+		txid := hex.EncodeToString(in.Txid)
+		if prevTx, exist := pending[txid]; exist {
+			prevTxs[txid] = prevTx
+			continue
+		}
+
+		prevTx, _, err := bc.findTxFrom(hash, in.Txid)
 		if err != nil {
-			log.Panic(err)
+			return nil, err
 		}
 
-		prevTxs[hex.EncodeToString(prevTx.ID)] = prevTx
+		prevTxs[txid] = prevTx
 	}
 
-	tx.Sign(prevTxs, skey)
+	return prevTxs, nil
 }
 
-func (bc *Blockchain) VerifyTx(tx *Transaction) bool {
-	if tx.IsCoinbase() {
-		return true
+// VerifyBlock recomputes the proof of work, the merkle root and the signatures of the block,
+// and returns the failures. The merkle root is verified through the block hash committing to it.
+// The previous transactions are found on the branch of the parent, which could be off the best chain.
+func (bc *Blockchain) VerifyBlock(block *Block) []error {
+	var failures []error
+
+	pow := NewProofOfWork(block)
+	if !bytes.Equal(pow.Hash(), block.Hash) {
+		failures = append(failures, errors.New("Block hash does not match the header and the merkle root of the transactions"))
+	}
+	if !pow.Validate() {
+		failures = append(failures, errors.New("Proof of work is not satisfied"))
 	}
 
-	prevTxs := make(map[string]Transaction)
+	// a transaction could spend the outputs of the preceding transactions in the same block.
+	pending := make(map[string]Transaction)
+	for _, tx := range block.Txs {
+		if !bytes.Equal(tx.ID, tx.UnsignedHash()) {
+			failures = append(failures, newTxError(tx.ID, errors.New("ID does not match the content")))
+		}
 
-	for _, in := range tx.Vins {
-		prevTx, err := bc.FindTx(in.Txid)
-		if err != nil {
-			log.Panic(err)
+		if !tx.IsCoinbase() {
+			if err := bc.verifyTxSigs(block.PrevHash, tx, pending); err != nil {
+				failures = append(failures, newTxError(tx.ID, err))
+			}
 		}
 
-		prevTxs[hex.EncodeToString(prevTx.ID)] = prevTx
+		pending[hex.EncodeToString(tx.ID)] = *tx
 	}
 
-	return tx.Verify(prevTxs)
+	return failures
 }
 
-func (bc *Blockchain) MineBlock(txs []*Transaction) *Block {
-	for _, tx := range txs {
-		if !bc.VerifyTx(tx) {
-			log.Panic("Transaction verification failure")
+// verifyTxSigs verifies the signatures of the inputs, without panic for the missing previous transactions.
+func (bc *Blockchain) verifyTxSigs(hash []byte, tx *Transaction, pending map[string]Transaction) error {
+	prevTxs, err := bc.findPrevTxs(hash, tx, pending)
+	if err != nil {
+		return errors.New("Previous transaction not found")
+	}
+
+	if !tx.Verify(prevTxs) {
+		return errors.New("Signature verification failure")
+	}
+
+	return nil
+}
+
+// VerifyBlockValues verifies the values of the block on its parent, and returns the failures.
+// Every input spends an output unspent on the branch of the parent or in the preceding transactions of the block,
+// no transaction spends more than its inputs, and the coinbase gets at most the block subsidy and the fees.
+func (bc *Blockchain) VerifyBlockValues(block *Block) []error {
+	var failures []error
+
+	// the UTXO set is of the tip, so that the unspent outputs are found on the branch only if the parent is off the tip.
+	findOut := UTXOSet{bc}.FindOut
+	if !bytes.Equal(block.PrevHash, bc.tip) {
+		utxos := bc.findUTXOsFrom(block.PrevHash)
+		findOut = func(op Outpoint) (TxOut, bool) {
+			outs := utxos[hex.EncodeToString(op.Txid)]
+			for idx, out := range outs.Outs {
+				if outs.Vout(idx) == op.Vout {
+					return out, true
+				}
+			}
+			return TxOut{}, false
 		}
 	}
 
-	var lastHash []byte
-	var lastHeight int
-	if err := bc.db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(blocksBucket))
-		lastHash = b.Get([]byte("l"))
-		lastBlock := UnmarshalBlock(b.Get(lastHash))
-		lastHeight = lastBlock.Height
-		return nil
+	// the outputs of the preceding transactions in the same block are pending, keyed by the outpoint string.
+	pending := make(map[string]TxOut)
+	spent := make(map[string]bool)
+	fee, coinbaseValue, coinbases := 0, 0, 0
+	for _, tx := range block.Txs {
+		outValue := 0
+		for _, out := range tx.Vouts {
+			if out.Value < 0 {
+				failures = append(failures, newTxError(tx.ID, errors.New("negative output value")))
+			}
+			outValue += out.Value
+		}
 
-	}); err != nil {
-		log.Panic(err)
+		if tx.IsCoinbase() {
+			coinbaseValue += outValue
+			coinbases++
+		} else {
+			inValue := 0
+			for _, in := range tx.Vins {
+				op := Outpoint{Txid: in.Txid, Vout: in.Vout}
+				out, exist := pending[op.String()]
+				if !exist {
+					out, exist = findOut(op)
+				}
+
+				if !exist || spent[op.String()] {
+					failures = append(failures, newTxError(tx.ID, fmt.Errorf("output %s is missing or spent", op)))
+					continue
+				}
+
+				spent[op.String()] = true
+				inValue += out.Value
+			}
+
+			if inValue < outValue {
+				failures = append(failures, newTxError(tx.ID, fmt.Errorf("outputs %d exceed inputs %d", outValue, inValue)))
+			} else {
+				fee += inValue - outValue
+			}
+		}
+
+		for idx, out := range tx.Vouts {
+			pending[Outpoint{Txid: tx.ID, Vout: idx}.String()] = out
+		}
+	}
+
+	if coinbases != 1 {
+		failures = append(failures, fmt.Errorf("%d coinbase transactions", coinbases))
+	}
+
+	if reward := activeNet.BlockSubsidy(block.Height) + fee; coinbaseValue > reward {
+		failures = append(failures, fmt.Errorf("Coinbase %d exceeds the subsidy and the fees %d", coinbaseValue, reward))
+	}
+
+	return failures
+}
+
+func (bc *Blockchain) SignTx(tx *Transaction, skey ecdsa.PrivateKey) {
+	tx.Sign(bc.FindPrevTxs(tx), skey)
+}
+
+func (bc *Blockchain) VerifyTx(tx *Transaction) bool {
+	if tx.IsCoinbase() {
+		return true
+	}
+
+	return tx.Verify(bc.FindPrevTxs(tx))
+}
+
+func (bc *Blockchain) MineBlock(txs []*Transaction) *Block {
+	// a transaction could spend the outputs of the preceding transactions in the same block.
+	pending := make(map[string]Transaction)
+	for _, tx := range txs {
+		if !tx.IsCoinbase() {
+			prevTxs, err := bc.findPrevTxs(bc.tip, tx, pending)
+			if err != nil || !tx.Verify(prevTxs) {
+				log.Panic("Transaction verification failure")
+			}
+		}
+
+		pending[hex.EncodeToString(tx.ID)] = *tx
 	}
 
+	lastHash, lastHeight := bc.Tip()
 	newBlock := NewBlock(txs, lastHash, lastHeight + 1)
 
 	if err := bc.db.Update(func(tx *bolt.Tx) error {
@@ -153,10 +326,12 @@ func (bc *Blockchain) MineBlock(txs []*Transaction) *Block {
 		log.Panic(err)
 	}
 
+	bc.events.Publish(Event{Type: BlockConnected, Block: newBlock})
 	return newBlock
 }
 
 func (bc *Blockchain) AddBlock(block *Block) {
+	var oldTip []byte
 	if err := bc.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
 		if b.Get(block.Hash) != nil {
@@ -175,6 +350,7 @@ func (bc *Blockchain) AddBlock(block *Block) {
 				log.Panic(err)
 			}
 			bc.tip = block.Hash
+			oldTip = append([]byte{}, lastHash...) // copy, because it is valid only in the transaction.
 		}
 
 		return nil
@@ -182,6 +358,60 @@ func (bc *Blockchain) AddBlock(block *Block) {
 	}); err != nil {
 		log.Panic(err)
 	}
+
+	if oldTip == nil {
+		return
+	}
+
+	disconnected, connected := bc.FindFork(oldTip, block)
+	for _, b := range disconnected {
+		bc.events.Publish(Event{Type: BlockDisconnected, Block: b})
+	}
+	for _, b := range connected {
+		bc.events.Publish(Event{Type: BlockConnected, Block: b})
+	}
+}
+
+// FindFork finds the blocks disconnected from the old tip to the fork point, from the newest,
+// and the blocks connected from the fork point to the new tip, from the oldest.
+// Only the new tip is connected, if the fork point is unknown, because the ancestors of the new tip are not received yet.
+func (bc *Blockchain) FindFork(oldTip []byte, newTip *Block) ([]*Block, []*Block) {
+	if bytes.Equal(newTip.PrevHash, oldTip) {
+		return nil, []*Block{newTip}
+	}
+
+	oldBranch := make(map[string]bool)
+	for hash := oldTip; len(hash) > 0; {
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			break
+		}
+		oldBranch[hex.EncodeToString(hash)] = true
+		hash = block.PrevHash
+	}
+
+	connected := []*Block{newTip}
+	fork := newTip.PrevHash
+	for !oldBranch[hex.EncodeToString(fork)] {
+		block, err := bc.GetBlock(fork)
+		if err != nil {
+			return nil, []*Block{newTip}
+		}
+		connected = append([]*Block{&block}, connected...)
+		fork = block.PrevHash
+	}
+
+	var disconnected []*Block
+	for hash := oldTip; !bytes.Equal(hash, fork); {
+		block, err := bc.GetBlock(hash)
+		if err != nil {
+			break
+		}
+		disconnected = append(disconnected, &block)
+		hash = block.PrevHash
+	}
+
+	return disconnected, connected
 }
 
 func (bc *Blockchain) GetBlock(hash []byte) (Block, error) {
@@ -205,6 +435,22 @@ func (bc *Blockchain) GetBlock(hash []byte) (Block, error) {
 	return block, nil
 }
 
+func (bc *Blockchain) GetBlockByHeight(height int) (Block, error) {
+	iter := bc.Iterator()
+	for iter.HasNext() {
+		block := iter.Next()
+		if block.Height == height {
+			return *block, nil
+		}
+
+		if block.Height < height {
+			break
+		}
+	}
+
+	return Block{}, errors.New("Block not found")
+}
+
 func (bc *Blockchain) GetBlockHashes() [][]byte {
 	var hashes [][]byte
 
@@ -217,6 +463,69 @@ func (bc *Blockchain) GetBlockHashes() [][]byte {
 	return hashes
 }
 
+// Locator returns the hashes of the best chain from the tip, where the first hashes are taken one by one,
+// and then the steps are doubled until the genesis block, so that a peer finds the last common block in a few hashes.
+func (bc *Blockchain) Locator() [][]byte {
+	hashes := bc.GetBlockHashes()
+
+	var locator [][]byte
+	step := 1
+	for i := 0; i < len(hashes); i += step {
+		locator = append(locator, hashes[i])
+		if len(locator) >= denseLocatorHashes {
+			step *= 2
+		}
+	}
+
+	if genesis := hashes[len(hashes) - 1]; !bytes.Equal(locator[len(locator) - 1], genesis) {
+		locator = append(locator, genesis)
+	}
+	return locator
+}
+
+// BlockHashesAfter returns up to max hashes of the best chain after the last block in the locator, from the newest.
+// The oldest hashes are returned, if there are more, so that the peer requests the rest from the last one again.
+// The hashes start from the genesis block, if no block in the locator is on the best chain.
+func (bc *Blockchain) BlockHashesAfter(locator [][]byte, max int) [][]byte {
+	known := make(map[string]bool)
+	for _, hash := range locator {
+		known[hex.EncodeToString(hash)] = true
+	}
+
+	var hashes [][]byte
+	iter := bc.Iterator()
+	for iter.HasNext() {
+		block := iter.Next()
+		if known[hex.EncodeToString(block.Hash)] {
+			break
+		}
+		hashes = append(hashes, block.Hash)
+	}
+
+	if len(hashes) > max {
+		hashes = hashes[len(hashes) - max:]
+	}
+	return hashes
+}
+
+// Tip returns the hash and the height of the last block of the best chain.
+func (bc *Blockchain) Tip() ([]byte, int) {
+	var lastHash []byte
+	var lastHeight int
+	if err := bc.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(blocksBucket))
+		lastHash = append([]byte{}, b.Get([]byte("l"))...) // copy, because it is valid only in the transaction.
+		lastBlock := UnmarshalBlock(b.Get(lastHash))
+		lastHeight = lastBlock.Height
+		return nil
+
+	}); err != nil {
+		log.Panic(err)
+	}
+
+	return lastHash, lastHeight
+}
+
 func (bc *Blockchain) GetBestHeight() int {
 	var lastHeight int
 
@@ -238,8 +547,27 @@ func (bc *Blockchain) Close() {
 	bc.db.Close()
 }
 
+// newBlockchain makes the blockchain of the tip, whose history index follows the blocks connected and disconnected.
+func newBlockchain(tip []byte, db *bolt.DB) *Blockchain {
+	bc := &Blockchain{
+		tip:    tip,
+		db:     db,
+		events: NewEventBus(),
+	}
+
+	index := HistoryIndex{bc}
+	bc.events.SubscribeFunc(func(e Event) bool {
+		return e.Type == BlockConnected || e.Type == BlockDisconnected
+	}, index.handleEvent)
+	return bc
+}
+
+func BlockchainExists(nodeID string) bool {
+	return FileExist(activeNet.DataFile(dbFile, nodeID))
+}
+
 func CreateBlockchain(nodeID, addr string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
+	dbFile := activeNet.DataFile(dbFile, nodeID)
 	if FileExist(dbFile) {
 		fmt.Println("Blockchain already exists.")
 		os.Exit(1)
@@ -250,7 +578,7 @@ func CreateBlockchain(nodeID, addr string) *Blockchain {
 		log.Panic(err)
 	}
 
-	coinbase := NewCoinbaseTx(addr, genesisCoinbaseData)
+	coinbase := NewCoinbaseTx(addr, activeNet.GenesisCoinbaseData)
 	genesis := NewGenesisBlock(coinbase)
 
 	var tip []byte
@@ -275,14 +603,11 @@ func CreateBlockchain(nodeID, addr string) *Blockchain {
 		log.Panic(err)
 	}
 
-	return &Blockchain{
-		tip: tip,
-		db:  db,
-	}
+	return newBlockchain(tip, db)
 }
 
 func NewBlockchain(nodeID string) *Blockchain {
-	dbFile := fmt.Sprintf(dbFile, nodeID)
+	dbFile := activeNet.DataFile(dbFile, nodeID)
 	if !FileExist(dbFile) {
 		fmt.Println("Blockchain not found. Create one first.")
 		os.Exit(1)
@@ -296,15 +621,12 @@ func NewBlockchain(nodeID string) *Blockchain {
 	var tip []byte
 	if err = db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(blocksBucket))
-		tip = b.Get([]byte("l"))
+		tip = append([]byte{}, b.Get([]byte("l"))...)
 		return nil
 
 	}); err != nil {
 		log.Panic(err)
 	}
 
-	return &Blockchain{
-		tip: tip,
-		db:  db,
-	}
+	return newBlockchain(tip, db)
 }
@@ -27,9 +27,21 @@ func (i *BlockchainIterator) Next() *Block {
 	return block
 }
 
+// HasNext reports whether the next block exists. It is false after the genesis block,
+// or at an ancestor not received yet in the sync.
 func (i *BlockchainIterator) HasNext() bool {
 	if len(i.currentHash) == 0 {
 		return false
 	}
-	return true
+
+	exist := false
+	if err := i.db.View(func(tx *bolt.Tx) error {
+		exist = tx.Bucket([]byte(blocksBucket)).Get(i.currentHash) != nil
+		return nil
+
+	}); err != nil {
+		log.Panic(err)
+	}
+
+	return exist
 }
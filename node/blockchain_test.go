@@ -0,0 +1,112 @@
+package node
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyBlock(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	wallet := NewWallet(Secp256k1)
+	addr := string(wallet.GetAddress())
+	bc := CreateBlockchain("test", addr)
+	defer bc.Close()
+
+	genesis, err := bc.GetBlockByHeight(0)
+	assert.Nil(t, err)
+	assert.Empty(t, bc.VerifyBlock(&genesis))
+
+	// the child spends the output of the parent in the same block.
+	parent := newSignedTx(wallet, []PrevOut{prevOutOf(*genesis.Txs[0], 0)}, Params().Subsidy)
+	child := newSignedTx(wallet, []PrevOut{prevOutOf(parent, 0)}, Params().Subsidy)
+	block := NewBlock([]*Transaction{NewCoinbaseTx(addr, ""), &parent, &child}, genesis.Hash, 1)
+	assert.Empty(t, bc.VerifyBlock(block))
+
+	failures := func(b *Block) string {
+		return fmt.Sprint(bc.VerifyBlock(b))
+	}
+
+	// the tampered output breaks the block hash, the transaction ID and the signature.
+	child.Vouts[0].Value = Params().Subsidy + 1
+	assert.Contains(t, failures(block), "Block hash does not match")
+	assert.Contains(t, failures(block), "ID does not match")
+	assert.Contains(t, failures(block), "Signature verification failure")
+
+	child.Vouts[0].Value = Params().Subsidy
+	block.Nonce++
+	assert.Contains(t, failures(block), "Block hash does not match")
+	assert.NotContains(t, failures(block), "ID does not match")
+
+	orphan := newSignedTx(wallet, []PrevOut{prevOutOf(child, 0)}, Params().Subsidy)
+	assert.Contains(t, failures(&Block{Txs: []*Transaction{&orphan}}), "Previous transaction not found")
+}
+
+func TestVerifyBlockValues(t *testing.T) {
+	t.Chdir(t.TempDir())
+	MiningOutput = io.Discard
+	defer func() { MiningOutput = os.Stdout }()
+
+	wallet := NewWallet(Secp256k1)
+	addr := string(wallet.GetAddress())
+	bc := CreateBlockchain("test", addr)
+	defer bc.Close()
+	UTXOSet{bc}.Reindex()
+
+	genesis, _ := bc.Tip()
+	genesisBlock, err := bc.GetBlock(genesis)
+	assert.Nil(t, err)
+	funding := prevOutOf(*genesisBlock.Txs[0], 0)
+	failures := func(prevHash []byte, txs ...*Transaction) string {
+		return fmt.Sprint(bc.VerifyBlockValues(&Block{Txs: txs, PrevHash: prevHash, Height: 1}))
+	}
+
+	spend := newSignedTx(wallet, []PrevOut{funding}, Params().Subsidy - 1)
+	assert.Equal(t, "[]", failures(genesis, NewCoinbaseTxWithFee(addr, "", 1, 1), &spend))
+	assert.Contains(t, failures(genesis, NewCoinbaseTxWithFee(addr, "", 1, 2), &spend), "Coinbase 12 exceeds the subsidy and the fees 11")
+	assert.Contains(t, failures(genesis, &spend), "0 coinbase transactions")
+
+	inflated := newSignedTx(wallet, []PrevOut{funding}, Params().Subsidy + 1)
+	assert.Contains(t, failures(genesis, NewCoinbaseTx(addr, ""), &inflated), "exceed inputs")
+
+	doubleSpend := newSignedTx(wallet, []PrevOut{funding}, 1)
+	assert.Contains(t, failures(genesis, NewCoinbaseTx(addr, ""), &spend, &doubleSpend), "is missing or spent")
+
+	block := NewBlock([]*Transaction{NewCoinbaseTx(addr, ""), &spend}, genesis, 1)
+	bc.AddBlock(block)
+	UTXOSet{bc}.Update(block)
+	assert.Contains(t, failures(block.Hash, NewCoinbaseTx(addr, ""), &doubleSpend), "is missing or spent")
+	assert.Equal(t, "[]", failures(genesis, NewCoinbaseTx(addr, ""), &doubleSpend), "The output is unspent on the branch of the parent")
+}
+
+func TestBlockHashesAfter(t *testing.T) {
+	t.Chdir(t.TempDir())
+	MiningOutput = io.Discard
+	defer func() { MiningOutput = os.Stdout }()
+
+	addr := string(NewWallet(Secp256k1).GetAddress())
+	bc := CreateBlockchain("test", addr)
+	defer bc.Close()
+
+	for i := 0; i < 14; i++ {
+		bc.MineBlock([]*Transaction{NewCoinbaseTx(addr, "")})
+	}
+	hashes := bc.GetBlockHashes()
+
+	// 10 hashes one by one from the tip at 14, and then the heights 3 and 0.
+	locator := bc.Locator()
+	assert.Len(t, locator, 12)
+	assert.Equal(t, hashes[:10], locator[:10])
+	assert.Equal(t, hashes[11], locator[10])
+	assert.Equal(t, hashes[14], locator[11], "The genesis block ends the locator")
+
+	// the oldest hashes after the common block are returned first.
+	assert.Equal(t, hashes[6:9], bc.BlockHashesAfter([][]byte{hashes[9]}, 3))
+	assert.Equal(t, hashes[:9], bc.BlockHashesAfter([][]byte{[]byte("unknown"), hashes[9]}, 100))
+	assert.Equal(t, hashes, bc.BlockHashesAfter(nil, 100), "The hashes start from the genesis block without a locator")
+	assert.Empty(t, bc.BlockHashesAfter(locator, 100))
+}
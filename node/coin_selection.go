@@ -0,0 +1,236 @@
+package node
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+  @ Coin Selection Strategies
+    - bnb:      Branch and bound searches the outputs summing to the amount exactly, so that no change is made.
+                It falls back to largest-first, when no exact match is found.
+    - largest:  Largest-first takes the largest outputs first, so that the fewest inputs are used.
+    - smallest: Smallest-first takes the smallest outputs first, so that dusty outputs are consolidated.
+    - random:   Random takes random outputs, so that the outputs of an address are not linked by order.
+
+  @ Manual Coin Control
+    - Pinned outpoints are always spent, and the strategy selects the rest of the amount if any.
+*/
+
+const (
+	DefaultCoinSelector = "bnb"
+	bnbMaxTries         = 100000
+)
+
+type Outpoint struct {
+	Txid []byte // transaction ID
+	Vout int    // output index of the transaction
+}
+
+func (op Outpoint) String() string {
+	return fmt.Sprintf("%x:%d", op.Txid, op.Vout)
+}
+
+// ParseOutpoint parses the outpoint formatted as <txid>:<vout>.
+func ParseOutpoint(s string) (Outpoint, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return Outpoint{}, fmt.Errorf("Invalid outpoint: %s", s)
+	}
+
+	txid, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return Outpoint{}, fmt.Errorf("Invalid outpoint: %s", s)
+	}
+
+	vout, err := strconv.Atoi(parts[1])
+	if err != nil || vout < 0 {
+		return Outpoint{}, fmt.Errorf("Invalid outpoint: %s", s)
+	}
+
+	return Outpoint{Txid: txid, Vout: vout}, nil
+}
+
+type SpendableOut struct {
+	Outpoint
+	Value int
+}
+
+type CoinSelector interface {
+	// Select selects the outputs summing to amount or more. The sum of outs is amount or more.
+	Select(outs []SpendableOut, amount int) []SpendableOut
+}
+
+// CoinControl decides how the outputs to spend are selected. Selector is DefaultCoinSelector, if it is nil.
+type CoinControl struct {
+	Selector CoinSelector
+	Pinned   []Outpoint
+}
+
+var coinSelectors = map[string]CoinSelector{
+	"bnb":      BranchAndBound{Fallback: LargestFirst{}},
+	"largest":  LargestFirst{},
+	"smallest": SmallestFirst{},
+	"random":   RandomSelector{},
+}
+
+func NewCoinSelector(name string) (CoinSelector, error) {
+	selector, exist := coinSelectors[name]
+	if !exist {
+		return nil, fmt.Errorf("Invalid coin selector: %s", name)
+	}
+
+	return selector, nil
+}
+
+// SelectOuts selects the outputs to spend for amount among outs. The pinned outputs are selected first,
+// and the selector selects the rest of the amount from the others. All outs are selected, if their sum is less than amount.
+// It fails if a pinned outpoint is not among outs, such as already spent.
+func SelectOuts(outs []SpendableOut, amount int, coinControl *CoinControl) ([]SpendableOut, error) {
+	if coinControl == nil {
+		coinControl = &CoinControl{}
+	}
+
+	selector := coinControl.Selector
+	if selector == nil {
+		selector = coinSelectors[DefaultCoinSelector]
+	}
+
+	outs = append([]SpendableOut{}, outs...)
+
+	var selected []SpendableOut
+	pinnedSum := 0
+	for _, op := range coinControl.Pinned {
+		idx := -1
+		for i, out := range outs {
+			if bytes.Equal(out.Txid, op.Txid) && out.Vout == op.Vout {
+				idx = i
+				break
+			}
+		}
+
+		if idx < 0 {
+			return nil, fmt.Errorf("Outpoint not spendable: %s", op)
+		}
+
+		selected = append(selected, outs[idx])
+		pinnedSum += outs[idx].Value
+		outs = append(outs[:idx], outs[idx + 1:]...)
+	}
+
+	if pinnedSum >= amount {
+		return selected, nil
+	}
+
+	total := pinnedSum
+	for _, out := range outs {
+		total += out.Value
+	}
+
+	if total < amount {
+		return append(selected, outs...), nil
+	}
+
+	return append(selected, selector.Select(outs, amount - pinnedSum)...), nil
+}
+
+type LargestFirst struct{}
+
+func (LargestFirst) Select(outs []SpendableOut, amount int) []SpendableOut {
+	sorted := sortOuts(outs, func(a, b SpendableOut) bool { return a.Value > b.Value })
+	return accumulateOuts(sorted, amount)
+}
+
+type SmallestFirst struct{}
+
+func (SmallestFirst) Select(outs []SpendableOut, amount int) []SpendableOut {
+	sorted := sortOuts(outs, func(a, b SpendableOut) bool { return a.Value < b.Value })
+	return accumulateOuts(sorted, amount)
+}
+
+type RandomSelector struct{}
+
+func (RandomSelector) Select(outs []SpendableOut, amount int) []SpendableOut {
+	shuffled := append([]SpendableOut{}, outs...)
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return accumulateOuts(shuffled, amount)
+}
+
+type BranchAndBound struct {
+	Fallback CoinSelector // selector used when no exact match is found
+}
+
+func (bnb BranchAndBound) Select(outs []SpendableOut, amount int) []SpendableOut {
+	sorted := sortOuts(outs, func(a, b SpendableOut) bool { return a.Value > b.Value })
+
+	// remains[i] is the sum of sorted[i:], to prune the branches which cannot reach the amount.
+	remains := make([]int, len(sorted) + 1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remains[i] = remains[i + 1] + sorted[i].Value
+	}
+
+	var selected []SpendableOut
+	tries := 0
+
+	var search func(idx, sum int) bool
+	search = func(idx, sum int) bool {
+		tries++
+		if sum == amount {
+			return true
+		}
+
+		if idx >= len(sorted) || sum > amount || sum + remains[idx] < amount || tries > bnbMaxTries {
+			return false
+		}
+
+		// include sorted[idx] first, and exclude it next.
+		selected = append(selected, sorted[idx])
+		if search(idx + 1, sum + sorted[idx].Value) {
+			return true
+		}
+		selected = selected[:len(selected) - 1]
+
+		return search(idx + 1, sum)
+	}
+
+	if search(0, 0) {
+		return selected
+	}
+
+	if bnb.Fallback == nil {
+		return LargestFirst{}.Select(outs, amount)
+	}
+
+	return bnb.Fallback.Select(outs, amount)
+}
+
+func sortOuts(outs []SpendableOut, less func(a, b SpendableOut) bool) []SpendableOut {
+	sorted := append([]SpendableOut{}, outs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+func accumulateOuts(outs []SpendableOut, amount int) []SpendableOut {
+	var selected []SpendableOut
+
+	sum := 0
+	for _, out := range outs {
+		if sum >= amount {
+			break
+		}
+
+		selected = append(selected, out)
+		sum += out.Value
+	}
+
+	return selected
+}
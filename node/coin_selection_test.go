@@ -0,0 +1,95 @@
+package node
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestOuts(values ...int) []SpendableOut {
+	var outs []SpendableOut
+	for i, value := range values {
+		outs = append(outs, SpendableOut{
+			Outpoint: Outpoint{Txid: []byte{byte(i)}, Vout: i},
+			Value:    value,
+		})
+	}
+	return outs
+}
+
+func sumOuts(outs []SpendableOut) int {
+	sum := 0
+	for _, out := range outs {
+		sum += out.Value
+	}
+	return sum
+}
+
+func TestCoinSelectors(t *testing.T) {
+	outs := newTestOuts(1, 7, 3, 10, 5)
+
+	largest := LargestFirst{}.Select(outs, 12)
+	assert.Equal(t, []int{10, 7}, []int{largest[0].Value, largest[1].Value})
+
+	smallest := SmallestFirst{}.Select(outs, 8)
+	assert.Equal(t, 3, len(smallest))
+	assert.Equal(t, 9, sumOuts(smallest))
+
+	random := RandomSelector{}.Select(outs, 20)
+	assert.True(t, sumOuts(random) >= 20)
+
+	// branch and bound finds the exact match, so that no change is made.
+	exact := BranchAndBound{}.Select(outs, 9)
+	assert.Equal(t, 9, sumOuts(exact))
+	exact = BranchAndBound{}.Select(outs, 26)
+	assert.Equal(t, 26, sumOuts(exact))
+
+	// branch and bound falls back when no exact match exists.
+	fallback := BranchAndBound{Fallback: LargestFirst{}}.Select(newTestOuts(4, 6), 5)
+	assert.Equal(t, 1, len(fallback))
+	assert.Equal(t, 6, fallback[0].Value)
+}
+
+func TestParseOutpoint(t *testing.T) {
+	op, err := ParseOutpoint("0a1b:2")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x0a, 0x1b}, op.Txid)
+	assert.Equal(t, 2, op.Vout)
+	assert.Equal(t, "0a1b:2", op.String())
+
+	for _, s := range []string{"0a1b", "xyz:1", "0a1b:-1", "0a1b:x"} {
+		_, err = ParseOutpoint(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestPinSpentOutpoint(t *testing.T) {
+	t.Chdir(t.TempDir())
+	MiningOutput = io.Discard
+	defer func() { MiningOutput = os.Stdout }()
+
+	wallet := NewWallet(Secp256k1)
+	addr := string(wallet.GetAddress())
+	bc := CreateBlockchain("test", addr)
+	defer bc.Close()
+	utxoSet := UTXOSet{bc}
+	utxoSet.Reindex()
+
+	genesis, err := bc.GetBlockByHeight(0)
+	assert.Nil(t, err)
+	spent := Outpoint{Txid: genesis.Txs[0].ID, Vout: 0}
+
+	tx, err := NewTxBuilder(&utxoSet).AddSource(wallet).AddRecipient(addr, 1).Build()
+	assert.Nil(t, err)
+	utxoSet.Update(bc.MineBlock([]*Transaction{NewCoinbaseTx(addr, ""), tx}))
+
+	// the outpoint from the user is spent by the mined transaction.
+	_, err = NewTxBuilder(&utxoSet).AddSource(wallet).AddRecipient(addr, 1).
+		SetCoinControl(&CoinControl{Pinned: []Outpoint{spent}}).Build()
+	assert.EqualError(t, err, "Outpoint not spendable: " + spent.String())
+
+	_, err = SelectOuts(newTestOuts(1, 2), 1, &CoinControl{Pinned: []Outpoint{spent}})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,156 @@
+package node
+
+import (
+	"bytes"
+	"sync"
+)
+
+/**
+  @ Events
+    - blockconnected:    a block becomes a part of the best chain, by mining or receiving it.
+    - blockdisconnected: a block leaves the best chain by reorganization.
+    - txaccepted:        a transaction is accepted to the mempool.
+    - txremoved:         a transaction is removed from the mempool, because it is mined, replaced, conflicting, invalid in a block
+                         or evicted from the full mempool.
+
+  A subscriber with a slow receiver misses events instead of blocking the publisher.
+  A function subscriber is called in Publish, so that it never misses events, such as an index following the blocks.
+*/
+
+type EventType string
+
+const (
+	BlockConnected    EventType = "blockconnected"
+	BlockDisconnected EventType = "blockdisconnected"
+	TxAccepted        EventType = "txaccepted"
+	TxRemoved         EventType = "txremoved"
+)
+
+const (
+	RemovedMined    = "mined"
+	RemovedReplaced = "replaced"
+	RemovedConflict = "conflict"
+	RemovedInvalid  = "invalid"
+	RemovedEvicted  = "evicted"
+)
+
+type Event struct {
+	Type   EventType
+	Block  *Block       // for block events
+	Tx     *Transaction // for transaction events
+	Reason string       // for txremoved
+}
+
+// Touches reports whether the event has a transaction paying to or spending from the public key hash.
+func (e Event) Touches(pkeyHash []byte) bool {
+	if e.Tx != nil {
+		return txTouches(e.Tx, pkeyHash)
+	}
+
+	if e.Block != nil {
+		for _, tx := range e.Block.Txs {
+			if txTouches(tx, pkeyHash) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+type Subscription struct {
+	C      <-chan Event
+	c      chan Event
+	filter func(Event) bool
+}
+
+// EventBus delivers the published events to the subscribers. A nil EventBus discards events.
+type EventBus struct {
+	mu    sync.RWMutex
+	subs  map[*Subscription]bool
+	funcs []funcSubscription
+}
+
+type funcSubscription struct {
+	filter func(Event) bool
+	fn     func(Event)
+}
+
+// Subscribe subscribes the events passing filter, or all the events if filter is nil.
+func (b *EventBus) Subscribe(filter func(Event) bool, bufSize int) *Subscription {
+	c := make(chan Event, bufSize)
+	sub := &Subscription{C: c, c: c, filter: filter}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subs[sub] = true
+	return sub
+}
+
+// SubscribeFunc calls fn for the events passing filter, or all the events if filter is nil, in Publish.
+// fn must not publish events.
+func (b *EventBus) SubscribeFunc(filter func(Event) bool, fn func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.funcs = append(b.funcs, funcSubscription{filter: filter, fn: fn})
+}
+
+func (b *EventBus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs[sub] {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
+
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, sub := range b.funcs {
+		if sub.filter == nil || sub.filter(e) {
+			sub.fn(e)
+		}
+	}
+
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+
+		select {
+		case sub.c <- e:
+		default:
+		}
+	}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*Subscription]bool)}
+}
+
+func txTouches(tx *Transaction, pkeyHash []byte) bool {
+	for _, out := range tx.Vouts {
+		if out.LockedWith(pkeyHash) {
+			return true
+		}
+	}
+
+	if !tx.IsCoinbase() {
+		for _, in := range tx.Vins {
+			if bytes.Equal(HashPkey(in.Pkey), pkeyHash) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
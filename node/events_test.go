@@ -0,0 +1,81 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus()
+	wallet := NewWallet(Secp256k1)
+	tx := NewCoinbaseTx(string(wallet.GetAddress()), "")
+
+	all := bus.Subscribe(nil, 1)
+	touching := bus.Subscribe(func(e Event) bool { return e.Touches(wallet.GetPkeyHash()) }, 1)
+
+	bus.Publish(Event{Type: TxAccepted, Tx: tx})
+	bus.Publish(Event{Type: TxAccepted, Tx: NewCoinbaseTx(string(NewWallet(P256).GetAddress()), "")})
+
+	// the second event is dropped for the full buffer, and filtered out for touching.
+	assert.Equal(t, tx, (<-all.C).Tx)
+	assert.Equal(t, tx, (<-touching.C).Tx)
+	assert.Len(t, all.C, 0)
+	assert.Len(t, touching.C, 0)
+
+	bus.Unsubscribe(all)
+	_, ok := <-all.C
+	assert.False(t, ok)
+
+	var nilBus *EventBus
+	nilBus.Publish(Event{Type: TxAccepted, Tx: tx})
+}
+
+func TestAddBlockEvents(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	addr := string(NewWallet(Secp256k1).GetAddress())
+	bc := CreateBlockchain("test", addr)
+	defer bc.Close()
+	genesis, err := bc.GetBlockByHeight(0)
+	assert.Nil(t, err)
+
+	sub := bc.Events().Subscribe(nil, 16)
+	newBlock := func(prev *Block) *Block {
+		return NewBlock([]*Transaction{NewCoinbaseTx(addr, "")}, prev.Hash, prev.Height + 1)
+	}
+	next := func() (EventType, []byte) {
+		e := <-sub.C
+		return e.Type, e.Block.Hash
+	}
+
+	a1 := newBlock(&genesis)
+	bc.AddBlock(a1)
+	typ, hash := next()
+	assert.Equal(t, BlockConnected, typ)
+	assert.Equal(t, a1.Hash, hash)
+
+	// a block of the same height does not change the tip.
+	b1 := newBlock(&genesis)
+	bc.AddBlock(b1)
+	assert.Len(t, sub.C, 0)
+
+	b2 := newBlock(b1)
+	bc.AddBlock(b2)
+	for _, expected := range []struct {
+		typ   EventType
+		block *Block
+	}{{BlockDisconnected, a1}, {BlockConnected, b1}, {BlockConnected, b2}} {
+		typ, hash = next()
+		assert.Equal(t, expected.typ, typ)
+		assert.Equal(t, expected.block.Hash, hash)
+	}
+
+	// only the new tip is connected, if its ancestors are unknown.
+	orphan := newBlock(newBlock(b2))
+	bc.AddBlock(orphan)
+	typ, hash = next()
+	assert.Equal(t, BlockConnected, typ)
+	assert.Equal(t, orphan.Hash, hash)
+	assert.Len(t, sub.C, 0)
+}
@@ -0,0 +1,191 @@
+package node
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+/**
+  @ How to Derive HD (Hierarchical Deterministic) Keys (BIP32-style)
+
+         Mnemonic (12 words) + Passphrase
+                      V
+         PBKDF2( HMAC-SHA512, 2048 rounds )
+                      V
+                     Seed
+                      V
+       HMAC-SHA512( "gChain seed", Seed ) = IL + IR
+                      V
+    Master Private Key = IL, Master Chain Code = IR
+                      V
+  CKDpriv( parent, index ) = HMAC-SHA512( Parent Chain Code, Data ) = IL + IR
+    - hardened (index >= 2^31): Data = 0x00 + Parent Private Key + index
+    - normal   (index <  2^31): Data = Compressed Parent Public Key + index
+    - Child Private Key = (IL + Parent Private Key) mod N, Child Chain Code = IR
+
+  @ Derivation Paths
+    - receive chain: m/44'/0'/0'/0/i
+    - change chain:  m/44'/0'/0'/1/i
+*/
+
+const (
+	HardenedKeyStart = uint32(0x80000000)
+	ReceiveChain     = uint32(0)
+	ChangeChain      = uint32(1)
+	hdAccountPath    = "m/44'/0'/0'"
+	hdMasterKey      = "gChain seed"
+	mnemonicEntropy  = 128 // 12 words
+)
+
+var errInvalidChildKey = errors.New("Invalid child key")
+
+type ExtendedKey struct {
+	Key       []byte // private key
+	ChainCode []byte
+	Depth     byte
+	Index     uint32
+	Type      KeyType
+}
+
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	curve := k.Type.Curve()
+
+	var data []byte
+	if index >= HardenedKeyStart {
+		data = append([]byte{0x00}, paddedKey(k.Key)...)
+	} else {
+		skey := PrivateKeyFromBytes(k.Type, k.Key)
+		data = MarshalPkey(k.Type, &skey.PublicKey, true)[1:] // SEC1 compressed encoding without the key type
+	}
+
+	indexBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(indexBytes, index)
+	data = append(data, indexBytes...)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := curve.Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, errInvalidChildKey
+	}
+
+	childKey := il.Add(il, new(big.Int).SetBytes(k.Key))
+	childKey.Mod(childKey, n)
+	if childKey.Sign() == 0 {
+		return nil, errInvalidChildKey
+	}
+
+	return &ExtendedKey{
+		Key:       paddedKey(childKey.Bytes()),
+		ChainCode: sum[32:],
+		Depth:     k.Depth + 1,
+		Index:     index,
+		Type:      k.Type,
+	}, nil
+}
+
+func (k *ExtendedKey) Derive(path []uint32) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		child, err := key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+
+	return key, nil
+}
+
+func (k *ExtendedKey) Wallet(path string) *Wallet {
+	skey := PrivateKeyFromBytes(k.Type, k.Key)
+	return &Wallet{
+		Skey: skey,
+		Pkey: MarshalPkey(k.Type, &skey.PublicKey, true),
+		Path: path,
+		Type: k.Type,
+	}
+}
+
+func NewMasterKey(seed []byte, kt KeyType) (*ExtendedKey, error) {
+	mac := hmac.New(sha512.New, []byte(hdMasterKey))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key := new(big.Int).SetBytes(sum[:32])
+	if key.Sign() == 0 || key.Cmp(kt.Curve().Params().N) >= 0 {
+		return nil, errors.New("Invalid master key")
+	}
+
+	return &ExtendedKey{
+		Key:       sum[:32],
+		ChainCode: sum[32:],
+		Depth:     0,
+		Index:     0,
+		Type:      kt,
+	}, nil
+}
+
+func NewMnemonic() string {
+	entropy, err := bip39.NewEntropy(mnemonicEntropy)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return mnemonic
+}
+
+func NewSeedFromMnemonic(mnemonic, passphrase string) ([]byte, error) {
+	return bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+}
+
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("Invalid derivation path: %s", path)
+	}
+
+	var indexes []uint32
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'")
+		num, err := strconv.ParseUint(strings.TrimSuffix(part, "'"), 10, 32)
+		if err != nil || uint32(num) >= HardenedKeyStart {
+			return nil, fmt.Errorf("Invalid derivation path: %s", path)
+		}
+
+		index := uint32(num)
+		if hardened {
+			index += HardenedKeyStart
+		}
+		indexes = append(indexes, index)
+	}
+
+	return indexes, nil
+}
+
+func hdPath(chain, index uint32) string {
+	return fmt.Sprintf("%s/%d/%d", hdAccountPath, chain, index)
+}
+
+func paddedKey(key []byte) []byte {
+	padded := make([]byte, 32)
+	copy(padded[32 - len(key):], key)
+	return padded
+}
@@ -0,0 +1,50 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestParseDerivationPath(t *testing.T) {
+	indexes, err := ParseDerivationPath("m/44'/0'/0'/1/5")
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{HardenedKeyStart + 44, HardenedKeyStart, HardenedKeyStart, 1, 5}, indexes)
+
+	_, err = ParseDerivationPath("44'/0'")
+	assert.NotNil(t, err, "Path must start with m")
+
+	_, err = ParseDerivationPath("m/x")
+	assert.NotNil(t, err, "Path index must be a number")
+}
+
+func TestDeriveWallet(t *testing.T) {
+	ws := Wallets{Wallets: make(map[string]*Wallet)}
+	assert.Nil(t, ws.SetMnemonic(testMnemonic, DefaultKeyType))
+	assert.True(t, ws.IsHD())
+
+	addr0, err := ws.CreateHDWallet(ReceiveChain)
+	assert.Nil(t, err)
+	addr1, err := ws.CreateHDWallet(ReceiveChain)
+	assert.Nil(t, err)
+	change0, err := ws.CreateHDWallet(ChangeChain)
+	assert.Nil(t, err)
+
+	assert.NotEqual(t, addr0, addr1)
+	assert.NotEqual(t, addr0, change0)
+	assert.Equal(t, uint32(2), ws.ReceiveIndex)
+	assert.Equal(t, uint32(1), ws.ChangeIndex)
+	assert.Equal(t, "m/44'/0'/0'/1/0", ws.Wallets[change0].Path)
+
+	// the same mnemonic derives the same addresses.
+	restored := Wallets{Wallets: make(map[string]*Wallet)}
+	assert.Nil(t, restored.SetMnemonic(testMnemonic, DefaultKeyType))
+	addr, err := restored.CreateHDWallet(ReceiveChain)
+	assert.Nil(t, err)
+	assert.Equal(t, addr0, addr)
+	assert.True(t, ValidateAddress(addr))
+
+	assert.NotNil(t, restored.SetMnemonic("abandon abandon", DefaultKeyType), "Invalid mnemonic must fail")
+}
@@ -0,0 +1,261 @@
+package node
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"log"
+
+	"github.com/boltdb/bolt"
+)
+
+/**
+  @ Address History Index
+    - key:   Public Key Hash (20) + Height (4) + Transaction ID (32) + Direction (1)
+    - value: Amount (8)
+    - A transaction has a received entry for each address locking its outputs,
+      and a sent entry for each address owning the outputs spent by its inputs.
+    - The index is optional. It is built by Reindex, and then updated by the blockconnected and blockdisconnected events,
+      so that the entries of the blocks disconnected by reorganization are removed.
+*/
+
+const historyBucket = "history"
+
+type TxDirection byte
+
+const (
+	Received TxDirection = iota
+	Sent
+)
+
+func (d TxDirection) String() string {
+	if d == Sent {
+		return "sent"
+	}
+	return "received"
+}
+
+type HistoryEntry struct {
+	Height    int
+	Txid      []byte
+	Direction TxDirection
+	Amount    int
+}
+
+type HistoryIndex struct {
+	BC *Blockchain
+}
+
+// Enabled reports whether the index has been built by Reindex.
+func (h HistoryIndex) Enabled() bool {
+	enabled := false
+
+	if err := h.BC.db.View(func(tx *bolt.Tx) error {
+		enabled = tx.Bucket([]byte(historyBucket)) != nil
+		return nil
+
+	}); err != nil {
+		log.Panic(err)
+	}
+
+	return enabled
+}
+
+// History returns the entries of the public key hash from the newest, skipping offset entries up to limit entries,
+// and the total number of the entries.
+func (h HistoryIndex) History(pkeyHash []byte, offset, limit int) ([]HistoryEntry, int) {
+	var entries []HistoryEntry
+
+	if err := h.BC.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(historyBucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for k, v := c.Seek(pkeyHash); k != nil && bytes.HasPrefix(k, pkeyHash); k, v = c.Next() {
+			entries = append(entries, unmarshalHistoryEntry(k, v))
+		}
+
+		return nil
+
+	}); err != nil {
+		log.Panic(err)
+	}
+
+	total := len(entries)
+	for i, j := 0, total - 1; i < j; i, j = i + 1, j - 1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+
+	if offset < 0 || offset >= total {
+		return nil, total
+	}
+
+	entries = entries[offset:]
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+
+	return entries, total
+}
+
+func (h HistoryIndex) Reindex() {
+	bucketName := []byte(historyBucket)
+
+	// keep all the transactions to find the outputs spent by the inputs.
+	var blocks []*Block
+	txs := make(map[string]Transaction)
+	iter := h.BC.Iterator()
+	for iter.HasNext() {
+		block := iter.Next()
+		blocks = append(blocks, block)
+		for _, tx := range block.Txs {
+			txs[hex.EncodeToString(tx.ID)] = *tx
+		}
+	}
+
+	if err := h.BC.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(bucketName); err != nil && err != bolt.ErrBucketNotFound {
+			log.Panic(err)
+		}
+
+		b, err := tx.CreateBucket(bucketName)
+		if err != nil {
+			log.Panic(err)
+		}
+
+		for _, block := range blocks {
+			putHistoryEntries(b, block, txs)
+		}
+
+		return nil
+
+	}); err != nil {
+		log.Panic(err)
+	}
+}
+
+// Update adds the entries of the block connected to the blockchain, if the index is enabled.
+func (h HistoryIndex) Update(block *Block) {
+	if !h.Enabled() {
+		return
+	}
+
+	// the inputs could spend the outputs of the preceding transactions in the same block.
+	prevTxs := make(map[string]Transaction)
+	for _, tx := range block.Txs {
+		if !tx.IsCoinbase() {
+			found, err := h.BC.findPrevTxs(block.PrevHash, tx, prevTxs)
+			if err != nil {
+				log.Panic(err)
+			}
+
+			for txid, prevTx := range found {
+				prevTxs[txid] = prevTx
+			}
+		}
+
+		prevTxs[hex.EncodeToString(tx.ID)] = *tx
+	}
+
+	if err := h.BC.db.Update(func(tx *bolt.Tx) error {
+		putHistoryEntries(tx.Bucket([]byte(historyBucket)), block, prevTxs)
+		return nil
+
+	}); err != nil {
+		log.Panic(err)
+	}
+}
+
+// Disconnect removes the entries of the block disconnected from the blockchain, if the index is enabled.
+func (h HistoryIndex) Disconnect(block *Block) {
+	if err := h.BC.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(historyBucket))
+		if b == nil {
+			return nil
+		}
+
+		for _, t := range block.Txs {
+			for _, out := range t.Vouts {
+				if err := b.Delete(historyKey(out.PkeyHash, block.Height, t.ID, Received)); err != nil {
+					log.Panic(err)
+				}
+			}
+
+			if t.IsCoinbase() {
+				continue
+			}
+
+			// the public key of a input is hashed to the public key hash of the spent output.
+			for _, in := range t.Vins {
+				if err := b.Delete(historyKey(HashPkey(in.Pkey), block.Height, t.ID, Sent)); err != nil {
+					log.Panic(err)
+				}
+			}
+		}
+
+		return nil
+
+	}); err != nil {
+		log.Panic(err)
+	}
+}
+
+// handleEvent updates the index by the block event, which is subscribed by the blockchain.
+func (h HistoryIndex) handleEvent(e Event) {
+	switch e.Type {
+	case BlockConnected:
+		h.Update(e.Block)
+	case BlockDisconnected:
+		h.Disconnect(e.Block)
+	}
+}
+
+func putHistoryEntries(b *bolt.Bucket, block *Block, prevTxs map[string]Transaction) {
+	amounts := make(map[string]int)
+
+	for _, tx := range block.Txs {
+		for _, out := range tx.Vouts {
+			amounts[string(historyKey(out.PkeyHash, block.Height, tx.ID, Received))] += out.Value
+		}
+
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		for _, in := range tx.Vins {
+			prevOut := prevTxs[hex.EncodeToString(in.Txid)].Vouts[in.Vout]
+			amounts[string(historyKey(prevOut.PkeyHash, block.Height, tx.ID, Sent))] += prevOut.Value
+		}
+	}
+
+	for key, amount := range amounts {
+		value := make([]byte, 8)
+		binary.BigEndian.PutUint64(value, uint64(amount))
+		if err := b.Put([]byte(key), value); err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+// historyKey makes the key sorted by the height for the public key hash.
+func historyKey(pkeyHash []byte, height int, txid []byte, direction TxDirection) []byte {
+	heightBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(heightBytes, uint32(height))
+
+	key := append([]byte{}, pkeyHash...)
+	key = append(key, heightBytes...)
+	key = append(key, txid...)
+	return append(key, byte(direction))
+}
+
+func unmarshalHistoryEntry(key, value []byte) HistoryEntry {
+	n := len(key)
+	return HistoryEntry{
+		Height:    int(binary.BigEndian.Uint32(key[n - 37:n - 33])),
+		Txid:      append([]byte{}, key[n - 33:n - 1]...),
+		Direction: TxDirection(key[n - 1]),
+		Amount:    int(binary.BigEndian.Uint64(value)),
+	}
+}
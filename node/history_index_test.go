@@ -0,0 +1,104 @@
+package node
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistoryIndex(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "history.db"), 0600, nil)
+	assert.Nil(t, err)
+	defer db.Close()
+
+	index := HistoryIndex{&Blockchain{db: db}}
+	assert.False(t, index.Enabled())
+
+	index.Reindex()
+	assert.True(t, index.Enabled())
+
+	wallet1, wallet2 := NewWallet(Secp256k1), NewWallet(P256)
+	coinbase := NewCoinbaseTx(string(wallet1.GetAddress()), "")
+	tx := Transaction{
+		Vins:  []TxIn{{Txid: coinbase.ID, Vout: 0, Pkey: wallet1.Pkey}},
+		Vouts: []TxOut{*NewTxOut(4, string(wallet2.GetAddress())), *NewTxOut(Params().Subsidy - 4, string(wallet1.GetAddress()))},
+	}
+	tx.ID = tx.Hash()
+	block := &Block{Txs: []*Transaction{coinbase, &tx}, Height: 1}
+
+	// the transaction spending the coinbase in the same block is indexed.
+	index.Update(block)
+
+	entries, total := index.History(wallet1.GetPkeyHash(), 0, 0)
+	assert.Equal(t, 3, total)
+	sent, received := 0, 0
+	for _, e := range entries {
+		assert.Equal(t, 1, e.Height)
+		if e.Direction == Sent {
+			sent += e.Amount
+		} else {
+			received += e.Amount
+		}
+	}
+	assert.Equal(t, Params().Subsidy, sent)
+	assert.Equal(t, 2 * Params().Subsidy - 4, received)
+
+	entries, total = index.History(wallet2.GetPkeyHash(), 0, 10)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, HistoryEntry{Height: 1, Txid: tx.ID, Direction: Received, Amount: 4}, entries[0])
+	}
+
+	entries, total = index.History(wallet1.GetPkeyHash(), 2, 10)
+	assert.Equal(t, 3, total)
+	assert.Len(t, entries, 1)
+
+	index.Disconnect(block)
+	_, total = index.History(wallet1.GetPkeyHash(), 0, 0)
+	assert.Equal(t, 0, total)
+	_, total = index.History(wallet2.GetPkeyHash(), 0, 0)
+	assert.Equal(t, 0, total)
+}
+
+func TestHistoryIndexReorg(t *testing.T) {
+	t.Chdir(t.TempDir())
+	MiningOutput = io.Discard
+	defer func() { MiningOutput = os.Stdout }()
+
+	wallet1, wallet2, wallet3 := NewWallet(Secp256k1), NewWallet(P256), NewWallet(P256)
+	bc := CreateBlockchain("test", string(wallet1.GetAddress()))
+	defer bc.Close()
+	index := HistoryIndex{bc}
+	index.Reindex()
+
+	genesis, _ := bc.Tip()
+	genesisBlock, err := bc.GetBlock(genesis)
+	assert.Nil(t, err)
+	tx := newSignedTx(wallet1, []PrevOut{prevOutOf(*genesisBlock.Txs[0], 0)}, Params().Subsidy)
+	tx.Vouts[0] = *NewTxOut(Params().Subsidy, string(wallet2.GetAddress()))
+	tx.ID = tx.Hash()
+	bc.SignTx(&tx, wallet1.Skey)
+
+	// the block connected is indexed by the event.
+	bc.AddBlock(NewBlock([]*Transaction{NewCoinbaseTx(string(wallet1.GetAddress()), ""), &tx}, genesis, 1))
+	_, total := index.History(wallet2.GetPkeyHash(), 0, 0)
+	assert.Equal(t, 1, total)
+
+	// the longer branch disconnects the block, and connects its blocks.
+	fork := NewBlock([]*Transaction{NewCoinbaseTx(string(wallet3.GetAddress()), "")}, genesis, 1)
+	bc.AddBlock(fork)
+	bc.AddBlock(NewBlock([]*Transaction{NewCoinbaseTx(string(wallet3.GetAddress()), "")}, fork.Hash, 2))
+
+	_, total = index.History(wallet2.GetPkeyHash(), 0, 0)
+	assert.Equal(t, 0, total, "The entries of the disconnected block are removed")
+	_, total = index.History(wallet1.GetPkeyHash(), 0, 0)
+	assert.Equal(t, 1, total, "Only the genesis reward is left")
+	entries, total := index.History(wallet3.GetPkeyHash(), 0, 0)
+	assert.Equal(t, 2, total)
+	for _, e := range entries {
+		assert.Equal(t, Received, e.Direction)
+	}
+}
@@ -0,0 +1,218 @@
+package node
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+/**
+  @ Public Key Encoding
+    - typed public key: Key Type + SEC1 Encoding
+      - compressed:   Key Type (1) + 0x02 or 0x03 (y parity) + X (32) = 34 bytes
+      - uncompressed: Key Type (1) + 0x04 + X (32) + Y (32)          = 66 bytes
+    - legacy public key: X + Y without leading zeros (P-256 only)
+      - It is still accepted to verify the transactions signed before the key type was introduced.
+
+  @ Key Types
+    Key Type   Curve       Address Version on mainnet   Address Prefix
+    0x00       P-256       0x00                         1
+    0x01       secp256k1   0x26                         G
+    - The address versions are decided by the network. (See params.go)
+*/
+
+type KeyType byte
+
+const (
+	P256      KeyType = 0x00
+	Secp256k1 KeyType = 0x01
+)
+
+const (
+	DefaultKeyType = Secp256k1
+
+	sec1Compressed   = 0x02
+	sec1Uncompressed = 0x04
+)
+
+var errInvalidPkey = errors.New("Invalid public key")
+
+type keyCurve struct {
+	name  string
+	curve elliptic.Curve
+	parse func(encoded []byte) (*ecdsa.PublicKey, error) // parses the SEC1 encoding
+}
+
+// keyCurves registers the supported key types. A new key type is plugged in by adding its curve here.
+var keyCurves = map[KeyType]keyCurve{
+	P256:      {"p256", elliptic.P256(), parseP256Pkey},
+	Secp256k1: {"secp256k1", secp256k1.S256(), parseSecp256k1Pkey},
+}
+
+func (kt KeyType) Curve() elliptic.Curve {
+	return kt.keyCurve().curve
+}
+
+// AddressVersion returns the address version of the key type on the network.
+func (kt KeyType) AddressVersion() byte {
+	version, exist := activeNet.AddressVersions[kt]
+	if !exist {
+		log.Panicf("Invalid key type: %d", kt)
+	}
+
+	return version
+}
+
+// Size returns the fixed width in bytes of a scalar or a coordinate on the curve.
+func (kt KeyType) Size() int {
+	return (kt.Curve().Params().BitSize + 7) / 8
+}
+
+func (kt KeyType) String() string {
+	return kt.keyCurve().name
+}
+
+func (kt KeyType) keyCurve() keyCurve {
+	kc, exist := keyCurves[kt]
+	if !exist {
+		log.Panicf("Invalid key type: %d", kt)
+	}
+
+	return kc
+}
+
+func ParseKeyType(name string) (KeyType, error) {
+	for kt, kc := range keyCurves {
+		if kc.name == name {
+			return kt, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Invalid key type: %s", name)
+}
+
+func keyTypeFromAddressVersion(version byte) (KeyType, bool) {
+	for kt := range keyCurves {
+		if kt.AddressVersion() == version {
+			return kt, true
+		}
+	}
+
+	return 0, false
+}
+
+func GenerateKey(kt KeyType) ecdsa.PrivateKey {
+	skey, err := ecdsa.GenerateKey(kt.Curve(), rand.Reader)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return *skey
+}
+
+// PrivateKeyFromBytes restores the private key and its public key from the scalar.
+func PrivateKeyFromBytes(kt KeyType, d []byte) ecdsa.PrivateKey {
+	curve := kt.Curve()
+	x, y := curve.ScalarBaseMult(d)
+	return ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: curve,
+			X:     x,
+			Y:     y,
+		},
+		D: new(big.Int).SetBytes(d),
+	}
+}
+
+// MarshalPkey encodes the public key as the key type followed by the SEC1 encoding.
+func MarshalPkey(kt KeyType, pkey *ecdsa.PublicKey, compressed bool) []byte {
+	size := kt.Size()
+
+	var encoded []byte
+	if compressed {
+		encoded = make([]byte, 1 + size)
+		encoded[0] = byte(sec1Compressed + pkey.Y.Bit(0))
+		pkey.X.FillBytes(encoded[1:])
+	} else {
+		encoded = make([]byte, 1 + 2 * size)
+		encoded[0] = sec1Uncompressed
+		pkey.X.FillBytes(encoded[1:1 + size])
+		pkey.Y.FillBytes(encoded[1 + size:])
+	}
+
+	return append([]byte{byte(kt)}, encoded...)
+}
+
+// ParsePkey decodes the typed public key or the legacy public key.
+// legacy reports whether pkey is the legacy public key, which has no key type and variable width.
+func ParsePkey(pkey []byte) (pub *ecdsa.PublicKey, legacy bool, err error) {
+	if len(pkey) == 0 {
+		return nil, false, errInvalidPkey
+	}
+
+	if kc, exist := keyCurves[KeyType(pkey[0])]; exist {
+		size := (kc.curve.Params().BitSize + 7) / 8
+		if len(pkey) == 2 + size || len(pkey) == 2 + 2 * size {
+			pub, err := kc.parse(pkey[1:])
+			return pub, false, err
+		}
+	}
+
+	// x and y of the legacy public key could have no leading zeros, so the split point on the curve is searched.
+	curve := elliptic.P256()
+	size := (curve.Params().BitSize + 7) / 8
+	for split := len(pkey) - size; split <= size; split++ {
+		if split < 1 || split >= len(pkey) {
+			continue
+		}
+
+		x := new(big.Int).SetBytes(pkey[:split])
+		y := new(big.Int).SetBytes(pkey[split:])
+		if curve.IsOnCurve(x, y) {
+			return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true, nil
+		}
+	}
+
+	return nil, false, errInvalidPkey
+}
+
+func parseP256Pkey(encoded []byte) (*ecdsa.PublicKey, error) {
+	curve := elliptic.P256()
+
+	var x, y *big.Int
+	if encoded[0] == sec1Uncompressed {
+		x, y = elliptic.Unmarshal(curve, encoded)
+	} else {
+		x, y = elliptic.UnmarshalCompressed(curve, encoded)
+	}
+
+	if x == nil {
+		return nil, errInvalidPkey
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func parseSecp256k1Pkey(encoded []byte) (*ecdsa.PublicKey, error) {
+	pub, err := secp256k1.ParsePubKey(encoded)
+	if err != nil {
+		return nil, errInvalidPkey
+	}
+
+	return pub.ToECDSA(), nil
+}
+
+// marshalSig encodes the signature as r and s in the fixed width of the curve.
+func marshalSig(curve elliptic.Curve, r, s *big.Int) []byte {
+	size := (curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2 * size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
+	return sig
+}
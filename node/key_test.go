@@ -0,0 +1,92 @@
+package node
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMarshalPkey(t *testing.T) {
+	for _, kt := range []KeyType{P256, Secp256k1} {
+		skey := GenerateKey(kt)
+
+		compressed := MarshalPkey(kt, &skey.PublicKey, true)
+		assert.Equal(t, 2 + kt.Size(), len(compressed))
+		uncompressed := MarshalPkey(kt, &skey.PublicKey, false)
+		assert.Equal(t, 2 + 2 * kt.Size(), len(uncompressed))
+
+		for _, pkey := range [][]byte{compressed, uncompressed} {
+			parsed, legacy, err := ParsePkey(pkey)
+			assert.Nil(t, err)
+			assert.False(t, legacy)
+			assert.True(t, skey.PublicKey.Equal(parsed), "%s public key is restored", kt)
+		}
+	}
+
+	// the legacy public key drops the leading zeros of x and y.
+	skey := GenerateKey(P256)
+	legacyPkey := append(skey.X.Bytes(), skey.Y.Bytes()...)
+	parsed, legacy, err := ParsePkey(legacyPkey)
+	assert.Nil(t, err)
+	assert.True(t, legacy)
+	assert.True(t, skey.PublicKey.Equal(parsed))
+
+	_, _, err = ParsePkey([]byte{0x01, 0x02, 0x03})
+	assert.NotNil(t, err)
+}
+
+func TestAddressVersion(t *testing.T) {
+	p256 := NewWallet(P256)
+	secp := NewWallet(Secp256k1)
+
+	assert.True(t, strings.HasPrefix(string(p256.GetAddress()), "1"))
+	assert.True(t, strings.HasPrefix(string(secp.GetAddress()), "G"))
+	assert.True(t, ValidateAddress(string(p256.GetAddress())))
+	assert.True(t, ValidateAddress(string(secp.GetAddress())))
+	assert.False(t, ValidateAddress("G1"))
+	assert.False(t, ValidateAddress(""))
+}
+
+func TestAddressOfLeadingZeroPkeyHash(t *testing.T) {
+	// the version byte 0x00 of P-256 on mainnet and the first byte of the public key hash are both leading zeros.
+	pkeyHash := append([]byte{0x00}, bytes.Repeat([]byte{0xab}, addressPkeyHashLen - 1)...)
+	addr := string(Wallet{Type: P256, PkeyHash: pkeyHash}.GetAddress())
+
+	assert.True(t, strings.HasPrefix(addr, "11"))
+	assert.True(t, ValidateAddress(addr))
+	assert.Equal(t, pkeyHash, GetPkeyHashFromAddress([]byte(addr)))
+}
+
+func TestSignAndVerify(t *testing.T) {
+	for _, kt := range []KeyType{P256, Secp256k1} {
+		wallet := NewWallet(kt)
+		other := NewWallet(kt)
+		prevTx := NewCoinbaseTx(string(wallet.GetAddress()), "")
+		prevTxs := map[string]Transaction{hex.EncodeToString(prevTx.ID): *prevTx}
+
+		tx := Transaction{
+			Vins:  []TxIn{{Txid: prevTx.ID, Vout: 0, Pkey: wallet.Pkey}},
+			Vouts: []TxOut{*NewTxOut(Params().Subsidy, string(other.GetAddress()))},
+		}
+		tx.ID = tx.Hash()
+		tx.Sign(prevTxs, wallet.Skey)
+		assert.Equal(t, 2 * kt.Size(), len(tx.Vins[0].Sig))
+		assert.True(t, tx.Verify(prevTxs), "%s signature is verified", kt)
+
+		tx.Vouts[0].Value++
+		assert.False(t, tx.Verify(prevTxs), "Modified transaction must fail")
+		tx.Vouts[0].Value--
+
+		// another key cannot spend the output even with its valid signature.
+		stolen := Transaction{
+			Vins:  []TxIn{{Txid: prevTx.ID, Vout: 0, Pkey: other.Pkey}},
+			Vouts: tx.Vouts,
+		}
+		stolen.ID = stolen.Hash()
+		stolen.Sign(prevTxs, other.Skey)
+		assert.False(t, stolen.Verify(prevTxs), "Output owned by another key must fail")
+	}
+}
@@ -0,0 +1,503 @@
+package node
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+/**
+  @ Replace-by-Fee (opt-in)
+    - A transaction is replaceable, when any of its inputs signals it. The signal is signed with the input,
+      so that it is kept as it is while the transaction is relayed, mined and returned to the mempool by reorganization.
+    - A new transaction spending the same outputs as mempool transactions replaces them and all their descendants,
+      when the conflicting transactions are replaceable, and it pays the fees of all the replaced ones
+      plus minReplaceFee per replaceFeeUnit bytes of its own size, which pays for relaying it again.
+
+        tx1 (fee 1, replaceable) <--- tx2 (fee 1)
+         ^
+         | spend the same output     tx1' of 300 bytes replaces tx1 and tx2, if fee of tx1' >= 1 + 1 + 2.
+         |
+        tx1'
+
+  @ Mempool Limit
+    - The mempool keeps the transactions up to MaxMempoolSize bytes in total.
+    - Over the limit, the transaction of the lowest package fee rate with its descendants is evicted,
+      where the package fee rate is (fees of the transaction and its descendants) / (sizes of them).
+    - A new transaction evicted at once is rejected as ErrMempoolFull.
+
+  @ Child-Pays-for-Parent
+    - A transaction is selected into a block together with its unconfirmed ancestors,
+      by the fee rate of the package: (fees of the transaction and its ancestors) / (sizes of them).
+    - So, a high-fee child pulls its low-fee parent into a block.
+*/
+
+const (
+	MaxMempoolSize = 32 << 20 // the total size in bytes of the transactions kept in the mempool
+
+	minReplaceFee  = 2    // the fee paid by a replacement over the replaced fees, per replaceFeeUnit bytes
+	replaceFeeUnit = 1000
+)
+
+var (
+	ErrTxInMempool      = errors.New("Transaction already in mempool")
+	ErrTxNotReplaceable = errors.New("Transaction conflicts with a non-replaceable transaction in mempool")
+	ErrFeeTooLow        = errors.New("Fee does not pay the fees of the replaced transactions and the replacement fee")
+	ErrMempoolFull      = errors.New("Mempool is full of the transactions paying higher fee rate")
+	ErrInvalidTx        = errors.New("Invalid transaction")
+	ErrTxVerification   = errors.New("Transaction verification failure")
+)
+
+// OutFinder finds the unspent output of the outpoint in the blockchain. UTXOSet is an OutFinder.
+type OutFinder interface {
+	FindOut(op Outpoint) (TxOut, bool)
+}
+
+type MempoolEntry struct {
+	Tx          Transaction
+	Fee         int
+	Size        int
+	Replaceable bool
+	Time        time.Time
+}
+
+// Mempool keeps the verified transactions not mined yet. It is safe for concurrent use.
+type Mempool struct {
+	mu      sync.RWMutex
+	chain   OutFinder
+	entries map[string]*MempoolEntry
+	spends  map[string]string // outpoint -> ID of the mempool transaction spending it
+	size    int               // the total size of the transactions
+	maxSize int
+	events  *EventBus
+}
+
+// Add verifies the transaction and adds it into the mempool. It returns the transactions replaced by it.
+func (m *Mempool) Add(tx Transaction) ([]Transaction, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.add(tx)
+}
+
+func (m *Mempool) add(tx Transaction) ([]Transaction, error) {
+	// the ID is signed as it is, so that a made-up ID is rejected before it is relayed and mined.
+	if !bytes.Equal(tx.ID, tx.UnsignedHash()) {
+		return nil, ErrInvalidTx
+	}
+
+	txid := hex.EncodeToString(tx.ID)
+	if _, exist := m.entries[txid]; exist {
+		return nil, ErrTxInMempool
+	}
+
+	if tx.IsCoinbase() || len(tx.Vins) == 0 || len(tx.Vouts) == 0 {
+		return nil, ErrInvalidTx
+	}
+
+	// find the previous outputs in the mempool first, and then in the blockchain.
+	var prevOuts []PrevOut
+	inSum := 0
+	conflicts := make(map[string]bool)
+	for _, in := range tx.Vins {
+		op := Outpoint{Txid: in.Txid, Vout: in.Vout}
+		for _, prevOut := range prevOuts {
+			if prevOut.String() == op.String() {
+				return nil, fmt.Errorf("Outpoint spent twice: %s", op)
+			}
+		}
+
+		out, found := m.findOut(op)
+		if !found {
+			return nil, fmt.Errorf("Outpoint not found or already spent: %s", op)
+		}
+
+		prevOuts = append(prevOuts, PrevOut{Outpoint: op, Out: out})
+		inSum += out.Value
+
+		if spender, exist := m.spends[op.String()]; exist {
+			conflicts[spender] = true
+		}
+	}
+
+	outSum := 0
+	for _, out := range tx.Vouts {
+		if out.Value <= 0 {
+			return nil, fmt.Errorf("Invalid output value: %d", out.Value)
+		}
+		outSum += out.Value
+	}
+
+	if inSum < outSum {
+		return nil, fmt.Errorf("Outputs exceed inputs: %d > %d", outSum, inSum)
+	}
+
+	if !tx.Verify(newPrevTxs(prevOuts)) {
+		return nil, ErrTxVerification
+	}
+
+	fee := inSum - outSum
+	size := len(tx.Marshal())
+	replaced := m.descendants(conflicts)
+	if len(replaced) > 0 {
+		replacedFee := 0
+		for id := range replaced {
+			if conflicts[id] && !m.entries[id].Replaceable {
+				return nil, ErrTxNotReplaceable
+			}
+			replacedFee += m.entries[id].Fee
+		}
+
+		if fee < replacedFee + replaceFee(size) {
+			return nil, ErrFeeTooLow
+		}
+
+		for _, prevOut := range prevOuts {
+			if replaced[hex.EncodeToString(prevOut.Txid)] {
+				return nil, fmt.Errorf("Outpoint of a replaced transaction: %s", prevOut.Outpoint)
+			}
+		}
+	}
+
+	var replacedTxs []Transaction
+	for id := range replaced {
+		replacedTxs = append(replacedTxs, m.entries[id].Tx)
+		m.remove(id, RemovedReplaced)
+	}
+
+	m.entries[txid] = &MempoolEntry{
+		Tx:          tx,
+		Fee:         fee,
+		Size:        size,
+		Replaceable: tx.IsReplaceable(),
+		Time:        time.Now(),
+	}
+	m.size += size
+
+	for _, prevOut := range prevOuts {
+		m.spends[prevOut.String()] = txid
+	}
+
+	m.events.Publish(Event{Type: TxAccepted, Tx: &tx})
+
+	m.trim()
+	if m.entries[txid] == nil {
+		return nil, ErrMempoolFull
+	}
+	return replacedTxs, nil
+}
+
+// replaceFee returns the fee paid by the replacement of the size over the fees of the replaced transactions.
+func replaceFee(size int) int {
+	return minReplaceFee * ((size + replaceFeeUnit - 1) / replaceFeeUnit)
+}
+
+// trim evicts the transaction of the lowest package fee rate with its descendants, until the mempool is within its limit.
+func (m *Mempool) trim() {
+	for m.size > m.maxSize {
+		var worst string
+		var worstPkg map[string]bool
+		worstFee, worstSize := 0, 0
+
+		for id := range m.entries {
+			pkg := m.descendants(map[string]bool{id: true})
+			fee, size := 0, 0
+			for descendant := range pkg {
+				fee += m.entries[descendant].Fee
+				size += m.entries[descendant].Size
+			}
+
+			// the later one of the same fee rate is evicted first, as betterPackage selects the earlier one first.
+			if worstPkg == nil || m.betterPackage(worstFee, worstSize, worst, fee, size, id) {
+				worst, worstPkg, worstFee, worstSize = id, pkg, fee, size
+			}
+		}
+
+		for id := range worstPkg {
+			m.remove(id, RemovedEvicted)
+		}
+	}
+}
+
+// RemoveBlockTxs removes the transactions mined in the block, and the transactions conflicting with them.
+func (m *Mempool) RemoveBlockTxs(block *Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeBlockTxs(block)
+}
+
+// RemoveInvalid removes the transactions failed in a block, and their descendants,
+// so that the next block template does not include them again.
+func (m *Mempool) RemoveInvalid(txids ...[]byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invalid := make(map[string]bool)
+	for _, txid := range txids {
+		if id := hex.EncodeToString(txid); m.entries[id] != nil {
+			invalid[id] = true
+		}
+	}
+
+	for id := range m.descendants(invalid) {
+		m.remove(id, RemovedInvalid)
+	}
+}
+
+// Reorg updates the mempool for the reorganization, after the chain of the mempool follows the new tip.
+// The transactions mined in the connected blocks are removed with their conflicts, and the transactions of the disconnected blocks
+// return to the mempool. And then, the transactions spending the outputs not existing any more are removed with their descendants.
+func (m *Mempool) Reorg(disconnected, connected []*Block) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, block := range connected {
+		m.removeBlockTxs(block)
+	}
+
+	// the disconnected blocks are from the newest, so that the older transactions return first.
+	for i := len(disconnected) - 1; i >= 0; i-- {
+		for _, tx := range disconnected[i].Txs {
+			if !tx.IsCoinbase() {
+				m.add(*tx)
+			}
+		}
+	}
+
+	invalid := make(map[string]bool)
+	for id, entry := range m.entries {
+		for _, in := range entry.Tx.Vins {
+			if _, found := m.findOut(Outpoint{Txid: in.Txid, Vout: in.Vout}); !found {
+				invalid[id] = true
+				break
+			}
+		}
+	}
+
+	for id := range m.descendants(invalid) {
+		m.remove(id, RemovedConflict)
+	}
+}
+
+func (m *Mempool) removeBlockTxs(block *Block) {
+	for _, tx := range block.Txs {
+		if txid := hex.EncodeToString(tx.ID); m.entries[txid] != nil {
+			m.remove(txid, RemovedMined)
+		}
+
+		if tx.IsCoinbase() {
+			continue
+		}
+
+		conflicts := make(map[string]bool)
+		for _, in := range tx.Vins {
+			if spender, exist := m.spends[Outpoint{Txid: in.Txid, Vout: in.Vout}.String()]; exist {
+				conflicts[spender] = true
+			}
+		}
+
+		for id := range m.descendants(conflicts) {
+			m.remove(id, RemovedConflict)
+		}
+	}
+}
+
+// SelectTxs selects the transactions to mine up to maxSize bytes by the package fee rate,
+// and returns them in the order where ancestors precede their descendants, with the sum of their fees.
+func (m *Mempool) SelectTxs(maxSize int) ([]*Transaction, int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var txs []*Transaction
+	selected := make(map[string]bool)
+	totalSize := 0
+	totalFee := 0
+
+	for {
+		var best []string
+		bestFee, bestSize := 0, 0
+
+		for id := range m.entries {
+			if selected[id] {
+				continue
+			}
+
+			pkg := m.unselectedAncestors(id, selected)
+			fee, size := 0, 0
+			for _, ancestor := range pkg {
+				fee += m.entries[ancestor].Fee
+				size += m.entries[ancestor].Size
+			}
+
+			if totalSize + size > maxSize {
+				continue
+			}
+
+			if best == nil || m.betterPackage(fee, size, id, bestFee, bestSize, best[len(best) - 1]) {
+				best, bestFee, bestSize = pkg, fee, size
+			}
+		}
+
+		if best == nil {
+			break
+		}
+
+		for _, id := range best {
+			tx := m.entries[id].Tx
+			txs = append(txs, &tx)
+			selected[id] = true
+		}
+
+		totalSize += bestSize
+		totalFee += bestFee
+	}
+
+	return txs, totalFee
+}
+
+func (m *Mempool) Get(txid []byte) (MempoolEntry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, exist := m.entries[hex.EncodeToString(txid)]
+	if !exist {
+		return MempoolEntry{}, false
+	}
+
+	return *entry, true
+}
+
+// Entries returns all the entries in the arrival order.
+func (m *Mempool) Entries() []MempoolEntry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var entries []MempoolEntry
+	for _, entry := range m.entries {
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries
+}
+
+func (m *Mempool) Has(txid []byte) bool {
+	_, exist := m.Get(txid)
+	return exist
+}
+
+func (m *Mempool) Count() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.entries)
+}
+
+func (m *Mempool) findOut(op Outpoint) (TxOut, bool) {
+	if entry, exist := m.entries[hex.EncodeToString(op.Txid)]; exist {
+		if op.Vout < 0 || op.Vout >= len(entry.Tx.Vouts) {
+			return TxOut{}, false
+		}
+		return entry.Tx.Vouts[op.Vout], true
+	}
+
+	return m.chain.FindOut(op)
+}
+
+// descendants returns the transactions and all the mempool transactions spending their outputs recursively.
+func (m *Mempool) descendants(txids map[string]bool) map[string]bool {
+	result := make(map[string]bool)
+
+	var queue []string
+	for id := range txids {
+		queue = append(queue, id)
+	}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if result[id] {
+			continue
+		}
+		result[id] = true
+
+		entry := m.entries[id]
+		for vout := range entry.Tx.Vouts {
+			if spender, exist := m.spends[Outpoint{Txid: entry.Tx.ID, Vout: vout}.String()]; exist {
+				queue = append(queue, spender)
+			}
+		}
+	}
+
+	return result
+}
+
+// unselectedAncestors returns the transaction and its ancestors not selected yet, where ancestors come first.
+func (m *Mempool) unselectedAncestors(txid string, selected map[string]bool) []string {
+	var pkg []string
+	visited := make(map[string]bool)
+
+	var visit func(id string)
+	visit = func(id string) {
+		if visited[id] || selected[id] {
+			return
+		}
+		visited[id] = true
+
+		for _, in := range m.entries[id].Tx.Vins {
+			if parent := hex.EncodeToString(in.Txid); m.entries[parent] != nil {
+				visit(parent)
+			}
+		}
+		pkg = append(pkg, id)
+	}
+
+	visit(txid)
+	return pkg
+}
+
+// betterPackage compares the fee rates of the packages, and then the arrival time of the transactions.
+func (m *Mempool) betterPackage(fee, size int, txid string, bestFee, bestSize int, bestTxid string) bool {
+	if lhs, rhs := fee * bestSize, bestFee * size; lhs != rhs {
+		return lhs > rhs
+	}
+
+	if t, bestT := m.entries[txid].Time, m.entries[bestTxid].Time; !t.Equal(bestT) {
+		return t.Before(bestT)
+	}
+
+	return txid < bestTxid
+}
+
+// remove removes only the transaction. Its descendants must be removed together, unless it is mined.
+func (m *Mempool) remove(txid, reason string) {
+	entry := m.entries[txid]
+	for _, in := range entry.Tx.Vins {
+		op := Outpoint{Txid: in.Txid, Vout: in.Vout}.String()
+		if m.spends[op] == txid {
+			delete(m.spends, op)
+		}
+	}
+
+	delete(m.entries, txid)
+	m.size -= entry.Size
+	m.events.Publish(Event{Type: TxRemoved, Tx: &entry.Tx, Reason: reason})
+}
+
+// NewMempool makes the mempool publishing the transaction events to events, if it is not nil.
+func NewMempool(chain OutFinder, events *EventBus) *Mempool {
+	return &Mempool{
+		chain:   chain,
+		entries: make(map[string]*MempoolEntry),
+		spends:  make(map[string]string),
+		maxSize: MaxMempoolSize,
+		events:  events,
+	}
+}
@@ -0,0 +1,230 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testChain map[string]TxOut
+
+func (c testChain) FindOut(op Outpoint) (TxOut, bool) {
+	out, exist := c[op.String()]
+	return out, exist
+}
+
+// newSignedTx makes the transaction spending the outputs of prevTxs locked with the wallet.
+func newSignedTx(wallet *Wallet, prevOuts []PrevOut, values ...int) Transaction {
+	return newTestTx(wallet, prevOuts, false, values...)
+}
+
+// newReplaceableTx makes the transaction of newSignedTx, which signals replace-by-fee.
+func newReplaceableTx(wallet *Wallet, prevOuts []PrevOut, values ...int) Transaction {
+	return newTestTx(wallet, prevOuts, true, values...)
+}
+
+func newTestTx(wallet *Wallet, prevOuts []PrevOut, replaceable bool, values ...int) Transaction {
+	addr := string(wallet.GetAddress())
+
+	tx := Transaction{}
+	for _, prevOut := range prevOuts {
+		tx.Vins = append(tx.Vins, TxIn{Txid: prevOut.Txid, Vout: prevOut.Vout, Pkey: wallet.Pkey, Replaceable: replaceable})
+	}
+	for _, value := range values {
+		tx.Vouts = append(tx.Vouts, *NewTxOut(value, addr))
+	}
+	tx.ID = tx.Hash()
+
+	tx.Sign(newPrevTxs(prevOuts), wallet.Skey)
+	return tx
+}
+
+func prevOutOf(tx Transaction, vout int) PrevOut {
+	return PrevOut{Outpoint: Outpoint{Txid: tx.ID, Vout: vout}, Out: tx.Vouts[vout]}
+}
+
+func TestMempoolReplaceByFee(t *testing.T) {
+	wallet := NewWallet(Secp256k1)
+	coinbase := *NewCoinbaseTx(string(wallet.GetAddress()), "")
+	funding := prevOutOf(coinbase, 0)
+	m := NewMempool(testChain{funding.String(): funding.Out}, nil)
+
+	parent := newReplaceableTx(wallet, []PrevOut{funding}, Params().Subsidy - 1)
+	replaced, err := m.Add(parent)
+	assert.Nil(t, err)
+	assert.Empty(t, replaced)
+	entry, _ := m.Get(parent.ID)
+	assert.True(t, entry.Replaceable)
+
+	_, err = m.Add(parent)
+	assert.Equal(t, ErrTxInMempool, err)
+
+	// the signal is signed, so that it cannot be set or cleared by a peer.
+	stripped := parent
+	stripped.Vins = []TxIn{parent.Vins[0]}
+	stripped.Vins[0].Replaceable = false
+	stripped.ID = stripped.UnsignedHash()
+	assert.False(t, stripped.Verify(newPrevTxs([]PrevOut{funding})))
+
+	child := newSignedTx(wallet, []PrevOut{prevOutOf(parent, 0)}, Params().Subsidy - 2)
+	_, err = m.Add(child)
+	assert.Nil(t, err)
+
+	// the replacement must pay the parent and the child together, plus the replacement fee.
+	cheap := newSignedTx(wallet, []PrevOut{funding}, Params().Subsidy - 2)
+	_, err = m.Add(cheap)
+	assert.Equal(t, ErrFeeTooLow, err)
+
+	oneMore := newSignedTx(wallet, []PrevOut{funding}, Params().Subsidy - 3)
+	assert.Less(t, len(oneMore.Marshal()), replaceFeeUnit)
+	_, err = m.Add(oneMore)
+	assert.Equal(t, ErrFeeTooLow, err)
+
+	bumped := newSignedTx(wallet, []PrevOut{funding}, Params().Subsidy - 2 - minReplaceFee)
+	replaced, err = m.Add(bumped)
+	assert.Nil(t, err)
+	assert.Len(t, replaced, 2)
+	assert.False(t, m.Has(parent.ID))
+	assert.False(t, m.Has(child.ID))
+	assert.Equal(t, 1, m.Count())
+
+	// the replacement is not replaceable itself.
+	again := newReplaceableTx(wallet, []PrevOut{funding}, Params().Subsidy - 8)
+	_, err = m.Add(again)
+	assert.Equal(t, ErrTxNotReplaceable, err)
+
+	_, err = m.Add(newSignedTx(wallet, []PrevOut{funding}, Params().Subsidy + 1))
+	assert.NotNil(t, err, "Outputs must not exceed inputs")
+
+	forged := Transaction{
+		ID:    []byte("made-up ID"),
+		Vins:  []TxIn{{Txid: funding.Txid, Vout: funding.Vout, Pkey: wallet.Pkey}},
+		Vouts: []TxOut{*NewTxOut(Params().Subsidy - 10, string(wallet.GetAddress()))},
+	}
+	forged.Sign(newPrevTxs([]PrevOut{funding}), wallet.Skey)
+	_, err = m.Add(forged)
+	assert.Equal(t, ErrInvalidTx, err, "The ID must be the hash of the content")
+
+	m.RemoveBlockTxs(&Block{Txs: []*Transaction{&bumped}})
+	assert.Equal(t, 0, m.Count())
+}
+
+func TestMempoolChildPaysForParent(t *testing.T) {
+	wallet := NewWallet(Secp256k1)
+	chain := testChain{}
+	var fundings []PrevOut
+	for i := 0; i < 2; i++ {
+		coinbase := *NewCoinbaseTx(string(wallet.GetAddress()), "")
+		funding := prevOutOf(coinbase, 0)
+		chain[funding.String()] = funding.Out
+		fundings = append(fundings, funding)
+	}
+	m := NewMempool(chain, nil)
+
+	parent := newSignedTx(wallet, fundings[:1], Params().Subsidy)                       // fee 0
+	other := newSignedTx(wallet, fundings[1:], Params().Subsidy - 1)                    // fee 1
+	child := newSignedTx(wallet, []PrevOut{prevOutOf(parent, 0)}, Params().Subsidy - 4) // fee 4
+	for _, tx := range []Transaction{parent, other, child} {
+		_, err := m.Add(tx)
+		assert.Nil(t, err)
+	}
+
+	// the child pulls the parent ahead of the other transaction.
+	txs, fee := m.SelectTxs(1 << 16)
+	assert.Equal(t, 5, fee)
+	if assert.Len(t, txs, 3) {
+		assert.Equal(t, parent.ID, txs[0].ID)
+		assert.Equal(t, child.ID, txs[1].ID)
+		assert.Equal(t, other.ID, txs[2].ID)
+	}
+
+	// only the package of the parent and the child fits.
+	entry, _ := m.Get(parent.ID)
+	childEntry, _ := m.Get(child.ID)
+	txs, fee = m.SelectTxs(entry.Size + childEntry.Size)
+	assert.Equal(t, 4, fee)
+	assert.Len(t, txs, 2)
+
+	// the invalid parent takes its child out together.
+	m.RemoveInvalid(parent.ID)
+	assert.False(t, m.Has(child.ID))
+	assert.Equal(t, 1, m.Count())
+}
+
+func TestMempoolReorg(t *testing.T) {
+	wallet := NewWallet(Secp256k1)
+	chain := testChain{}
+	var fundings []PrevOut
+	for i := 0; i < 3; i++ {
+		coinbase := *NewCoinbaseTx(string(wallet.GetAddress()), "")
+		funding := prevOutOf(coinbase, 0)
+		chain[funding.String()] = funding.Out
+		fundings = append(fundings, funding)
+	}
+	m := NewMempool(chain, nil)
+
+	// mined is confirmed in the old branch, and its child is in the mempool.
+	mined := newReplaceableTx(wallet, fundings[:1], Params().Subsidy - 1)
+	child := newSignedTx(wallet, []PrevOut{prevOutOf(mined, 0)}, Params().Subsidy - 2)
+	conflicting := newSignedTx(wallet, fundings[1:2], Params().Subsidy - 1)
+	orphaned := newSignedTx(wallet, fundings[2:], Params().Subsidy - 1)
+	delete(chain, fundings[0].String())
+	chain[prevOutOf(mined, 0).String()] = mined.Vouts[0]
+	for _, tx := range []Transaction{child, conflicting, orphaned} {
+		_, err := m.Add(tx)
+		assert.Nil(t, err)
+	}
+
+	// the new branch spends the output of conflicting, and drops the output spent by orphaned.
+	spending := newSignedTx(wallet, fundings[1:2], Params().Subsidy - 3)
+	delete(chain, prevOutOf(mined, 0).String())
+	delete(chain, fundings[1].String())
+	delete(chain, fundings[2].String())
+	chain[fundings[0].String()] = fundings[0].Out
+
+	m.Reorg([]*Block{{Txs: []*Transaction{&mined}}}, []*Block{{Txs: []*Transaction{&spending}}})
+	assert.True(t, m.Has(mined.ID), "The transaction of the disconnected block returns")
+	entry, _ := m.Get(mined.ID)
+	assert.True(t, entry.Replaceable, "The returned transaction is still replaceable")
+	assert.True(t, m.Has(child.ID))
+	assert.False(t, m.Has(conflicting.ID), "The transaction conflicting with the connected block is removed")
+	assert.False(t, m.Has(orphaned.ID), "The transaction spending the output not existing any more is removed")
+	assert.Equal(t, 2, m.Count())
+}
+
+func TestMempoolLimit(t *testing.T) {
+	wallet := NewWallet(Secp256k1)
+	chain := testChain{}
+	var fundings []PrevOut
+	for i := 0; i < 4; i++ {
+		coinbase := *NewCoinbaseTx(string(wallet.GetAddress()), "")
+		funding := prevOutOf(coinbase, 0)
+		chain[funding.String()] = funding.Out
+		fundings = append(fundings, funding)
+	}
+	m := NewMempool(chain, nil)
+
+	parent := newSignedTx(wallet, fundings[:1], Params().Subsidy)                       // fee 0
+	child := newSignedTx(wallet, []PrevOut{prevOutOf(parent, 0)}, Params().Subsidy - 4) // fee 4
+	low := newSignedTx(wallet, fundings[1:2], Params().Subsidy - 1)                     // fee 1
+	for _, tx := range []Transaction{parent, child, low} {
+		_, err := m.Add(tx)
+		assert.Nil(t, err)
+	}
+
+	// the limit keeps only the three of the similar sizes.
+	m.maxSize = m.size + m.size / 6
+
+	// the parent is not evicted alone, because its child pays for it.
+	high := newSignedTx(wallet, fundings[2:3], Params().Subsidy - 3) // fee 3
+	_, err := m.Add(high)
+	assert.Nil(t, err)
+	assert.False(t, m.Has(low.ID), "The lowest package fee rate is evicted")
+	assert.True(t, m.Has(parent.ID))
+	assert.True(t, m.Has(child.ID))
+	assert.LessOrEqual(t, m.size, m.maxSize)
+
+	_, err = m.Add(newSignedTx(wallet, fundings[3:], Params().Subsidy - 1))
+	assert.Equal(t, ErrMempoolFull, err, "The new transaction of the lowest fee rate is evicted at once")
+	assert.Equal(t, 3, m.Count())
+}
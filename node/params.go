@@ -0,0 +1,115 @@
+package node
+
+import "fmt"
+
+/**
+  @ Networks
+    The network is selected for the whole process, and decides the rules and the encodings not to be mixed with the others.
+
+                      mainnet        testnet        regtest
+    Magic             0xa7c8e19d     0xa7c8e1b3     0xa7c8e1f6
+    Default Port      3000           13000          23000
+    Target Bits       16             12             1
+    Subsidy           10             10             10
+    Halving Interval  210000         210000         150
+    P-256 Version     0x00 (1)       0x6f (m, n)    0x6f (m, n)
+    secp256k1 Version 0x26 (G)       0x41 (T)       0x41 (T)
+    WIF Version       0x80           0xef           0xef
+    Data Files        <name>_<ID>    testnet_...    regtest_...
+
+    - The genesis block has the coinbase data of the network, and the target bits are committed to every block hash,
+      so that the blocks of a network are invalid on the others.
+    - The messages are prefixed by the magic, and a message of another network is dropped.
+      The magics are of gchain, not of Bitcoin, and start with a byte invalid as the first byte of UTF-8, which is rare in text.
+    - The data files of the networks except mainnet are prefixed by the network name, such as regtest_blockchain_3000.db.
+    - regtest mines the blocks on demand by generate, with the trivial difficulty.
+*/
+
+type ChainParams struct {
+	Name                string
+	Magic               [4]byte // the prefix of every message on the wire
+	DefaultPort         int
+	GenesisCoinbaseData string
+	TargetBits          int // TargetBits gets larger, the target gets smaller, and the difficulty of POW gets higher.
+	Subsidy             int // the block reward before the first halving
+	HalvingInterval     int // the blocks between the halvings of the block reward
+	AddressVersions     map[KeyType]byte
+	WIFVersion          byte
+	MineOnDemand        bool // generate mines the blocks at once
+}
+
+var MainNetParams = ChainParams{
+	Name:                "mainnet",
+	Magic:               [4]byte{0xa7, 0xc8, 0xe1, 0x9d},
+	DefaultPort:         3000,
+	GenesisCoinbaseData: "The Times 03/Jan/2009 Chancellor on brink of second bailout for banks",
+	TargetBits:          16, // 24
+	Subsidy:             10,
+	HalvingInterval:     210000,
+	AddressVersions:     map[KeyType]byte{P256: 0x00, Secp256k1: 0x26},
+	WIFVersion:          0x80,
+}
+
+var TestNetParams = ChainParams{
+	Name:                "testnet",
+	Magic:               [4]byte{0xa7, 0xc8, 0xe1, 0xb3},
+	DefaultPort:         13000,
+	GenesisCoinbaseData: "gchain testnet genesis",
+	TargetBits:          12,
+	Subsidy:             10,
+	HalvingInterval:     210000,
+	AddressVersions:     map[KeyType]byte{P256: 0x6f, Secp256k1: 0x41},
+	WIFVersion:          0xef,
+}
+
+var RegTestParams = ChainParams{
+	Name:                "regtest",
+	Magic:               [4]byte{0xa7, 0xc8, 0xe1, 0xf6},
+	DefaultPort:         23000,
+	GenesisCoinbaseData: "gchain regtest genesis",
+	TargetBits:          1,
+	Subsidy:             10,
+	HalvingInterval:     150,
+	AddressVersions:     map[KeyType]byte{P256: 0x6f, Secp256k1: 0x41},
+	WIFVersion:          0xef,
+	MineOnDemand:        true,
+}
+
+var activeNet = &MainNetParams
+
+// Params returns the parameters of the network selected for the process, mainnet by default.
+func Params() *ChainParams {
+	return activeNet
+}
+
+// SelectNetwork selects the network by the name. It must be called before any data is made or loaded.
+func SelectNetwork(name string) error {
+	for _, params := range []*ChainParams{&MainNetParams, &TestNetParams, &RegTestParams} {
+		if params.Name == name {
+			activeNet = params
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Invalid network: %s", name)
+}
+
+// BlockSubsidy returns the block reward at the height, which halves every HalvingInterval blocks.
+func (p *ChainParams) BlockSubsidy(height int) int {
+	halvings := height / p.HalvingInterval
+	if halvings >= 63 {
+		return 0
+	}
+
+	return p.Subsidy >> uint(halvings)
+}
+
+// DataFile returns the file name of the format for the node, prefixed by the network name except mainnet.
+func (p *ChainParams) DataFile(format, nodeID string) string {
+	name := fmt.Sprintf(format, nodeID)
+	if p == &MainNetParams {
+		return name
+	}
+
+	return p.Name + "_" + name
+}
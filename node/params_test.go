@@ -0,0 +1,53 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockSubsidy(t *testing.T) {
+	params := RegTestParams
+	assert.Equal(t, 10, params.BlockSubsidy(0))
+	assert.Equal(t, 10, params.BlockSubsidy(149))
+	assert.Equal(t, 5, params.BlockSubsidy(150))
+	assert.Equal(t, 2, params.BlockSubsidy(300))
+	assert.Equal(t, 0, params.BlockSubsidy(150 * 100))
+}
+
+func TestSelectNetwork(t *testing.T) {
+	defer SelectNetwork(MainNetParams.Name)
+
+	wallet := NewWallet(Secp256k1)
+	mainAddr := string(wallet.GetAddress())
+	mainWIF, err := EncodeWIF(wallet)
+	assert.Nil(t, err)
+
+	assert.NotNil(t, SelectNetwork("unknown"))
+	assert.Nil(t, SelectNetwork(RegTestParams.Name))
+	assert.Equal(t, "regtest_wallet_3000.dat", Params().DataFile(walletFile, "3000"))
+
+	regAddr := string(wallet.GetAddress())
+	assert.Equal(t, byte('T'), regAddr[0])
+	assert.True(t, ValidateAddress(regAddr))
+	assert.False(t, ValidateAddress(mainAddr), "The address of another network is invalid")
+
+	_, err = DecodeWIF(mainWIF)
+	assert.Equal(t, errInvalidWIF, err)
+
+	block := NewGenesisBlock(NewCoinbaseTx(regAddr, Params().GenesisCoinbaseData))
+	assert.True(t, NewProofOfWork(block).Validate())
+
+	assert.Nil(t, SelectNetwork(MainNetParams.Name))
+	assert.False(t, NewProofOfWork(block).Validate(), "The target bits are committed to the block hash")
+}
+
+func TestMagics(t *testing.T) {
+	bitcoin := [][4]byte{{0xf9, 0xbe, 0xb4, 0xd9}, {0x0b, 0x11, 0x09, 0x07}, {0xfa, 0xbf, 0xb5, 0xda}}
+	magics := make(map[[4]byte]string)
+	for _, params := range []ChainParams{MainNetParams, TestNetParams, RegTestParams} {
+		assert.NotContains(t, bitcoin, params.Magic, "%s reuses the magic of Bitcoin", params.Name)
+		assert.NotContains(t, magics, params.Magic, "%s reuses the magic of %s", params.Name, magics[params.Magic])
+		magics[params.Magic] = params.Name
+	}
+}
@@ -0,0 +1,181 @@
+package node
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+)
+
+/**
+  @ Offline Signing Workflow
+    - createrawtx (online, no private key):  UTXO set -> PartialTx (unsigned) -> file
+    - signrawtx   (offline, no blockchain):  file + wallet -> PartialTx (partially or fully signed) -> file
+    - sendrawtx   (online, no private key):  file -> verified Transaction -> mined or sent to a node
+
+  A PartialTx carries the previous outputs spent by its inputs,
+  because signing requires their public key hashes without the blockchain.
+*/
+
+const (
+	rawTxFilePerm = 0644
+	maxPrevTxOuts = 1 << 16 // the outputs of the previous transactions made by newPrevTxs, in total
+)
+
+type PrevOut struct {
+	Outpoint
+	Out TxOut
+}
+
+type PartialTx struct {
+	Tx       Transaction
+	PrevOuts []PrevOut // previous outputs spent by Tx.Vins in the same order
+}
+
+// Sign signs the inputs spending the outputs locked with the wallets, and returns the number of signed inputs.
+func (p *PartialTx) Sign(wallets *Wallets) int {
+	prevTxs := p.prevTxs()
+	signed := 0
+
+	for idx, in := range p.Tx.Vins {
+		for _, wallet := range wallets.Wallets {
+			if wallet.IsWatchOnly() || !bytes.Equal(wallet.Pkey, in.Pkey) {
+				continue
+			}
+
+			p.Tx.SignInput(idx, prevTxs, wallet.Skey)
+			signed++
+			break
+		}
+	}
+
+	return signed
+}
+
+// IsComplete reports whether all the inputs are signed.
+func (p *PartialTx) IsComplete() bool {
+	for _, in := range p.Tx.Vins {
+		if len(in.Sig) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Verify verifies the signatures with the carried previous outputs. It does not check the outputs are unspent.
+func (p *PartialTx) Verify() bool {
+	return p.IsComplete() && p.Tx.Verify(p.prevTxs())
+}
+
+// Fee returns the sum of the carried previous outputs minus the sum of the outputs.
+func (p *PartialTx) Fee() int {
+	fee := 0
+	for _, prevOut := range p.PrevOuts {
+		fee += prevOut.Out.Value
+	}
+
+	for _, out := range p.Tx.Vouts {
+		fee -= out.Value
+	}
+
+	return fee
+}
+
+// prevTxs makes the previous transactions holding only the carried previous outputs,
+// which are enough for Transaction.Sign and Transaction.Verify.
+func (p *PartialTx) prevTxs() map[string]Transaction {
+	return newPrevTxs(p.PrevOuts)
+}
+
+// newPrevTxs makes the previous transactions holding the previous outputs at their indexes,
+// whose outputs before the indexes are padded with the empty ones. See countPrevTxOuts for the padded outputs.
+func newPrevTxs(prevOuts []PrevOut) map[string]Transaction {
+	prevTxs := make(map[string]Transaction)
+
+	for _, prevOut := range prevOuts {
+		txid := hex.EncodeToString(prevOut.Txid)
+		prevTx := prevTxs[txid]
+		prevTx.ID = prevOut.Txid
+		for len(prevTx.Vouts) <= prevOut.Vout {
+			prevTx.Vouts = append(prevTx.Vouts, TxOut{})
+		}
+		prevTx.Vouts[prevOut.Vout] = prevOut.Out
+		prevTxs[txid] = prevTx
+	}
+
+	return prevTxs
+}
+
+// countPrevTxOuts counts the outputs of the previous transactions made by newPrevTxs, including the padded ones.
+func countPrevTxOuts(prevOuts []PrevOut) int {
+	lens := make(map[string]int)
+	for _, prevOut := range prevOuts {
+		txid := hex.EncodeToString(prevOut.Txid)
+		if prevOut.Vout + 1 > lens[txid] {
+			lens[txid] = prevOut.Vout + 1
+		}
+	}
+
+	count := 0
+	for _, n := range lens {
+		count += n
+	}
+	return count
+}
+
+func (p *PartialTx) Marshal() []byte {
+	var result bytes.Buffer
+
+	encoder := gob.NewEncoder(&result)
+	if err := encoder.Encode(p); err != nil {
+		log.Panic(err)
+	}
+
+	return result.Bytes()
+}
+
+func (p *PartialTx) SaveFile(file string) error {
+	return ioutil.WriteFile(file, p.Marshal(), rawTxFilePerm)
+}
+
+func UnmarshalPartialTx(data []byte) (*PartialTx, error) {
+	var p PartialTx
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&p); err != nil {
+		return nil, err
+	}
+
+	if len(p.PrevOuts) != len(p.Tx.Vins) {
+		return nil, errors.New("Invalid raw transaction: previous outputs do not match inputs")
+	}
+
+	for idx, in := range p.Tx.Vins {
+		if in.Vout < 0 || in.Vout >= maxPrevTxOuts {
+			return nil, errors.New("Invalid raw transaction: output index out of range")
+		}
+
+		if !bytes.Equal(in.Txid, p.PrevOuts[idx].Txid) || in.Vout != p.PrevOuts[idx].Vout {
+			return nil, errors.New("Invalid raw transaction: previous outputs do not match inputs")
+		}
+	}
+
+	// the outputs are padded up to the indexes, so that the total of the indexes bounds the memory, not each one.
+	if countPrevTxOuts(p.PrevOuts) > maxPrevTxOuts {
+		return nil, errors.New("Invalid raw transaction: output indexes out of range in total")
+	}
+
+	return &p, nil
+}
+
+func LoadPartialTx(file string) (*PartialTx, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return UnmarshalPartialTx(data)
+}
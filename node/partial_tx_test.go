@@ -0,0 +1,64 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartialTx(t *testing.T) {
+	ws := &Wallets{Wallets: make(map[string]*Wallet)}
+	addr1 := ws.CreateWallet(Secp256k1)
+	addr2 := ws.CreateWallet(P256)
+	wallet1, wallet2 := ws.Wallets[addr1], ws.Wallets[addr2]
+
+	prevTx1 := NewCoinbaseTx(addr1, "")
+	prevTx2 := NewCoinbaseTx(addr2, "")
+	tx := Transaction{
+		Vins: []TxIn{
+			{Txid: prevTx1.ID, Vout: 0, Pkey: wallet1.Pkey},
+			{Txid: prevTx2.ID, Vout: 0, Pkey: wallet2.Pkey},
+		},
+		Vouts: []TxOut{*NewTxOut(2 * Params().Subsidy, addr1)},
+	}
+	tx.ID = tx.Hash()
+
+	p := &PartialTx{
+		Tx: tx,
+		PrevOuts: []PrevOut{
+			{Outpoint: Outpoint{Txid: prevTx1.ID, Vout: 0}, Out: prevTx1.Vouts[0]},
+			{Outpoint: Outpoint{Txid: prevTx2.ID, Vout: 0}, Out: prevTx2.Vouts[0]},
+		},
+	}
+
+	// the wallet having only one of the keys signs the transaction partially.
+	partial := &Wallets{Wallets: map[string]*Wallet{addr1: wallet1}}
+	assert.Equal(t, 1, p.Sign(partial))
+	assert.False(t, p.IsComplete())
+	assert.False(t, p.Verify())
+
+	decoded, err := UnmarshalPartialTx(p.Marshal())
+	assert.Nil(t, err)
+	assert.Equal(t, 2, decoded.Sign(ws))
+	assert.True(t, decoded.IsComplete())
+	assert.True(t, decoded.Verify())
+	assert.Equal(t, tx.ID, decoded.Tx.ID)
+
+	decoded.PrevOuts = decoded.PrevOuts[:1]
+	_, err = UnmarshalPartialTx(decoded.Marshal())
+	assert.NotNil(t, err, "Previous outputs must match inputs")
+
+	for _, vout := range []int{-2, maxPrevTxOuts} {
+		p.Tx.Vins[0].Vout, p.PrevOuts[0].Vout = vout, vout
+		_, err = UnmarshalPartialTx(p.Marshal())
+		assert.NotNil(t, err, "Output index %d must be out of range", vout)
+	}
+
+	// each index is in range, but the outputs padded for both of them are too many.
+	vout := maxPrevTxOuts / 2
+	p.Tx.Vins[0].Vout, p.PrevOuts[0].Vout = vout, vout
+	p.Tx.Vins[1].Vout, p.PrevOuts[1].Vout = vout, vout
+	assert.Equal(t, 2 * (vout + 1), countPrevTxOuts(p.PrevOuts))
+	_, err = UnmarshalPartialTx(p.Marshal())
+	assert.NotNil(t, err, "Output indexes must be out of range in total")
+}
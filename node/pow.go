@@ -7,68 +7,110 @@ import (
 	"math"
 	"crypto/sha256"
 	"time"
+	"io"
+	"os"
 )
 
-const (
-	targetBits = 16 // 24 // targetBits gets larger, target gets smaller, and the difficulty of POW gets higher.
-	maxNonce   = math.MaxInt64
-)
+// MiningOutput is where the mining progress is printed.
+var MiningOutput io.Writer = os.Stdout
+
+const maxNonce = math.MaxInt64
 
 type ProofOfWork struct {
-	block  *Block
-	target *big.Int
+	block   *Block
+	target  *big.Int
+	txsHash []byte // the merkle root, which is not hashed again for every nonce
 }
 
-func (pow *ProofOfWork) prepareData(nonce int) []byte {
+// Header returns the block header except the nonce, which is hashed with the nonce appended.
+func (pow *ProofOfWork) Header() []byte {
 	return bytes.Join([][]byte{
 		pow.block.PrevHash,
-		pow.block.HashTxs(),
+		pow.txsHash,
 		IntToBytes(pow.block.Timestamp),
-		IntToBytes(int64(targetBits)),
-		IntToBytes(int64(nonce)),
+		IntToBytes(int64(activeNet.TargetBits)),
 	}, []byte{})
 }
 
+// Target returns the copy of the target, which the hash must be less than.
+func (pow *ProofOfWork) Target() *big.Int {
+	return new(big.Int).Set(pow.target)
+}
+
+func (pow *ProofOfWork) prepareData(nonce int) []byte {
+	return append(pow.Header(), IntToBytes(int64(nonce))...)
+}
+
 func (pow *ProofOfWork) Run() (int, []byte) {
+	nonce, hash, _ := pow.RunUntil(nil)
+	return nonce, hash
+}
+
+// RunUntil searches the nonce until it is found or abort is closed. It returns false if it is aborted.
+func (pow *ProofOfWork) RunUntil(abort <-chan struct{}) (int, []byte, bool) {
+	//fmt.Printf("Mining the block containing \"%s\"\n", pow.block.Data)
+	fmt.Fprintln(MiningOutput, "Mining the block...")
+	startTime := time.Now()
+	nonce, hash, ok := FindNonce(pow.Header(), pow.target, abort)
+	if !ok {
+		return nonce, nil, false
+	}
+
+	elapsedTime := time.Since(startTime)
+	fmt.Fprintln(MiningOutput, "Done: nonce:", nonce, ", elapsed time:", elapsedTime)
+	fmt.Fprintln(MiningOutput)
+	return nonce, hash, true
+}
+
+// FindNonce searches the nonce making the hash of the header and the nonce less than the target,
+// so that a miner only with the header could mine the block. It returns false if abort is closed.
+func FindNonce(header []byte, target *big.Int, abort <-chan struct{}) (int, []byte, bool) {
 	var hashInt big.Int
 	var hash [32]byte
 	nonce := 0
 
-	//fmt.Printf("Mining the block containing \"%s\"\n", pow.block.Data)
-	fmt.Println("Mining the block...")
-	startTime := time.Now()
+	defer fmt.Fprintln(MiningOutput)
 	for nonce < maxNonce {
-		data := pow.prepareData(nonce)
+		// the abort is checked every 4096 nonces, not to slow down the search.
+		if abort != nil && nonce % 4096 == 0 {
+			select {
+			case <-abort:
+				return nonce, nil, false
+			default:
+			}
+		}
+
+		data := append(header[:len(header):len(header)], IntToBytes(int64(nonce))...)
 		hash = sha256.Sum256(data)
-		fmt.Printf("\r%x", hash)
+		fmt.Fprintf(MiningOutput, "\r%x", hash)
 		hashInt.SetBytes(hash[:])
-		if hashInt.Cmp(pow.target) == -1 {
+		if hashInt.Cmp(target) == -1 {
 			break
 		}
 		nonce++
 	}
 
-	elapsedTime := time.Since(startTime)
-	fmt.Println()
-	fmt.Println("Done: nonce:", nonce, ", elapsed time:", elapsedTime)
-	fmt.Println()
-	return nonce, hash[:]
+	return nonce, hash[:], true
+}
+
+func (pow *ProofOfWork) Hash() []byte {
+	hash := sha256.Sum256(pow.prepareData(pow.block.Nonce))
+	return hash[:]
 }
 
 func (pow *ProofOfWork) Validate() bool {
 	var hashInt big.Int
 
-	data := pow.prepareData(pow.block.Nonce)
-	hash := sha256.Sum256(data)
-	hashInt.SetBytes(hash[:])
+	hashInt.SetBytes(pow.Hash())
 	return hashInt.Cmp(pow.target) == -1
 }
 
 func NewProofOfWork(b *Block) *ProofOfWork {
 	target := big.NewInt(1)
-	target.Lsh(target, uint(256 - targetBits))
+	target.Lsh(target, uint(256 - activeNet.TargetBits))
 	return &ProofOfWork{
-		block:  b,
-		target: target,
-	} 
+		block:   b,
+		target:  target,
+		txsHash: b.HashTxs(),
+	}
 }
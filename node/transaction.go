@@ -3,7 +3,6 @@ package node
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/gob"
@@ -38,19 +37,48 @@ import (
     -------------
 */
 
-const subsidy = 10
-
 type Transaction struct {
 	ID    []byte  // transaction ID
 	Vins  []TxIn  // transaction input list
 	Vouts []TxOut // transaction output list
 }
 
+// The encoded transaction includes the gob type IDs, which are assigned in the order the types are first encoded in the process.
+// So the transaction is encoded first of all, with and without the replace-by-fee signal,
+// for the transaction ID and the block hash to be the same in every process, such as a node which has encoded its messages before.
+func init() {
+	Transaction{}.Marshal()
+	Transaction{Vins: []TxIn{{Replaceable: true}}}.Marshal()
+}
+
 func (tx Transaction) Marshal() []byte {
+	// the transaction without the replace-by-fee signal is encoded in the types before the signal, whose gob types have the same names,
+	// so that the IDs of the transactions and the hashes of the blocks made before the signal are kept.
+	type TxIn struct {
+		Txid []byte
+		Vout int
+		Sig  []byte
+		Pkey []byte
+	}
+	type Transaction struct {
+		ID    []byte
+		Vins  []TxIn
+		Vouts []TxOut
+	}
+
+	var v interface{} = tx
+	if !tx.IsReplaceable() {
+		legacy := Transaction{ID: tx.ID, Vouts: tx.Vouts}
+		for _, in := range tx.Vins {
+			legacy.Vins = append(legacy.Vins, TxIn{Txid: in.Txid, Vout: in.Vout, Sig: in.Sig, Pkey: in.Pkey})
+		}
+		v = legacy
+	}
+
 	var result bytes.Buffer
 
 	encoder := gob.NewEncoder(&result)
-	if err := encoder.Encode(tx); err != nil {
+	if err := encoder.Encode(v); err != nil {
 		log.Panic(err)
 	}
 
@@ -64,6 +92,28 @@ func (tx *Transaction) Hash() []byte {
 	return hash[:]
 }
 
+// UnsignedHash returns the hash of the transaction without the signatures, which is the transaction ID.
+func (tx *Transaction) UnsignedHash() []byte {
+	copiedTx := *tx
+	copiedTx.Vins = make([]TxIn, len(tx.Vins))
+	for i, in := range tx.Vins {
+		in.Sig = nil
+		copiedTx.Vins[i] = in
+	}
+
+	return copiedTx.Hash()
+}
+
+// IsReplaceable reports whether any input signals replace-by-fee. The signal is signed, so that no peer could change it.
+func (tx Transaction) IsReplaceable() bool {
+	for _, in := range tx.Vins {
+		if in.Replaceable {
+			return true
+		}
+	}
+	return false
+}
+
 func (tx Transaction) IsCoinbase() bool {
 	return len(tx.Vins) == 1 && len(tx.Vins[0].Txid) == 0 && tx.Vins[0].Vout == -1
 }
@@ -74,10 +124,11 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 
 	for _, in := range tx.Vins {
 		ins = append(ins, TxIn{
-			Txid: in.Txid,
-			Vout: in.Vout,
-			Sig:  nil,
-			Pkey: nil,
+			Txid:        in.Txid,
+			Vout:        in.Vout,
+			Sig:         nil,
+			Pkey:        nil,
+			Replaceable: in.Replaceable,
 		})
 	}
 
@@ -96,6 +147,13 @@ func (tx *Transaction) TrimmedCopy() Transaction {
 }
 
 func (tx *Transaction) Sign(prevTxs map[string]Transaction, skey ecdsa.PrivateKey) {
+	for idx := range tx.Vins {
+		tx.SignInput(idx, prevTxs, skey)
+	}
+}
+
+// SignInput signs only the input at idx, so that inputs owned by different keys are signed with each key.
+func (tx *Transaction) SignInput(idx int, prevTxs map[string]Transaction, skey ecdsa.PrivateKey) {
 	if tx.IsCoinbase() {
 		return
 	}
@@ -107,23 +165,22 @@ func (tx *Transaction) Sign(prevTxs map[string]Transaction, skey ecdsa.PrivateKe
 	}
 
 	copiedTx := tx.TrimmedCopy()
-	for idx, in := range copiedTx.Vins {
-		prevTx := prevTxs[hex.EncodeToString(in.Txid)]
-		copiedTx.Vins[idx].Sig = nil
-		copiedTx.Vins[idx].Pkey = prevTx.Vouts[in.Vout].PkeyHash
-
-		data := fmt.Sprintf("%x\n", copiedTx)
+	in := copiedTx.Vins[idx]
+	prevTx := prevTxs[hex.EncodeToString(in.Txid)]
+	copiedTx.Vins[idx].Sig = nil
+	copiedTx.Vins[idx].Pkey = prevTx.Vouts[in.Vout].PkeyHash
 
-		// TODO: check that the sign data which is not a hash would be a problem.
-		r, s, err := ecdsa.Sign(rand.Reader, &skey, []byte(data))
-		if err != nil {
-			log.Panic(err)
-		}
-		sig := append(r.Bytes(), s.Bytes()...)
+	_, legacy, err := ParsePkey(tx.Vins[idx].Pkey)
+	if err != nil {
+		log.Panic(err)
+	}
 
-		tx.Vins[idx].Sig = sig
-		copiedTx.Vins[idx].Pkey = nil
+	r, s, err := ecdsa.Sign(rand.Reader, &skey, signData(copiedTx, legacy))
+	if err != nil {
+		log.Panic(err)
 	}
+
+	tx.Vins[idx].Sig = marshalSig(skey.Curve, r, s)
 }
 
 func (tx *Transaction) Verify(prevTxs map[string]Transaction) bool {
@@ -138,33 +195,22 @@ func (tx *Transaction) Verify(prevTxs map[string]Transaction) bool {
 	}
 
 	copiedTx := tx.TrimmedCopy()
-	curve := elliptic.P256()
 
 	for idx, in := range tx.Vins {
 		prevTx := prevTxs[hex.EncodeToString(in.Txid)]
+		if in.Vout < 0 || in.Vout >= len(prevTx.Vouts) || !in.UnlockableWith(prevTx.Vouts[in.Vout].PkeyHash) {
+			return false
+		}
+
 		copiedTx.Vins[idx].Sig = nil
 		copiedTx.Vins[idx].Pkey = prevTx.Vouts[in.Vout].PkeyHash
 
-		r := big.Int{}
-		s := big.Int{}
-		sigHalfLen := len(in.Sig) / 2
-		r.SetBytes(in.Sig[:sigHalfLen])
-		s.SetBytes(in.Sig[sigHalfLen:])
-
-		x := big.Int{}
-		y := big.Int{}
-		pkeyHalfLen := len(in.Pkey) / 2
-		x.SetBytes(in.Pkey[:pkeyHalfLen])
-		y.SetBytes(in.Pkey[pkeyHalfLen:])
-
-		data := fmt.Sprintf("%x\n", copiedTx)
-		pkey := ecdsa.PublicKey{
-			Curve: curve,
-			X:     &x,
-			Y:     &y,
+		pkey, legacy, err := ParsePkey(in.Pkey)
+		if err != nil {
+			return false
 		}
 
-		if !ecdsa.Verify(&pkey, []byte(data), &r, &s) {
+		if !verifySig(pkey, signData(copiedTx, legacy), in.Sig, legacy) {
 			return false
 		}
 
@@ -174,6 +220,48 @@ func (tx *Transaction) Verify(prevTxs map[string]Transaction) bool {
 	return true
 }
 
+// signData returns the data to sign for the trimmed copy of the transaction.
+// The data is hashed for typed public keys. The data is not hashed for legacy public keys
+// to verify the transactions signed before the key type was introduced.
+func signData(copiedTx Transaction, legacy bool) []byte {
+	data := []byte(fmt.Sprintf("%x\n", copiedTx))
+	if legacy {
+		return data
+	}
+
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// verifySig verifies the signature of r and s in the fixed width of the curve.
+// The legacy signature could have r and s without leading zeros, so every split point is tried.
+func verifySig(pkey *ecdsa.PublicKey, data, sig []byte, legacy bool) bool {
+	size := (pkey.Curve.Params().BitSize + 7) / 8
+	if len(sig) == 2 * size {
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(pkey, data, r, s)
+	}
+
+	if !legacy || len(sig) > 2 * size {
+		return false
+	}
+
+	for split := len(sig) - size; split <= size; split++ {
+		if split < 1 {
+			continue
+		}
+
+		r := new(big.Int).SetBytes(sig[:split])
+		s := new(big.Int).SetBytes(sig[split:])
+		if ecdsa.Verify(pkey, data, r, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (tx Transaction) String() string {
 	var lines []string
 
@@ -185,6 +273,10 @@ func (tx Transaction) String() string {
 		lines = append(lines, fmt.Sprintf("       out: %d", in.Vout))
 		lines = append(lines, fmt.Sprintf("       sig: %x", in.Sig))
 		lines = append(lines, fmt.Sprintf("       pkey: %x", in.Pkey))
+		// only the replaceable input has the line, so that the transactions signed before the flag are verified as they are.
+		if in.Replaceable {
+			lines = append(lines, "       replaceable: true")
+		}
 	}
 
 	for i, out := range tx.Vouts {
@@ -196,56 +288,27 @@ func (tx Transaction) String() string {
 	return strings.Join(lines, "\n")
 }
 
-func NewTransaction(wallet *Wallet, to string, amount int, utxoSet *UTXOSet) *Transaction {
-	var inputs []TxIn
-	var outputs []TxOut
-
-	sum, utxos := utxoSet.FindSpendableOuts(HashPkey(wallet.Pkey), amount)
-	if sum < amount {
-		log.Panic("Balance not enough")
-	}
-
-	for txid, outs := range utxos {
-		txidBytes, err := hex.DecodeString(txid)
-		if err != nil {
-			log.Panic(err)
-		}
-
-		// make a input list.
-		// make inputs to spend UTXOs.
-		for _, out := range outs {
-			inputs = append(inputs, TxIn{
-				Txid: txidBytes,
-				Vout: out,
-				Sig:  nil,
-				Pkey: wallet.Pkey,
-			})
-		}
-	}
-
-	// make a output list.
-	// make a output to give coins.
-	outputs = append(outputs, *NewTxOut(amount, to))
-
-	// make a output to get the change back, because a output is indivisible.
-	if sum > amount {
-		from := string(wallet.GetAddress())
-		outputs = append(outputs, *NewTxOut(sum - amount, from))
-	}
-
-	// make a transaction.
-	tx := Transaction{
-		ID:    nil,
-		Vins:  inputs,
-		Vouts: outputs,
+func NewTransaction(wallet *Wallet, to string, amount int, change string, coinControl *CoinControl, utxoSet *UTXOSet) *Transaction {
+	tx, err := NewTxBuilder(utxoSet).
+		AddSource(wallet).
+		AddRecipient(to, amount).
+		SetChange(change).
+		SetCoinControl(coinControl).
+		Build()
+	if err != nil {
+		log.Panic(err)
 	}
 
-	tx.ID = tx.Hash()
-	utxoSet.BC.SignTx(&tx, wallet.Skey)
-	return &tx
+	return tx
 }
 
 func NewCoinbaseTx(to, data string) *Transaction {
+	return NewCoinbaseTxWithFee(to, data, 0, 0)
+}
+
+// NewCoinbaseTxWithFee makes the coinbase transaction of the block at the height,
+// rewarding the subsidy and the fees of the mined transactions.
+func NewCoinbaseTxWithFee(to, data string, height, fee int) *Transaction {
 	if data == "" {
 		randData := make([]byte, 20)
 		if _, err := rand.Read(randData); err != nil {
@@ -262,8 +325,8 @@ func NewCoinbaseTx(to, data string) *Transaction {
 		Pkey: []byte(data),
 	}
 
-	out := *NewTxOut(subsidy, to)
-	
+	out := *NewTxOut(activeNet.BlockSubsidy(height) + fee, to)
+
 	tx := Transaction{
 		ID:    nil,
 		Vins:  []TxIn{in},
@@ -275,12 +338,22 @@ func NewCoinbaseTx(to, data string) *Transaction {
 }
 
 func UnmarshalTx(data []byte) Transaction {
+	tx, err := DecodeTx(data)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	return tx
+}
+
+// DecodeTx decodes the transaction from untrusted data, such as RPC params.
+func DecodeTx(data []byte) (Transaction, error) {
 	var tx Transaction
 
 	decoder := gob.NewDecoder(bytes.NewReader(data))
 	if err := decoder.Decode(&tx); err != nil {
-		log.Panic(err)
+		return Transaction{}, err
 	}
 
-	return tx
+	return tx, nil
 }
@@ -0,0 +1,30 @@
+package node
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashIndependentOfEncodedTypes(t *testing.T) {
+	// another type encoded before the transaction must not change the gob type IDs of the transaction.
+	type message struct {
+		From  string
+		Items [][]byte
+	}
+	assert.Nil(t, gob.NewEncoder(&bytes.Buffer{}).Encode(message{"localhost:3000", [][]byte{{1}}}))
+
+	tx := Transaction{
+		Vins:  []TxIn{{Txid: []byte{1}, Vout: 0, Pkey: []byte{2}}},
+		Vouts: []TxOut{{Value: 1, PkeyHash: []byte{3}}},
+	}
+	assert.Equal(t, "7ec202f91d84ce69399ecaede8793b889fe368298f99d4ac5c0e1cdf214f4e6c", hex.EncodeToString(tx.Hash()))
+
+	// the replace-by-fee signal changes the hash, and is kept by the encoding.
+	tx.Vins[0].Replaceable = true
+	assert.Equal(t, "1864dbf55604b8a9d2f985f3ef95e462f196215320ffb22a25ecdbacf688021c", hex.EncodeToString(tx.Hash()))
+	assert.True(t, UnmarshalTx(tx.Marshal()).IsReplaceable())
+}
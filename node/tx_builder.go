@@ -0,0 +1,201 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Recipient struct {
+	Addr   string
+	Amount int
+}
+
+// TxBuilder builds a transaction paying many recipients from many source wallets.
+// Each input is signed with the key of the source wallet owning the spent output.
+type TxBuilder struct {
+	utxoSet     *UTXOSet
+	sources     []*Wallet
+	recipients  []Recipient
+	change      string
+	fee         int
+	coinControl *CoinControl
+	replaceable bool
+}
+
+func (b *TxBuilder) AddSource(wallet *Wallet) *TxBuilder {
+	b.sources = append(b.sources, wallet)
+	return b
+}
+
+func (b *TxBuilder) AddRecipient(addr string, amount int) *TxBuilder {
+	b.recipients = append(b.recipients, Recipient{Addr: addr, Amount: amount})
+	return b
+}
+
+// SetChange sets the address to get the change back. The change goes back to the first source, if it is not set.
+func (b *TxBuilder) SetChange(addr string) *TxBuilder {
+	b.change = addr
+	return b
+}
+
+// SetFee sets the fee left to the miner, which is the sum of the inputs minus the sum of the outputs.
+func (b *TxBuilder) SetFee(fee int) *TxBuilder {
+	b.fee = fee
+	return b
+}
+
+// SetReplaceable signals replace-by-fee in the inputs, so that a conflicting transaction paying higher fee could replace it in the mempool.
+func (b *TxBuilder) SetReplaceable(replaceable bool) *TxBuilder {
+	b.replaceable = replaceable
+	return b
+}
+
+func (b *TxBuilder) SetCoinControl(coinControl *CoinControl) *TxBuilder {
+	b.coinControl = coinControl
+	return b
+}
+
+// Build builds the transaction signed with the keys of the sources.
+func (b *TxBuilder) Build() (*Transaction, error) {
+	p, owners, err := b.build(true)
+	if err != nil {
+		return nil, err
+	}
+
+	prevTxs := p.prevTxs()
+	for idx := range p.Tx.Vins {
+		p.Tx.SignInput(idx, prevTxs, owners[idx].Skey)
+	}
+
+	return &p.Tx, nil
+}
+
+// BuildUnsigned builds the transaction without signing, so that the sources need only their public keys.
+func (b *TxBuilder) BuildUnsigned() (*PartialTx, error) {
+	p, _, err := b.build(false)
+	return p, err
+}
+
+// build builds the unsigned transaction, and returns the source owning each input.
+func (b *TxBuilder) build(sign bool) (*PartialTx, []*Wallet, error) {
+	if len(b.sources) == 0 {
+		return nil, nil, errors.New("No source address")
+	}
+
+	if len(b.recipients) == 0 {
+		return nil, nil, errors.New("No recipient")
+	}
+
+	amount := 0
+	for _, r := range b.recipients {
+		if !ValidateAddress(r.Addr) {
+			return nil, nil, fmt.Errorf("Invalid address: %s", r.Addr)
+		}
+
+		if r.Amount <= 0 {
+			return nil, nil, fmt.Errorf("Invalid amount: %d to %s", r.Amount, r.Addr)
+		}
+
+		amount += r.Amount
+	}
+
+	if b.fee < 0 {
+		return nil, nil, fmt.Errorf("Invalid fee: %d", b.fee)
+	}
+	amount += b.fee
+
+	if b.change != "" && !ValidateAddress(b.change) {
+		return nil, nil, fmt.Errorf("Invalid address: %s", b.change)
+	}
+
+	// list the outputs of all the sources, and remember the source owning each output.
+	var outs []SpendableOut
+	owners := make(map[string]*Wallet)
+	sources := make(map[string]bool)
+	for _, wallet := range b.sources {
+		if sign && wallet.IsWatchOnly() {
+			return nil, nil, fmt.Errorf("Address is watch-only: %s", wallet.GetAddress())
+		}
+
+		if wallet.Pkey == nil {
+			return nil, nil, fmt.Errorf("Public key unknown: %s", wallet.GetAddress())
+		}
+
+		if addr := string(wallet.GetAddress()); sources[addr] {
+			continue
+		} else {
+			sources[addr] = true
+		}
+
+		for _, out := range b.utxoSet.ListSpendableOuts(HashPkey(wallet.Pkey)) {
+			outs = append(outs, out)
+			owners[out.String()] = wallet
+		}
+	}
+
+	selected, err := SelectOuts(outs, amount, b.coinControl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sum := 0
+	for _, out := range selected {
+		sum += out.Value
+	}
+
+	if sum < amount {
+		return nil, nil, errors.New("Balance not enough")
+	}
+
+	// make a input list.
+	// make inputs to spend UTXOs.
+	var inputs []TxIn
+	var prevOuts []PrevOut
+	var inputOwners []*Wallet
+	for _, out := range selected {
+		owner := owners[out.String()]
+		inputs = append(inputs, TxIn{
+			Txid:        out.Txid,
+			Vout:        out.Vout,
+			Sig:         nil,
+			Pkey:        owner.Pkey,
+			Replaceable: b.replaceable,
+		})
+
+		prevOuts = append(prevOuts, PrevOut{
+			Outpoint: out.Outpoint,
+			Out:      TxOut{Value: out.Value, PkeyHash: HashPkey(owner.Pkey)},
+		})
+		inputOwners = append(inputOwners, owner)
+	}
+
+	// make a output list.
+	// make outputs to give coins.
+	var outputs []TxOut
+	for _, r := range b.recipients {
+		outputs = append(outputs, *NewTxOut(r.Amount, r.Addr))
+	}
+
+	// make a output to get the change back, because a output is indivisible.
+	if sum > amount {
+		change := b.change
+		if change == "" {
+			change = string(b.sources[0].GetAddress())
+		}
+		outputs = append(outputs, *NewTxOut(sum - amount, change))
+	}
+
+	// make a transaction.
+	tx := Transaction{
+		ID:    nil,
+		Vins:  inputs,
+		Vouts: outputs,
+	}
+
+	tx.ID = tx.Hash()
+	return &PartialTx{Tx: tx, PrevOuts: prevOuts}, inputOwners, nil
+}
+
+func NewTxBuilder(utxoSet *UTXOSet) *TxBuilder {
+	return &TxBuilder{utxoSet: utxoSet}
+}
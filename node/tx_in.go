@@ -7,6 +7,8 @@ type TxIn struct {
 	Vout int    // previous transaction output index connected with input
 	Sig  []byte // signature of transaction trimmed copy signed with transaction creator's private key
 	Pkey []byte // previous transaction output owner connected with input. transaction creator's public key
+
+	Replaceable bool // opt-in replace-by-fee of the transaction, which is signed with input
 }
 
 func (in *TxIn) UnlockableWith(pkeyHash []byte) bool {
@@ -33,7 +33,17 @@ func NewTxOut(value int, addr string) *TxOut {
 }
 
 type TxOuts struct {
-	Outs []TxOut
+	Outs  []TxOut
+	Vouts []int // output indexes of Outs in the transaction, because spent outputs are removed from Outs
+}
+
+// Vout returns the output index in the transaction of Outs[idx].
+func (outs TxOuts) Vout(idx int) int {
+	if idx < len(outs.Vouts) {
+		return outs.Vouts[idx]
+	}
+
+	return idx
 }
 
 func (outs TxOuts) Marshal() []byte {
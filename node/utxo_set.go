@@ -3,6 +3,7 @@ package node
 import (
 	"log"
 	"encoding/hex"
+	"crypto/sha256"
 
 	"github.com/boltdb/bolt"
 )
@@ -37,20 +38,39 @@ func (u UTXOSet) FindUTXOs(pkeyHash []byte) []TxOut {
 	return utxos
 }
 
-func (u UTXOSet) FindSpendableOuts(pkeyHash []byte, amount int) (int, map[string][]int) {
+func (u UTXOSet) FindSpendableOuts(pkeyHash []byte, amount int, coinControl *CoinControl) (int, map[string][]int) {
 	sum := 0
 	utxos := make(map[string][]int)
 
+	selected, err := SelectOuts(u.ListSpendableOuts(pkeyHash), amount, coinControl)
+	if err != nil {
+		log.Panic(err)
+	}
+
+	for _, out := range selected {
+		txid := hex.EncodeToString(out.Txid)
+		utxos[txid] = append(utxos[txid], out.Vout)
+		sum += out.Value
+	}
+
+	return sum, utxos
+}
+
+// ListSpendableOuts lists all the outputs locked with the public key hash.
+func (u UTXOSet) ListSpendableOuts(pkeyHash []byte) []SpendableOut {
+	var outs []SpendableOut
+
 	if err := u.BC.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(utxoBucket))
 		c := b.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
-			txid := hex.EncodeToString(k)
-			outs := UnmarshalOuts(v)
-			for idx, out := range outs.Outs {
-				if out.LockedWith(pkeyHash) && sum < amount {
-					sum += out.Value
-					utxos[txid] = append(utxos[txid], idx)
+			txOuts := UnmarshalOuts(v)
+			for idx, out := range txOuts.Outs {
+				if out.LockedWith(pkeyHash) {
+					outs = append(outs, SpendableOut{
+						Outpoint: Outpoint{Txid: append([]byte{}, k...), Vout: txOuts.Vout(idx)},
+						Value:    out.Value,
+					})
 				}
 			}
 		}
@@ -60,8 +80,38 @@ func (u UTXOSet) FindSpendableOuts(pkeyHash []byte, amount int) (int, map[string
 	}); err != nil {
 		log.Panic(err)
 	}
-	
-	return sum, utxos
+
+	return outs
+}
+
+// FindOut finds the unspent output of the outpoint.
+func (u UTXOSet) FindOut(op Outpoint) (TxOut, bool) {
+	var out TxOut
+	found := false
+
+	if err := u.BC.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		v := b.Get(op.Txid)
+		if v == nil {
+			return nil
+		}
+
+		txOuts := UnmarshalOuts(v)
+		for idx := range txOuts.Outs {
+			if txOuts.Vout(idx) == op.Vout {
+				out = txOuts.Outs[idx]
+				found = true
+				break
+			}
+		}
+
+		return nil
+
+	}); err != nil {
+		log.Panic(err)
+	}
+
+	return out, found
 }
 
 func (u UTXOSet) CountTxs() int {
@@ -83,6 +133,33 @@ func (u UTXOSet) CountTxs() int {
 	return count
 }
 
+// Hash returns the hash of all the unspent outputs in the order of the outpoints, so that the UTXO sets of the nodes
+// are compared without listing them. It hashes the fields of the outputs instead of their encoding, which could differ by the process.
+func (u UTXOSet) Hash() []byte {
+	hasher := sha256.New()
+
+	if err := u.BC.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(utxoBucket))
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			txOuts := UnmarshalOuts(v)
+			for idx, out := range txOuts.Outs {
+				hasher.Write(k)
+				hasher.Write(IntToBytes(int64(txOuts.Vout(idx))))
+				hasher.Write(IntToBytes(int64(out.Value)))
+				hasher.Write(out.PkeyHash)
+			}
+		}
+
+		return nil
+
+	}); err != nil {
+		log.Panic(err)
+	}
+
+	return hasher.Sum(nil)
+}
+
 func (u UTXOSet) Reindex() {
 	bucketName := []byte(utxoBucket)
 
@@ -134,8 +211,9 @@ func (u UTXOSet) Update(block *Block) {
 					newOuts := TxOuts{}
 					oldOuts := UnmarshalOuts(b.Get(in.Txid))
 					for idx, out := range oldOuts.Outs {
-						if idx != in.Vout {
+						if vout := oldOuts.Vout(idx); vout != in.Vout {
 							newOuts.Outs = append(newOuts.Outs, out)
+							newOuts.Vouts = append(newOuts.Vouts, vout)
 						}
 					}
 
@@ -153,8 +231,9 @@ func (u UTXOSet) Update(block *Block) {
 
 			// update new UTXOs of current transaction
 			newOuts := TxOuts{}
-			for _, out := range tx.Vouts {
+			for idx, out := range tx.Vouts {
 				newOuts.Outs = append(newOuts.Outs, out)
+				newOuts.Vouts = append(newOuts.Vouts, idx)
 			}
 
 			if err := b.Put(tx.ID, newOuts.Marshal()); err != nil {
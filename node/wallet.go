@@ -2,9 +2,9 @@ package node
 
 import (
 	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
 	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
 	"log"
 	"bytes"
 
@@ -35,44 +35,127 @@ import (
 
     - base58-encoded address
       1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa
+
+  @ Address Version
+    - Version is decided by the key type, so that addresses of different curves coexist. (See key.go)
 */
 
 const (
-	addressVersion     = byte(0x00)
 	addressVersionLen  = 1
+	addressPkeyHashLen = 20
 	addressChecksumLen = 4
 )
 
 type Wallet struct {
 	Skey ecdsa.PrivateKey // Private key is a random value.
-	Pkey []byte // Public key is (x, y) on the elliptic curve. `pkey` combines x with y as a byte array.
+	Pkey []byte // Public key is (x, y) on the elliptic curve. `pkey` is the key type followed by the SEC1 encoding of (x, y).
+	Path string // HD derivation path. It is empty if the key is not derived from the wallet seed.
+	Type KeyType // Key type decides the curve and the address version.
+	PkeyHash []byte // Public key hash of the watch-only address imported without the public key.
+}
+
+// walletGob is the gob representation of Wallet, because the curve of ecdsa.PrivateKey has no exported fields to encode.
+type walletGob struct {
+	Skey     []byte
+	Pkey     []byte
+	Path     string
+	Type     KeyType
+	PkeyHash []byte
+}
+
+// IsWatchOnly reports whether the wallet has no private key, so that it can only watch the address.
+func (w Wallet) IsWatchOnly() bool {
+	return w.Skey.D == nil
+}
+
+func (w Wallet) GobEncode() ([]byte, error) {
+	var result bytes.Buffer
+
+	encoder := gob.NewEncoder(&result)
+	wg := walletGob{
+		Pkey:     w.Pkey,
+		Path:     w.Path,
+		Type:     w.Type,
+		PkeyHash: w.PkeyHash,
+	}
+
+	if !w.IsWatchOnly() {
+		wg.Skey = w.Skey.D.Bytes()
+	}
+
+	if err := encoder.Encode(wg); err != nil {
+		return nil, err
+	}
+
+	return result.Bytes(), nil
+}
+
+func (w *Wallet) GobDecode(data []byte) error {
+	var wg walletGob
+
+	decoder := gob.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(&wg); err != nil {
+		return err
+	}
+
+	if len(wg.Skey) > 0 {
+		w.Skey = PrivateKeyFromBytes(wg.Type, wg.Skey)
+	}
+	w.Pkey = wg.Pkey
+	w.Path = wg.Path
+	w.Type = wg.Type
+	w.PkeyHash = wg.PkeyHash
+	return nil
+}
+
+func (w Wallet) GetPkeyHash() []byte {
+	if w.Pkey == nil {
+		return w.PkeyHash
+	}
+
+	return HashPkey(w.Pkey)
 }
 
 func (w Wallet) GetAddress() []byte {
-	pkeyHash := HashPkey(w.Pkey)
-	versionedPkeyHash := append([]byte{addressVersion}, pkeyHash...)
+	pkeyHash := w.GetPkeyHash()
+	versionedPkeyHash := append([]byte{w.Type.AddressVersion()}, pkeyHash...)
 	checksum := newChecksum(versionedPkeyHash)
 	payload := append(versionedPkeyHash, checksum...)
 	return base58.Encode(payload)
 }
 
-func NewWallet() *Wallet {
-	skey, pkey := newKeyPair()
-	return &Wallet{
-		Skey: skey,
-		Pkey: pkey,
+// NewWatchOnlyWallet makes the wallet watching the address without any key.
+func NewWatchOnlyWallet(addr string) (*Wallet, error) {
+	if !ValidateAddress(addr) {
+		return nil, fmt.Errorf("Invalid address: %s", addr)
 	}
+
+	payload := base58.Decode([]byte(addr))
+	kt, _ := keyTypeFromAddressVersion(payload[0])
+	return &Wallet{
+		Type:     kt,
+		PkeyHash: GetPkeyHashFromAddress([]byte(addr)),
+	}, nil
 }
 
-func newKeyPair() (ecdsa.PrivateKey, []byte) {
-	curve := elliptic.P256()
-	skey, err := ecdsa.GenerateKey(curve, rand.Reader)
-	if err != nil {
-		log.Panic(err)
+// NewPkeyWallet makes the wallet watching the address of the public key without the private key.
+func NewPkeyWallet(pkey []byte) (*Wallet, error) {
+	if _, legacy, err := ParsePkey(pkey); err != nil {
+		return nil, err
+	} else if legacy {
+		return &Wallet{Pkey: pkey, Type: P256}, nil
 	}
 
-	pkey := append(skey.PublicKey.X.Bytes(), skey.PublicKey.Y.Bytes()...)
-	return *skey, pkey
+	return &Wallet{Pkey: pkey, Type: KeyType(pkey[0])}, nil
+}
+
+func NewWallet(kt KeyType) *Wallet {
+	skey := GenerateKey(kt)
+	return &Wallet{
+		Skey: skey,
+		Pkey: MarshalPkey(kt, &skey.PublicKey, true),
+		Type: kt,
+	}
 }
 
 func HashPkey(pkey []byte) []byte {
@@ -94,7 +177,19 @@ func newChecksum(data []byte) []byte {
 }
 
 func ValidateAddress(addr string) bool {
+	if addr == "" {
+		return false
+	}
+
 	payload := base58.Decode([]byte(addr))
+	if len(payload) != addressVersionLen + addressPkeyHashLen + addressChecksumLen {
+		return false
+	}
+
+	if _, exist := keyTypeFromAddressVersion(payload[0]); !exist {
+		return false
+	}
+
 	actualChecksum := payload[len(payload) - addressChecksumLen:]
 	version := payload[0]
 	pkeyHash := payload[1:len(payload) - addressChecksumLen]
@@ -0,0 +1,172 @@
+package node
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+/**
+  @ How to Encrypt Wallet File
+
+       Passphrase + Salt (random 16 bytes)
+                      V
+          scrypt( N = 2^15, r = 8, p = 1 )
+                      V
+                 Key (32 bytes)
+                      V
+    AES-256-GCM( Key, Nonce (random 12 bytes), gob( Wallets ) )
+                      V
+    Magic + gob( Salt, N, r, p, Nonce, Ciphertext ) = Wallet File
+*/
+
+const (
+	encryptedWalletMagic = "gchain-wallet-enc"
+	scryptN              = 1 << 15
+	scryptR              = 8
+	scryptP              = 1
+	walletKeyLen         = 32
+	walletSaltLen        = 16
+	walletFilePerm       = 0600
+)
+
+var (
+	ErrWalletLocked       = errors.New("Wallet is locked")
+	ErrWalletNotEncrypted = errors.New("Wallet is not encrypted")
+	ErrInvalidPassphrase  = errors.New("Invalid passphrase")
+)
+
+type encryptedWallet struct {
+	Salt  []byte
+	N     int
+	R     int
+	P     int
+	Nonce []byte
+	Data  []byte
+}
+
+func (ew *encryptedWallet) deriveKey(passphrase string) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), ew.Salt, ew.N, ew.R, ew.P, walletKeyLen)
+}
+
+func newEncryptedWallet(passphrase string) (*encryptedWallet, []byte, error) {
+	salt := make([]byte, walletSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, nil, err
+	}
+
+	ew := &encryptedWallet{
+		Salt: salt,
+		N:    scryptN,
+		R:    scryptR,
+		P:    scryptP,
+	}
+
+	key, err := ew.deriveKey(passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ew, key, nil
+}
+
+func sealWallet(ew *encryptedWallet, key, plain []byte) ([]byte, error) {
+	aead, err := newWalletCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := *ew
+	sealed.Nonce = nonce
+	sealed.Data = aead.Seal(nil, nonce, plain, []byte(encryptedWalletMagic))
+
+	var content bytes.Buffer
+	content.WriteString(encryptedWalletMagic)
+	if err := gob.NewEncoder(&content).Encode(sealed); err != nil {
+		return nil, err
+	}
+
+	return content.Bytes(), nil
+}
+
+func openWallet(ew *encryptedWallet, key []byte) ([]byte, error) {
+	aead, err := newWalletCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := aead.Open(nil, ew.Nonce, ew.Data, []byte(encryptedWalletMagic))
+	if err != nil {
+		return nil, ErrInvalidPassphrase
+	}
+
+	return plain, nil
+}
+
+func newWalletCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func isEncryptedWallet(content []byte) bool {
+	return bytes.HasPrefix(content, []byte(encryptedWalletMagic))
+}
+
+func unmarshalEncryptedWallet(content []byte) (*encryptedWallet, error) {
+	var ew encryptedWallet
+
+	decoder := gob.NewDecoder(bytes.NewReader(content[len(encryptedWalletMagic):]))
+	if err := decoder.Decode(&ew); err != nil {
+		return nil, err
+	}
+
+	return &ew, nil
+}
+
+// writeFileAtomic writes data into a temporary file and renames it to name,
+// so that name has either the old content or the new content even on a crash.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name) + ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
@@ -2,30 +2,157 @@ package node
 
 import (
 	"bytes"
-	"crypto/elliptic"
 	"encoding/gob"
-	"fmt"
+	"errors"
 	"io/ioutil"
 	"log"
+	"sync"
+	"time"
 )
 
-const walletFile = "wallet_%s.dat"
+const (
+	walletFile = "wallet_%s.dat"
+	gapLimit   = 20 // the number of consecutive unused addresses to stop scanning at on restore
+)
 
 type Wallets struct {
-	Wallets map[string]*Wallet
+	Wallets      map[string]*Wallet
+	Seed         []byte // HD wallet seed derived from the mnemonic. It is nil for a non-HD wallet.
+	ReceiveIndex uint32 // next index of the receive chain
+	ChangeIndex  uint32 // next index of the change chain
+	KeyType      KeyType // key type of the addresses derived from the seed
+
+	mu        sync.Mutex
+	cipher    *encryptedWallet // encryption parameters. It is nil for a wallet which is not encrypted.
+	key       []byte           // key derived from the passphrase. It is nil while the wallet is locked.
+	lockTimer *time.Timer
+}
+
+func (ws *Wallets) CreateWallet(kt KeyType) string {
+	wallet := NewWallet(kt)
+	addr := string(wallet.GetAddress())
+	ws.Wallets[addr] = wallet
+	return addr
 }
 
-func (ws *Wallets) CreateWallet() string {
-	wallet := NewWallet()
+// ImportWallet adds the wallet, and replaces the watch-only wallet of the same address if any.
+func (ws *Wallets) ImportWallet(wallet *Wallet) string {
 	addr := string(wallet.GetAddress())
+	if old, exist := ws.Wallets[addr]; exist && !old.IsWatchOnly() {
+		return addr
+	}
+
 	ws.Wallets[addr] = wallet
 	return addr
 }
 
-func (ws Wallets) GetWallet(addr string) Wallet {
+func (ws *Wallets) IsHD() bool {
+	return len(ws.Seed) > 0
+}
+
+// SetMnemonic sets the seed derived from the mnemonic, so that new addresses of the key type are derived from it.
+func (ws *Wallets) SetMnemonic(mnemonic string, kt KeyType) error {
+	seed, err := NewSeedFromMnemonic(mnemonic, "")
+	if err != nil {
+		return err
+	}
+
+	ws.Seed = seed
+	ws.KeyType = kt
+	ws.ReceiveIndex = 0
+	ws.ChangeIndex = 0
+	return nil
+}
+
+// CreateHDWallet derives the next address of the chain, which is ReceiveChain or ChangeChain.
+func (ws *Wallets) CreateHDWallet(chain uint32) (string, error) {
+	var index *uint32
+	if chain == ChangeChain {
+		index = &ws.ChangeIndex
+	} else {
+		index = &ws.ReceiveIndex
+	}
+
+	wallet, err := ws.deriveWallet(chain, *index)
+	if err != nil {
+		return "", err
+	}
+
+	*index++
+	addr := string(wallet.GetAddress())
+	ws.Wallets[addr] = wallet
+	return addr, nil
+}
+
+// Restore scans both chains of the HD wallet and adds the addresses owning UTXOs.
+// Scanning of a chain stops after gapLimit consecutive addresses without UTXOs.
+func (ws *Wallets) Restore(utxoSet *UTXOSet) ([]string, error) {
+	var addrs []string
+
+	for _, chain := range []uint32{ReceiveChain, ChangeChain} {
+		next := uint32(0)
+		for index, unused := uint32(0), 0; unused < gapLimit; index++ {
+			wallet, err := ws.deriveWallet(chain, index)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(utxoSet.FindUTXOs(HashPkey(wallet.Pkey))) == 0 {
+				unused++
+				continue
+			}
+
+			unused = 0
+			next = index + 1
+			addr := string(wallet.GetAddress())
+			ws.Wallets[addr] = wallet
+			addrs = append(addrs, addr)
+		}
+
+		// the addresses derived before are not derived again, even if they are unused.
+		if chain == ChangeChain && next > ws.ChangeIndex {
+			ws.ChangeIndex = next
+		} else if chain == ReceiveChain && next > ws.ReceiveIndex {
+			ws.ReceiveIndex = next
+		}
+	}
+
+	return addrs, nil
+}
+
+func (ws *Wallets) deriveWallet(chain, index uint32) (*Wallet, error) {
+	if !ws.IsHD() {
+		return nil, errors.New("Wallet is not an HD wallet")
+	}
+
+	master, err := NewMasterKey(ws.Seed, ws.KeyType)
+	if err != nil {
+		return nil, err
+	}
+
+	path := hdPath(chain, index)
+	indexes, err := ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := master.Derive(indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	return key.Wallet(path), nil
+}
+
+func (ws *Wallets) GetWallet(addr string) Wallet {
 	return *ws.Wallets[addr]
 }
 
+func (ws *Wallets) HasWallet(addr string) bool {
+	_, exist := ws.Wallets[addr]
+	return exist
+}
+
 func (ws *Wallets) GetAddresses() []string {
 	var addrs []string
 
@@ -36,23 +163,183 @@ func (ws *Wallets) GetAddresses() []string {
 	return addrs
 }
 
-func (ws Wallets) SaveFile(nodeID string) {
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+func (ws *Wallets) IsEncrypted() bool {
+	return ws.cipher != nil
+}
+
+func (ws *Wallets) IsLocked() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.cipher != nil && ws.key == nil
+}
+
+// Unlock decrypts the wallet with the passphrase. The wallet is locked again after timeout, if timeout is positive.
+func (ws *Wallets) Unlock(passphrase string, timeout time.Duration) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.cipher == nil {
+		return ErrWalletNotEncrypted
+	}
+
+	key, err := ws.cipher.deriveKey(passphrase)
+	if err != nil {
+		return err
+	}
+
+	plain, err := openWallet(ws.cipher, key)
+	if err != nil {
+		return err
+	}
+
+	var wallets Wallets
+	decoder := gob.NewDecoder(bytes.NewReader(plain))
+	if err := decoder.Decode(&wallets); err != nil {
+		return err
+	}
+
+	ws.setContent(&wallets)
+	ws.key = key
+
+	if ws.lockTimer != nil {
+		ws.lockTimer.Stop()
+		ws.lockTimer = nil
+	}
+
+	if timeout > 0 {
+		ws.lockTimer = time.AfterFunc(timeout, ws.Lock)
+	}
+
+	return nil
+}
+
+// Lock wipes the decrypted keys from memory. It does nothing for a wallet which is not encrypted.
+func (ws *Wallets) Lock() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.cipher == nil {
+		return
+	}
+
+	if ws.lockTimer != nil {
+		ws.lockTimer.Stop()
+		ws.lockTimer = nil
+	}
+
+	for _, wallet := range ws.Wallets {
+		if wallet.Skey.D != nil {
+			wallet.Skey.D.SetInt64(0)
+		}
+	}
+	wipeBytes(ws.Seed)
+	wipeBytes(ws.key)
+
+	ws.setContent(&Wallets{Wallets: make(map[string]*Wallet)})
+	ws.key = nil
+}
+
+// SignPartialTx signs the partial transaction with the wallet, which must be unlocked if encrypted.
+// The wallet is not locked by the timer while signing.
+func (ws *Wallets) SignPartialTx(p *PartialTx) (int, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.cipher != nil && ws.key == nil {
+		return 0, ErrWalletLocked
+	}
+
+	return p.Sign(ws), nil
+}
+
+// Encrypt sets the passphrase of the wallet which is not encrypted yet. The wallet file is encrypted on SaveFile.
+func (ws *Wallets) Encrypt(passphrase string) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.cipher != nil {
+		return errors.New("Wallet is already encrypted")
+	}
+
+	return ws.setPassphrase(passphrase)
+}
+
+// ChangePassphrase re-encrypts the wallet file with the new passphrase atomically.
+func (ws *Wallets) ChangePassphrase(nodeID, oldPassphrase, newPassphrase string) error {
+	if err := ws.Unlock(oldPassphrase, 0); err != nil {
+		return err
+	}
+
+	ws.mu.Lock()
+	if err := ws.setPassphrase(newPassphrase); err != nil {
+		ws.mu.Unlock()
+		return err
+	}
+	ws.mu.Unlock()
+
+	ws.SaveFile(nodeID)
+	return nil
+}
+
+func (ws *Wallets) setPassphrase(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("Empty passphrase")
+	}
+
+	cipher, key, err := newEncryptedWallet(passphrase)
+	if err != nil {
+		return err
+	}
+
+	ws.cipher = cipher
+	ws.key = key
+	return nil
+}
+
+func (ws *Wallets) setContent(wallets *Wallets) {
+	ws.Wallets = wallets.Wallets
+	if ws.Wallets == nil {
+		ws.Wallets = make(map[string]*Wallet)
+	}
+	ws.Seed = wallets.Seed
+	ws.ReceiveIndex = wallets.ReceiveIndex
+	ws.ChangeIndex = wallets.ChangeIndex
+	ws.KeyType = wallets.KeyType
+}
+
+func (ws *Wallets) SaveFile(nodeID string) {
+	walletFile := activeNet.DataFile(walletFile, nodeID)
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if ws.cipher != nil && ws.key == nil {
+		log.Panic(ErrWalletLocked)
+	}
 
 	var content bytes.Buffer
-	gob.Register(elliptic.P256())
 	encoder := gob.NewEncoder(&content)
 	if err := encoder.Encode(ws); err != nil {
 		log.Panic(err)
 	}
 
-	if err := ioutil.WriteFile(walletFile, content.Bytes(), 0644); err != nil {
+	data := content.Bytes()
+	if ws.cipher != nil {
+		sealed, err := sealWallet(ws.cipher, ws.key, data)
+		if err != nil {
+			log.Panic(err)
+		}
+		data = sealed
+	}
+
+	if err := writeFileAtomic(walletFile, data, walletFilePerm); err != nil {
 		log.Panic(err)
 	}
 }
 
+// LoadFile loads the wallet file. An encrypted wallet is loaded locked, and must be unlocked to use its keys.
 func (ws *Wallets) LoadFile(nodeID string) error {
-	walletFile := fmt.Sprintf(walletFile, nodeID)
+	walletFile := activeNet.DataFile(walletFile, nodeID)
 	if !FileExist(walletFile) {
 		return nil // No error return here, because wallet file does not exist when the first wallet is created.
 	}
@@ -62,20 +349,36 @@ func (ws *Wallets) LoadFile(nodeID string) error {
 		log.Panic(err)
 	}
 
+	if isEncryptedWallet(content) {
+		cipher, err := unmarshalEncryptedWallet(content)
+		if err != nil {
+			return err
+		}
+
+		ws.cipher = cipher
+		ws.key = nil
+		return nil
+	}
+
 	var wallets Wallets
-	gob.Register(elliptic.P256())
 	decoder := gob.NewDecoder(bytes.NewReader(content))
 	if err := decoder.Decode(&wallets); err != nil {
 		log.Panic(err)
 	}
 
-	ws.Wallets = wallets.Wallets
+	ws.setContent(&wallets)
 	return nil
 }
 
 func NewWallets(nodeID string) (*Wallets, error) {
-	wallets := Wallets{}
+	wallets := &Wallets{}
 	wallets.Wallets = make(map[string]*Wallet)
 	err := wallets.LoadFile(nodeID)
-	return &wallets, err
+	return wallets, err
+}
+
+func wipeBytes(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
 }
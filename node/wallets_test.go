@@ -0,0 +1,54 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptedWallets(t *testing.T) {
+	t.Chdir(t.TempDir())
+	nodeID := "test"
+
+	ws, err := NewWallets(nodeID)
+	assert.Nil(t, err)
+	addr := ws.CreateWallet(DefaultKeyType)
+	assert.Nil(t, ws.Encrypt("secret"))
+	ws.SaveFile(nodeID)
+
+	info, err := os.Stat(fmt.Sprintf(walletFile, nodeID))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// an encrypted wallet is loaded locked.
+	loaded, err := NewWallets(nodeID)
+	assert.Nil(t, err)
+	assert.True(t, loaded.IsEncrypted())
+	assert.True(t, loaded.IsLocked())
+	assert.Empty(t, loaded.GetAddresses())
+
+	assert.Equal(t, ErrInvalidPassphrase, loaded.Unlock("wrong", 0))
+	assert.Nil(t, loaded.Unlock("secret", 0))
+	assert.False(t, loaded.IsLocked())
+	assert.Equal(t, []string{addr}, loaded.GetAddresses())
+
+	loaded.Lock()
+	assert.True(t, loaded.IsLocked())
+	assert.Empty(t, loaded.GetAddresses())
+
+	// the wallet is locked again after the timeout.
+	assert.Nil(t, loaded.Unlock("secret", 10 * time.Millisecond))
+	assert.Eventually(t, loaded.IsLocked, time.Second, 5 * time.Millisecond)
+
+	assert.Equal(t, ErrInvalidPassphrase, loaded.ChangePassphrase(nodeID, "wrong", "new secret"))
+	assert.Nil(t, loaded.ChangePassphrase(nodeID, "secret", "new secret"))
+
+	reloaded, err := NewWallets(nodeID)
+	assert.Nil(t, err)
+	assert.Equal(t, ErrInvalidPassphrase, reloaded.Unlock("secret", 0))
+	assert.Nil(t, reloaded.Unlock("new secret", 0))
+	assert.Equal(t, []string{addr}, reloaded.GetAddresses())
+}
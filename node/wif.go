@@ -0,0 +1,111 @@
+package node
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+
+	"github.com/hansung080/gchain/encoding/base58"
+)
+
+/**
+  @ How to Export Private Key (WIF-like)
+
+    Version (0x80 on mainnet) + Key Type + Private Key (32 bytes) + Public Key Format
+                      V   ---> SHA256( SHA256( Version + ... + Public Key Format ) )
+                      V   |        V
+                    Payload + Checksum (1 + 1 + 32 + 1 + 4 = 39 bytes)
+                      V
+             Base58Encode( Payload )
+                      V
+             Exported Private Key
+
+  @ Public Key Format
+    - 0x00: legacy public key (P-256 only)
+    - 0x01: compressed public key
+    - 0x02: uncompressed public key
+*/
+
+const (
+	wifLegacyPkey       = byte(0x00)
+	wifCompressedPkey   = byte(0x01)
+	wifUncompressedPkey = byte(0x02)
+)
+
+var errInvalidWIF = errors.New("Invalid private key format")
+
+// EncodeWIF exports the private key of the wallet with the format of its public key,
+// so that DecodeWIF restores the same address.
+func EncodeWIF(w *Wallet) (string, error) {
+	if w.IsWatchOnly() {
+		return "", errors.New("Wallet is watch-only")
+	}
+
+	pkeyFormat := wifCompressedPkey
+	if _, legacy, err := ParsePkey(w.Pkey); err != nil {
+		return "", err
+	} else if legacy {
+		pkeyFormat = wifLegacyPkey
+	} else if w.Pkey[1] == sec1Uncompressed {
+		pkeyFormat = wifUncompressedPkey
+	}
+
+	skey := make([]byte, w.Type.Size())
+	w.Skey.D.FillBytes(skey)
+
+	payload := []byte{activeNet.WIFVersion, byte(w.Type)}
+	payload = append(payload, skey...)
+	payload = append(payload, pkeyFormat)
+	payload = append(payload, newChecksum(payload)...)
+	return string(base58.Encode(payload)), nil
+}
+
+func DecodeWIF(wif string) (*Wallet, error) {
+	if wif == "" {
+		return nil, errInvalidWIF
+	}
+
+	payload := base58.Decode([]byte(wif))
+	if len(payload) < 3 + addressChecksumLen || payload[0] != activeNet.WIFVersion {
+		return nil, errInvalidWIF
+	}
+
+	data := payload[:len(payload) - addressChecksumLen]
+	if !bytes.Equal(newChecksum(data), payload[len(data):]) {
+		return nil, errInvalidWIF
+	}
+
+	kt := KeyType(data[1])
+	if _, exist := keyCurves[kt]; !exist || len(data) != 3 + kt.Size() {
+		return nil, errInvalidWIF
+	}
+
+	// the private key must be in [1, N - 1], whose public key is not the point at infinity.
+	d := new(big.Int).SetBytes(data[2:2 + kt.Size()])
+	if d.Sign() == 0 || d.Cmp(keyCurves[kt].curve.Params().N) >= 0 {
+		return nil, errInvalidWIF
+	}
+
+	skey := PrivateKeyFromBytes(kt, data[2:2 + kt.Size()])
+
+	var pkey []byte
+	switch data[len(data) - 1] {
+	case wifLegacyPkey:
+		if kt != P256 {
+			return nil, errInvalidWIF
+		}
+		pkey = append(skey.X.Bytes(), skey.Y.Bytes()...)
+	case wifCompressedPkey:
+		pkey = MarshalPkey(kt, &skey.PublicKey, true)
+	case wifUncompressedPkey:
+		pkey = MarshalPkey(kt, &skey.PublicKey, false)
+	default:
+		return nil, errInvalidWIF
+	}
+
+	return &Wallet{
+		Skey: skey,
+		Pkey: pkey,
+		Type: kt,
+	}, nil
+}
@@ -0,0 +1,47 @@
+package node
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/hansung080/gchain/encoding/base58"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWIF(t *testing.T) {
+	legacy := NewWallet(P256)
+	legacy.Pkey = append(legacy.Skey.X.Bytes(), legacy.Skey.Y.Bytes()...)
+
+	for _, wallet := range []*Wallet{NewWallet(P256), NewWallet(Secp256k1), legacy} {
+		wif, err := EncodeWIF(wallet)
+		assert.Nil(t, err)
+
+		decoded, err := DecodeWIF(wif)
+		assert.Nil(t, err)
+		assert.Equal(t, wallet.GetAddress(), decoded.GetAddress())
+		assert.Equal(t, 0, wallet.Skey.D.Cmp(decoded.Skey.D))
+
+		corrupted := []byte(wif)
+		corrupted[len(corrupted) / 2]++
+		_, err = DecodeWIF(string(corrupted))
+		assert.NotNil(t, err)
+	}
+
+	// the private key out of [1, N - 1] is rejected, which has no valid public key.
+	for _, kt := range []KeyType{P256, Secp256k1} {
+		for _, d := range []*big.Int{big.NewInt(0), keyCurves[kt].curve.Params().N} {
+			payload := []byte{activeNet.WIFVersion, byte(kt)}
+			payload = append(payload, d.FillBytes(make([]byte, kt.Size()))...)
+			payload = append(payload, wifCompressedPkey)
+			payload = append(payload, newChecksum(payload)...)
+			_, err := DecodeWIF(string(base58.Encode(payload)))
+			assert.Equal(t, errInvalidWIF, err, "%s private key %d", kt, d)
+		}
+	}
+
+	watchOnly, err := NewWatchOnlyWallet(string(NewWallet(Secp256k1).GetAddress()))
+	assert.Nil(t, err)
+	assert.True(t, watchOnly.IsWatchOnly())
+	_, err = EncodeWIF(watchOnly)
+	assert.NotNil(t, err)
+}